@@ -0,0 +1,84 @@
+// Command chat ส่ง prompt ไปยัง Agent จาก command line สำหรับคนเขียน prompt
+//
+//	go run ./cmd/chat -system "You are an examiner" -schema schema.json -prompt-file essay.txt -dry-run
+//
+// เมื่อใช้ -dry-run จะพิมพ์ request ที่จะส่งจริง (รวม system prompt จาก schema)
+// พร้อมจำนวน token และค่าใช้จ่ายโดยประมาณ โดยไม่เรียก API
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"go-azure-openai/internal/service/agent"
+)
+
+func main() {
+	prompt := flag.String("prompt", "", "user prompt")
+	promptFile := flag.String("prompt-file", "", "อ่าน user prompt จากไฟล์ (- คือ stdin)")
+	system := flag.String("system", "", "system prompt")
+	schemaFile := flag.String("schema", "", "ไฟล์ JSON Schema ของคำตอบ")
+	temperature := flag.Float64("temperature", 0.7, "sampling temperature")
+	maxTokens := flag.Int("max-tokens", 0, "จำกัด output tokens (0 = ให้ API กำหนด)")
+	dryRun := flag.Bool("dry-run", false, "แสดง request, token และค่าใช้จ่ายโดยประมาณ โดยไม่ส่งจริง")
+	flag.Parse()
+
+	text := *prompt
+	if *promptFile != "" {
+		b, err := readInput(*promptFile)
+		if err != nil {
+			log.Fatalf("read prompt: %v", err)
+		}
+		text = string(b)
+	}
+	if text == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var opts []agent.Option
+	if *dryRun {
+		opts = append(opts, agent.WithDryRun())
+	}
+	a, err := agent.NewAuto(opts...)
+	if err != nil {
+		log.Fatalf("agent: %v", err)
+	}
+
+	chatOpts := []agent.ChatOption{agent.WithTemperature(float32(*temperature)), agent.WithMaxTokens(*maxTokens)}
+	if *system != "" {
+		chatOpts = append(chatOpts, agent.WithSystem(*system))
+	}
+	var res agent.ChatResult
+	if *schemaFile != "" {
+		schema, err := os.ReadFile(*schemaFile)
+		if err != nil {
+			log.Fatalf("read schema: %v", err)
+		}
+		res, _, err = a.ChatStructuredJSON(context.Background(), text, append(chatOpts, agent.WithOutputSchema(string(schema)))...)
+		if err != nil && res.Text == "" {
+			log.Fatalf("chat: %v", err)
+		}
+	} else if res, err = a.ChatStructured(context.Background(), text, chatOpts...); err != nil {
+		log.Fatalf("chat: %v", err)
+	}
+
+	if res.DryRun != nil {
+		out, _ := json.MarshalIndent(res.DryRun, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	fmt.Println(res.Text)
+}
+
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
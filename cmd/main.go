@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"go-azure-openai/internal/metrics"
 	"go-azure-openai/internal/server"
+	"go-azure-openai/internal/telemetry"
 	"log"
 	"os"
 
@@ -9,7 +12,21 @@ import (
 )
 
 func main() {
-	app := server.NewServer("AI-Service")
+	// ส่ง trace/metrics ผ่าน OTLP เมื่อกำหนด OTEL_EXPORTER_OTLP_ENDPOINT
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
+		shutdown, err := telemetry.Setup(context.Background(), telemetry.Options{ServiceName: "AI-Service"})
+		if err != nil {
+			log.Fatalf("telemetry setup: %v", err)
+		}
+		defer shutdown(context.Background())
+	}
+
+	// Prometheus /metrics เปิดไว้เสมอ ยกเว้น METRICS_DISABLED=true
+	var opts []server.Option
+	if os.Getenv("METRICS_DISABLED") != "true" {
+		opts = append(opts, server.WithMetrics(metrics.New()))
+	}
+	app := server.NewServer("AI-Service", opts...)
 
 	app.Get("/ai/chat", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
@@ -0,0 +1,145 @@
+// Package metrics collects Prometheus metrics for the HTTP server, Agent calls,
+// caches and job queues, and serves them in the Prometheus exposition format.
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-azure-openai/internal/service/agent"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "ai_service"
+
+// Metrics owns a Prometheus registry and the collectors registered on it.
+// It implements agent.Observer, so it can be passed to agent.WithObserver.
+type Metrics struct {
+	reg *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	httpInFlight prometheus.Gauge
+
+	agentDuration *prometheus.HistogramVec
+	agentTokens   *prometheus.CounterVec
+	agentInFlight *prometheus.GaugeVec
+
+	cacheLookups *prometheus.CounterVec
+	queueDepth   *prometheus.GaugeVec
+}
+
+// New creates Metrics with its own registry, including the Go runtime and
+// process collectors.
+func New() *Metrics {
+	m := &Metrics{
+		reg: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "http", Name: "requests_total",
+			Help: "HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "http", Name: "request_duration_seconds",
+			Help:    "HTTP request latency by method and route.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		httpInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "http", Name: "requests_in_flight",
+			Help: "HTTP requests currently being served.",
+		}),
+		agentDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "agent", Name: "call_duration_seconds",
+			Help:    "Agent backend call latency by operation, deployment and outcome.",
+			Buckets: []float64{0.1, 0.25, 0.5, 1, 2, 4, 8, 16, 32, 64},
+		}, []string{"operation", "deployment", "outcome"}),
+		agentTokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "agent", Name: "tokens_total",
+			Help: "Tokens reported by the backend by deployment and type (prompt or completion).",
+		}, []string{"deployment", "type"}),
+		agentInFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "agent", Name: "calls_in_flight",
+			Help: "Agent backend calls currently running.",
+		}, []string{"operation", "deployment"}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "cache", Name: "lookups_total",
+			Help: "Cache lookups by cache and result (hit or miss).",
+		}, []string{"cache", "result"}),
+		queueDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "queue", Name: "depth",
+			Help: "Jobs waiting in a queue, e.g. grading.",
+		}, []string{"queue"}),
+	}
+	m.reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests, m.httpDuration, m.httpInFlight,
+		m.agentDuration, m.agentTokens, m.agentInFlight,
+		m.cacheLookups, m.queueDepth,
+	)
+	return m
+}
+
+// Registry returns the underlying registry, for registering extra collectors.
+func (m *Metrics) Registry() *prometheus.Registry { return m.reg }
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.reg, promhttp.HandlerOpts{Registry: m.reg})
+}
+
+// RequestStarted marks an HTTP request as in flight.
+func (m *Metrics) RequestStarted() { m.httpInFlight.Inc() }
+
+// RequestFinished records a served HTTP request. route is the matched route
+// pattern, not the raw path, to keep label cardinality bounded.
+func (m *Metrics) RequestFinished(method, route string, status int, seconds float64) {
+	m.httpInFlight.Dec()
+	m.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(method, route).Observe(seconds)
+}
+
+// SetQueueDepth reports the number of jobs waiting in queue.
+func (m *Metrics) SetQueueDepth(queue string, depth int) {
+	m.queueDepth.WithLabelValues(queue).Set(float64(depth))
+}
+
+// CallStarted implements agent.Observer.
+func (m *Metrics) CallStarted(operation, deployment string) {
+	m.agentInFlight.WithLabelValues(operation, deployment).Inc()
+}
+
+// CallFinished implements agent.Observer.
+func (m *Metrics) CallFinished(s agent.CallStats) {
+	m.agentInFlight.WithLabelValues(s.Operation, s.Deployment).Dec()
+	m.agentDuration.WithLabelValues(s.Operation, s.Deployment, outcome(s.Err)).Observe(s.Duration.Seconds())
+	if s.PromptTokens > 0 {
+		m.agentTokens.WithLabelValues(s.Deployment, "prompt").Add(float64(s.PromptTokens))
+	}
+	if s.CompletionTokens > 0 {
+		m.agentTokens.WithLabelValues(s.Deployment, "completion").Add(float64(s.CompletionTokens))
+	}
+}
+
+// CacheLookup implements agent.Observer. The hit ratio is
+// rate(lookups_total{result="hit"}) / rate(lookups_total).
+func (m *Metrics) CacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cacheLookups.WithLabelValues(cache, result).Inc()
+}
+
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, agent.ErrCircuitOpen):
+		return "circuit_open"
+	}
+	return "error"
+}
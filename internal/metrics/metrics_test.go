@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go-azure-openai/internal/service/agent"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics_AgentObserver(t *testing.T) {
+	m := New()
+	var _ agent.Observer = m
+
+	m.CallStarted("chat", "gpt-dep")
+	if got := testutil.ToFloat64(m.agentInFlight.WithLabelValues("chat", "gpt-dep")); got != 1 {
+		t.Fatalf("expected 1 call in flight, got %v", got)
+	}
+	m.CallFinished(agent.CallStats{Operation: "chat", Deployment: "gpt-dep", Duration: time.Second, PromptTokens: 12, CompletionTokens: 5})
+	m.CallStarted("chat", "gpt-dep")
+	m.CallFinished(agent.CallStats{Operation: "chat", Deployment: "gpt-dep", Err: errors.Join(agent.ErrCircuitOpen)})
+
+	if got := testutil.ToFloat64(m.agentInFlight.WithLabelValues("chat", "gpt-dep")); got != 0 {
+		t.Fatalf("expected no calls in flight, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.agentTokens.WithLabelValues("gpt-dep", "prompt")); got != 12 {
+		t.Fatalf("expected 12 prompt tokens, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.agentTokens.WithLabelValues("gpt-dep", "completion")); got != 5 {
+		t.Fatalf("expected 5 completion tokens, got %v", got)
+	}
+	if n := testutil.CollectAndCount(m.agentDuration); n != 2 {
+		t.Fatalf("expected ok and circuit_open series, got %d", n)
+	}
+}
+
+func TestMetrics_CacheLookup(t *testing.T) {
+	m := New()
+	m.CacheLookup(agent.CacheSpeech, true)
+	m.CacheLookup(agent.CacheSpeech, true)
+	m.CacheLookup(agent.CacheSpeech, false)
+	hits := testutil.ToFloat64(m.cacheLookups.WithLabelValues("speech", "hit"))
+	misses := testutil.ToFloat64(m.cacheLookups.WithLabelValues("speech", "miss"))
+	if hits != 2 || misses != 1 {
+		t.Fatalf("expected 2 hits and 1 miss, got %v/%v", hits, misses)
+	}
+}
@@ -1,12 +1,38 @@
 package server
 
 import (
+	"go-azure-openai/internal/metrics"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 )
 
-func NewServer(AppName string) *fiber.App {
+// Option ปรับแต่ง NewServer
+type Option func(*options)
+
+type options struct {
+	metrics     *metrics.Metrics
+	metricsPath string
+}
+
+// WithMetrics เปิด Prometheus metrics และ endpoint สำหรับ scrape (default /metrics)
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(o *options) { o.metrics = m }
+}
+
+// WithMetricsPath เปลี่ยน path ของ metrics endpoint
+func WithMetricsPath(path string) Option {
+	return func(o *options) { o.metricsPath = path }
+}
+
+func NewServer(AppName string, opts ...Option) *fiber.App {
+	o := options{metricsPath: "/metrics"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	// สร้าง instance ของ Fiber app
 	app := fiber.New(fiber.Config{
 		AppName: AppName,
@@ -15,6 +41,10 @@ func NewServer(AppName string) *fiber.App {
 	// Middleware พื้นฐาน
 	app.Use(recover.New()) // ป้องกัน server crash
 	app.Use(logger.New())  // log request/response
+	app.Use(tracing())     // OpenTelemetry server span ต่อ request
+	if o.metrics != nil {
+		app.Use(httpMetrics(o.metrics)) // นับ request/latency ต่อ route
+	}
 
 	// Health check route
 	app.Get("/health", func(c *fiber.Ctx) error {
@@ -24,5 +54,10 @@ func NewServer(AppName string) *fiber.App {
 		})
 	})
 
+	// Prometheus exposition
+	if o.metrics != nil {
+		app.Get(o.metricsPath, adaptor.HTTPHandler(o.metrics.Handler()))
+	}
+
 	return app
 }
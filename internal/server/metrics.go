@@ -0,0 +1,45 @@
+package server
+
+import (
+	"time"
+
+	"go-azure-openai/internal/metrics"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// unmatchedRoute labels requests that hit no route, so probes for random paths
+// cannot blow up label cardinality.
+const unmatchedRoute = "unmatched"
+
+// httpMetrics records request count, latency and in-flight requests per route.
+// A panicking handler is recorded as a 500 before the panic reaches recover.
+func httpMetrics(m *metrics.Metrics) fiber.Handler {
+	return func(c *fiber.Ctx) (err error) {
+		start := time.Now()
+		m.RequestStarted()
+		self := c.Route()
+		panicked := true
+		defer func() {
+			status := c.Response().StatusCode()
+			if panicked {
+				status = fiber.StatusInternalServerError
+			} else if err != nil {
+				if fe, ok := err.(*fiber.Error); ok {
+					status = fe.Code
+				} else {
+					status = fiber.StatusInternalServerError
+				}
+			}
+			// Without a matching route c.Route() is still this middleware's own.
+			route := c.Route().Path
+			if c.Route() == self {
+				route = unmatchedRoute
+			}
+			m.RequestFinished(c.Method(), route, status, time.Since(start).Seconds())
+		}()
+		err = c.Next()
+		panicked = false
+		return err
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-azure-openai/internal/metrics"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestNewServer_MetricsEndpoint(t *testing.T) {
+	m := metrics.New()
+	m.SetQueueDepth("grading", 3)
+	app := NewServer("test", WithMetrics(m))
+
+	for _, path := range []string{"/health", "/health", "/no-such-path"} {
+		if _, err := app.Test(httptest.NewRequest("GET", path, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := app.Test(httptest.NewRequest("GET", "/metrics", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	out := string(body)
+	for _, want := range []string{
+		`ai_service_http_requests_total{method="GET",route="/health",status="200"} 2`,
+		`ai_service_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`ai_service_queue_depth{queue="grading"} 3`,
+		`ai_service_http_requests_in_flight 1`, // the scrape itself
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("metrics output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestNewServer_MetricsRecordPanics(t *testing.T) {
+	m := metrics.New()
+	app := NewServer("test", WithMetrics(m))
+	app.Get("/boom", func(c *fiber.Ctx) error { panic("boom") })
+
+	if resp, err := app.Test(httptest.NewRequest("GET", "/boom", nil)); err != nil || resp.StatusCode != 500 {
+		t.Fatalf("expected recover to answer 500, got %v %v", resp, err)
+	}
+	resp, err := app.Test(httptest.NewRequest("GET", "/metrics", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	out := string(body)
+	for _, want := range []string{
+		`ai_service_http_requests_total{method="GET",route="/boom",status="500"} 1`,
+		`ai_service_http_requests_in_flight 1`, // only the scrape
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("metrics output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestNewServer_MetricsDisabledByDefault(t *testing.T) {
+	app := NewServer("test")
+	resp, err := app.Test(httptest.NewRequest("GET", "/metrics", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 404 {
+		t.Fatalf("expected 404 without WithMetrics, got %d", resp.StatusCode)
+	}
+}
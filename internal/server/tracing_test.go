@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing_PropagatesIncomingTrace(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	app := NewServer("test")
+	var handlerTrace trace.TraceID
+	app.Get("/ai/chat", func(c *fiber.Ctx) error {
+		handlerTrace = trace.SpanContextFromContext(c.UserContext()).TraceID()
+		return c.SendString("ok")
+	})
+
+	req := httptest.NewRequest("GET", "/ai/chat", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if _, err := app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+	spans := rec.Ended()
+	if len(spans) != 1 || spans[0].Name() != "GET /ai/chat" {
+		t.Fatalf("unexpected spans: %v", spans)
+	}
+	if got := spans[0].SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("trace not continued: %s", got)
+	}
+	if handlerTrace != spans[0].SpanContext().TraceID() {
+		t.Fatalf("handler context does not carry the server span")
+	}
+}
@@ -6,39 +6,122 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
+	"net/http"
 	"os"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"go-azure-openai/internal/service/guardrail"
+	"go-azure-openai/internal/service/injection"
+	"go-azure-openai/internal/service/moderation"
+	"go-azure-openai/internal/service/redact"
+	"go-azure-openai/internal/service/schema"
+
 	"github.com/joho/godotenv"
 	openai "github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Config holds minimal Azure OpenAI configuration.
 // Values can be left empty to fall back to environment variables:
 //
-//	AZURE_OPENAI_KEY, AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_MODEL, AZURE_OPENAI_DEPLOYMENT
+//	AI_PROVIDER, AZURE_OPENAI_KEY, AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_API_VERSION,
+//	AZURE_OPENAI_MODEL, AZURE_OPENAI_DEPLOYMENT, AZURE_OPENAI_EMBEDDING_DEPLOYMENT,
+//	AZURE_OPENAI_WHISPER_DEPLOYMENT, AZURE_OPENAI_TTS_DEPLOYMENT, AZURE_OPENAI_IMAGE_DEPLOYMENT,
+//	AZURE_OPENAI_PROXY, AZURE_OPENAI_CA_FILE, AZURE_OPENAI_CLIENT_CERT, AZURE_OPENAI_CLIENT_KEY,
+//	AZURE_OPENAI_KEY_FILE, AZURE_OPENAI_KEY_SECONDARY
+//
+// AZURE_OPENAI_KEY_FILE selects FileKeys; otherwise AZURE_OPENAI_KEY_SECONDARY
+// selects EnvKeys over AZURE_OPENAI_KEY and AZURE_OPENAI_KEY_SECONDARY.
+//
+// For the openai and compatible providers OPENAI_API_KEY, OPENAI_BASE_URL,
+// OPENAI_API_KEY_FILE and OPENAI_API_KEY_SECONDARY are read instead of the
+// Azure key, endpoint and api-version; deployment fields are then sent as plain
+// model names.
 type Config struct {
-	Key        string
-	Endpoint   string
-	Model      string
-	Deployment string // optional; if empty uses Model
-	Timeout    time.Duration
+	Provider            Provider // optional; defaults to ProviderAzure
+	Key                 string
+	Endpoint            string
+	APIVersion          string // azure only; empty uses the client default
+	Model               string
+	Deployment          string     // optional; if empty uses Model
+	EmbeddingDeployment string     // optional; required only for Embed
+	WhisperDeployment   string     // optional; required only for Transcribe/Translate
+	SpeechDeployment    string     // optional; required only for Speak
+	SpeechCacheDir      string     // optional; if set, Speak caches audio here by text hash
+	ImageDeployment     string     // optional; required only for GenerateImage
+	ImageStore          ImageStore // optional; if set, GenerateImage saves images with their prompts
+	Timeout             time.Duration
+	CircuitBreaker      *BreakerConfig // optional; fail fast while the backend is unhealthy
+	Hedge               *HedgePolicy   // optional; send a duplicate when a chat call is slow
+	// TracerProvider and MeterProvider receive spans and metrics following the
+	// OpenTelemetry GenAI conventions. Nil uses the otel global providers.
+	TracerProvider trace.TracerProvider
+	MeterProvider  metric.MeterProvider
+	// Observer is told about each backend call and cache lookup, e.g. to export
+	// Prometheus metrics. Optional.
+	Observer Observer
+	// Redactor replaces personal data in chat prompts with placeholders before
+	// they are sent and restores it in the answer. Optional.
+	Redactor *redact.Redactor
+	// InjectionClassifier checks content passed with WithUntrustedContent.
+	// Nil uses injection.Heuristic.
+	InjectionClassifier injection.Classifier
+	// Spotlight is how untrusted content is marked; empty uses injection.ModeDelimit.
+	Spotlight injection.Mode
+	// DryRun builds chat requests without sending them; see WithDryRun.
+	DryRun bool
+	// Pricing adds or overrides DefaultPricing for dry-run and budget cost
+	// estimates, keyed by deployment or model name. Deployments under a cost
+	// limit must be priced; see ErrNoPrice.
+	Pricing map[string]Price
+	// Transport configures proxy, TLS, connection pooling and per-attempt
+	// timeouts of the HTTP client. Nil uses the TransportConfig defaults.
+	Transport *TransportConfig
+	// KeyProvider supplies several keys (e.g. primary and secondary) and is
+	// consulted on every request; on a 401 the next key is tried. When set,
+	// Key is not used.
+	KeyProvider KeyProvider
+	// CallLimits caps every chat call; see WithCallLimits for per-call caps.
+	CallLimits CallLimits
+	// TenantBudgets holds the daily budgets used with ContextWithTenant.
+	TenantBudgets *TenantBudgets
+	// Profiles holds the named profiles used with Agent.Profile.
+	Profiles *ProfileRegistry
+	// Moderation screens the input and output of every chat call; see
+	// WithModeration. Optional.
+	Moderation *moderation.Policy
 }
 
-// LoadEnv fills empty fields from environment variables.
+// LoadEnv fills empty fields from environment variables, reading .env from
+// the working directory first when there is one.
 func (c *Config) LoadEnv() {
-	err := godotenv.Load()
-	if err != nil {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
 		log.Fatal("Error loading .env file")
 	}
 
+	if c.Provider == "" {
+		c.Provider = Provider(os.Getenv("AI_PROVIDER"))
+	}
+	c.Provider = normalizeProvider(c.Provider)
+	// only the variables of the selected provider are read, so a .env that
+	// still holds the Azure settings does not leak into another provider
+	keyVar, endpointVar := "AZURE_OPENAI_KEY", "AZURE_OPENAI_ENDPOINT"
+	if !c.Provider.isAzure() {
+		keyVar, endpointVar = "OPENAI_API_KEY", "OPENAI_BASE_URL"
+	}
 	if c.Key == "" {
-		c.Key = os.Getenv("AZURE_OPENAI_KEY")
+		c.Key = os.Getenv(keyVar)
 	}
 	if c.Endpoint == "" {
-		c.Endpoint = os.Getenv("AZURE_OPENAI_ENDPOINT")
+		c.Endpoint = os.Getenv(endpointVar)
+	}
+	if c.APIVersion == "" && c.Provider.isAzure() {
+		c.APIVersion = os.Getenv("AZURE_OPENAI_API_VERSION")
 	}
 	if c.Model == "" {
 		c.Model = os.Getenv("AZURE_OPENAI_MODEL")
@@ -48,20 +131,61 @@ func (c *Config) LoadEnv() {
 			c.Deployment = d
 		}
 	}
+	if c.EmbeddingDeployment == "" {
+		c.EmbeddingDeployment = os.Getenv("AZURE_OPENAI_EMBEDDING_DEPLOYMENT")
+	}
+	if c.WhisperDeployment == "" {
+		c.WhisperDeployment = os.Getenv("AZURE_OPENAI_WHISPER_DEPLOYMENT")
+	}
+	if c.SpeechDeployment == "" {
+		c.SpeechDeployment = os.Getenv("AZURE_OPENAI_TTS_DEPLOYMENT")
+	}
+	if c.ImageDeployment == "" {
+		c.ImageDeployment = os.Getenv("AZURE_OPENAI_IMAGE_DEPLOYMENT")
+	}
+	c.Transport = loadTransportEnv(c.Transport)
+	if c.KeyProvider == nil {
+		if f := os.Getenv(keyVar + "_FILE"); f != "" {
+			c.KeyProvider = FileKeys(f)
+		} else if os.Getenv(keyVar+"_SECONDARY") != "" {
+			c.KeyProvider = EnvKeys(keyVar, keyVar+"_SECONDARY")
+		}
+	}
 }
 
-// Validate basic required fields.
+// Validate basic required fields for the configured provider.
 func (c *Config) Validate() error {
-	if c.Key == "" || c.Endpoint == "" || c.Model == "" {
-		return errors.New("missing required azure openai configuration (need key, endpoint, model)")
+	c.Provider = normalizeProvider(c.Provider)
+	switch c.Provider {
+	case ProviderAzure, ProviderAzureAD:
+		if (c.Key == "" && c.KeyProvider == nil) || c.Endpoint == "" || c.Model == "" {
+			return errors.New("missing required azure openai configuration (need key, endpoint, model)")
+		}
+	case ProviderOpenAI:
+		if (c.Key == "" && c.KeyProvider == nil) || c.Model == "" {
+			return errors.New("missing required openai configuration (need key, model)")
+		}
+	case ProviderCompatible:
+		if c.Endpoint == "" || c.Model == "" {
+			return errors.New("missing required compatible provider configuration (need endpoint, model)")
+		}
+	default:
+		return fmt.Errorf("unknown provider %q", c.Provider)
 	}
 	return nil
 }
 
 // Agent is a lightweight wrapper around the OpenAI client to simplify common chat use cases.
 type Agent struct {
-	cfg    Config
-	client oaiClient
+	cfg        Config
+	client     oaiClient
+	httpClient *http.Client // used by New's client; nil with NewWithClient
+	breakerMu  sync.Mutex
+	breakers   map[string]*CircuitBreaker // by deployment
+	latency    latencyWindow
+	usage      usageCounter
+	telOnce    sync.Once
+	tel        *instruments
 }
 
 // oaiClient is a minimal interface of the go-openai client used by Agent.
@@ -72,7 +196,15 @@ type oaiClient interface {
 }
 
 // GetConfig returns a copy of the agent configuration (read-only for caller).
-func (a *Agent) GetConfig() Config { return a.cfg }
+// For agents made by New, Transport holds the HTTP settings in effect.
+func (a *Agent) GetConfig() Config {
+	cfg := a.cfg
+	if cfg.Transport != nil {
+		t := *cfg.Transport
+		cfg.Transport = &t
+	}
+	return cfg
+}
 
 // New creates a new Agent using the provided config (with env fallbacks).
 func New(cfg Config) (*Agent, error) {
@@ -86,23 +218,46 @@ func New(cfg Config) (*Agent, error) {
 	if cfg.Deployment == "" {
 		cfg.Deployment = cfg.Model
 	}
-	oaiCfg := openai.DefaultAzureConfig(cfg.Key, cfg.Endpoint)
-	// Map logical model -> deployment
-	oaiCfg.AzureModelMapperFunc = func(model string) string {
-		// Always return the explicit deployment for our configured model.
-		if model == cfg.Model {
-			return cfg.Deployment
-		}
-		// fallback: echo original (allows direct deployment usage)
-		return model
+	var tc TransportConfig
+	if cfg.Transport != nil {
+		tc = *cfg.Transport
+	}
+	tc = tc.withDefaults()
+	cfg.Transport = &tc
+	hc, err := tc.httpClient()
+	if err != nil {
+		return nil, fmt.Errorf("http transport: %w", err)
+	}
+	oaiCfg, err := clientConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	oaiCfg.HTTPClient = hc
+	if cfg.KeyProvider != nil {
+		// hc itself stays key-free for downloads from other hosts.
+		kt := &keyTransport{base: hc.Transport, provider: cfg.KeyProvider, bearer: cfg.Provider != ProviderAzure}
+		oaiCfg.HTTPClient = &http.Client{Transport: kt, Timeout: hc.Timeout}
 	}
 	client := openai.NewClientWithConfig(oaiCfg)
-	return &Agent{cfg: cfg, client: client}, nil
+	a := newAgent(cfg, client)
+	a.httpClient = hc
+	return a, nil
+}
+
+// newAgent wires optional components around a validated config and client.
+func newAgent(cfg Config, client oaiClient) *Agent {
+	return &Agent{cfg: cfg, client: client}
 }
 
 // Option is a functional option to modify agent configuration before initialization.
 type Option func(*Config)
 
+// WithProvider selects the API provider (azure, azure_ad, openai, compatible).
+func WithProvider(p Provider) Option { return func(c *Config) { c.Provider = p } }
+
+// WithAPIVersion overrides the Azure api-version.
+func WithAPIVersion(v string) Option { return func(c *Config) { c.APIVersion = v } }
+
 // WithKey overrides API key.
 func WithKey(v string) Option { return func(c *Config) { c.Key = v } }
 
@@ -115,15 +270,42 @@ func WithModel(v string) Option { return func(c *Config) { c.Model = v } }
 // WithDeployment sets deployment mapping explicitly.
 func WithDeployment(v string) Option { return func(c *Config) { c.Deployment = v } }
 
+// WithEmbeddingDeployment sets the deployment used by Embed.
+func WithEmbeddingDeployment(v string) Option { return func(c *Config) { c.EmbeddingDeployment = v } }
+
+// WithWhisperDeployment sets the deployment used by Transcribe and Translate.
+func WithWhisperDeployment(v string) Option { return func(c *Config) { c.WhisperDeployment = v } }
+
+// WithSpeechDeployment sets the deployment used by Speak.
+func WithSpeechDeployment(v string) Option { return func(c *Config) { c.SpeechDeployment = v } }
+
+// WithSpeechCacheDir enables the Speak audio cache in dir.
+func WithSpeechCacheDir(dir string) Option { return func(c *Config) { c.SpeechCacheDir = dir } }
+
+// WithImageDeployment sets the deployment used by GenerateImage.
+func WithImageDeployment(v string) Option { return func(c *Config) { c.ImageDeployment = v } }
+
+// WithImageStore saves images from GenerateImage in s.
+func WithImageStore(s ImageStore) Option { return func(c *Config) { c.ImageStore = s } }
+
 // WithTimeout sets request timeout.
 func WithTimeout(d time.Duration) Option { return func(c *Config) { c.Timeout = d } }
 
-// NewAuto creates an Agent pulling defaults from environment variables first, then applying options.
+// WithCircuitBreaker enables a circuit breaker around backend calls.
+func WithCircuitBreaker(b BreakerConfig) Option { return func(c *Config) { c.CircuitBreaker = &b } }
+
+// WithHedging enables hedged chat requests.
+func WithHedging(p HedgePolicy) Option { return func(c *Config) { c.Hedge = &p } }
+
+// WithObserver sets an Observer for backend calls and cache lookups.
+func WithObserver(o Observer) Option { return func(c *Config) { c.Observer = o } }
+
+// NewAuto creates an Agent from options, with environment variables filling
+// whatever the options leave empty. The options are applied first, so the
+// environment is read for the provider they select.
 // This allows super simple usage: a, _ := agent.NewAuto(agent.WithModel("gpt-4o-mini"))
 func NewAuto(opts ...Option) (*Agent, error) {
 	cfg := Config{}
-	// load env first
-	cfg.LoadEnv()
 	for _, o := range opts {
 		o(&cfg)
 	}
@@ -153,7 +335,7 @@ func NewWithClient(cfg Config, client interface {
 	if !ok {
 		return nil, errors.New("provided client does not implement required methods")
 	}
-	return &Agent{cfg: cfg, client: oc}, nil
+	return newAgent(cfg, oc), nil
 }
 
 // ChatOption allows customizing a single Chat call.
@@ -164,15 +346,38 @@ type chatParams struct {
 	temperature  float32
 	maxTokens    int
 	outputSchema string
-	// future: tools, response format, etc.
+	fields       []schema.Field
+	images       []imageInput
+	tools        []openai.Tool
+	// redactedOutput keeps redaction placeholders in the returned text.
+	redactedOutput bool
+	untrusted      string
+	hasUntrusted   bool
+	history        []openai.ChatCompletionMessage
+	guardrails     *guardrail.Policy
+	moderation     *moderation.Policy
+	budgets        []*Budget
+	callLimits     CallLimits
+	model          string // overrides Config.Model, e.g. from a profile
+	topP           float32
+	profileVars    map[string]any
+	// future: response format, etc.
 }
 
 // WithSystem sets a system prompt.
 func WithSystem(system string) ChatOption { return func(p *chatParams) { p.system = system } }
 
-// WithTemperature sets sampling temperature (0-2, typical 0-1).
+// WithTemperature sets sampling temperature (0-2, typical 0-1). 0 asks for
+// the most deterministic output.
 func WithTemperature(t float32) ChatOption { return func(p *chatParams) { p.temperature = t } }
 
+// WithTopP sets nucleus sampling (0 lets the API decide).
+func WithTopP(p float32) ChatOption { return func(cp *chatParams) { cp.topP = p } }
+
+// WithChatModel sends the call to another model or Azure deployment than
+// Config.Model.
+func WithChatModel(model string) ChatOption { return func(p *chatParams) { p.model = model } }
+
 // WithMaxTokens limits output tokens (0 lets API decide / defaults).
 func WithMaxTokens(n int) ChatOption { return func(p *chatParams) { p.maxTokens = n } }
 
@@ -183,8 +388,22 @@ func WithOutputSchema(schema string) ChatOption {
 	return func(p *chatParams) { p.outputSchema = schema }
 }
 
-// StreamHandler receives incremental tokens. Return false to stop early.
-type StreamHandler func(delta string) bool
+// WithFields asks ChatStructuredJSON for an answer shaped like fields and
+// validates it; the JSON Schema of the fields replaces WithOutputSchema.
+func WithFields(fields ...schema.Field) ChatOption {
+	return func(p *chatParams) { p.fields = fields }
+}
+
+// WithTools offers function tools to the model. Tool calls it makes are
+// returned in ChatResult.ToolCalls; run them with Agent.ExecuteTool.
+func WithTools(tools ...openai.Tool) ChatOption {
+	return func(p *chatParams) { p.tools = append(p.tools, tools...) }
+}
+
+// WithHistory sends earlier turns of a conversation before the user prompt.
+func WithHistory(msgs ...openai.ChatCompletionMessage) ChatOption {
+	return func(p *chatParams) { p.history = append(p.history, msgs...) }
+}
 
 // Simple helper to demonstrate usage from another package.
 func Example() {
@@ -209,6 +428,13 @@ type ChatResult struct {
 	Model        string                         `json:"model,omitempty"`
 	FinishReason string                         `json:"finish_reason,omitempty"`
 	Tokens       int                            `json:"tokens,omitempty"`
+	ToolCalls    []openai.ToolCall              `json:"tool_calls,omitempty"`
+	Redaction    *redact.Report                 `json:"redaction,omitempty"`  // set when Config.Redactor is used
+	Injection    *injection.Verdict             `json:"injection,omitempty"`  // set with WithUntrustedContent
+	DryRun       *DryRun                        `json:"dry_run,omitempty"`    // set instead of a response when Config.DryRun is on
+	Guardrails   *guardrail.Report              `json:"guardrails,omitempty"` // set with WithGuardrails
+	Profile      string                         `json:"profile,omitempty"`    // name@version when sent through Agent.Profile
+	Moderation   *moderation.Report             `json:"moderation,omitempty"` // set when the call is moderated
 	Raw          *openai.ChatCompletionResponse `json:"-"`
 }
 
@@ -222,51 +448,137 @@ func (a *Agent) ChatStructured(ctx context.Context, userPrompt string, opts ...C
 	for _, o := range opts {
 		o(&p)
 	}
-	msgs := make([]openai.ChatCompletionMessage, 0, 2)
+	req, redaction, verdict, err := a.prepareRequest(ctx, userPrompt, p)
+	if err != nil {
+		return empty, err
+	}
+	if a.cfg.DryRun {
+		return ChatResult{Model: req.Model, DryRun: a.dryRun(req)}, nil
+	}
+	pol, mod, err := a.moderateInput(ctx, userPrompt, p, redaction)
+	if err != nil {
+		return ChatResult{Model: req.Model, Injection: verdict, Moderation: mod}, err
+	}
+	ctx = p.budgetContext(ctx)
+	r, err := a.complete(ctx, req)
+	if err != nil {
+		// keep the input report, which may already have been flagged
+		return ChatResult{Moderation: mod}, err
+	}
+	r.Injection = verdict
+	finishRedaction(redaction, p, &r)
+	if p.guardrails != nil {
+		r, err = a.applyGuardrails(ctx, req, redaction, p, r)
+	}
+	if merr := moderateOutput(ctx, pol, mod, redaction, &r); merr != nil {
+		return r, merr
+	}
+	return r, err
+}
+
+// complete sends one chat request inside a GenAI span.
+func (a *Agent) complete(ctx context.Context, req openai.ChatCompletionRequest) (ChatResult, error) {
+	ctx, settle, err := a.reserve(ctx, &req)
+	if err != nil {
+		return ChatResult{}, err
+	}
+	ctx, o := a.startOp(ctx, semconv.GenAIOperationNameChat, req.Model, a.resolveDeployment(req.Model), requestAttrs(req)...)
+	ctx, cancel := context.WithTimeout(ctx, a.cfg.Timeout)
+	defer cancel()
+	resp, err := a.createChat(ctx, req)
+	if err == nil && len(resp.Choices) == 0 {
+		err = errors.New("empty response choices")
+	}
+	settle(resp.Usage, err)
+	if err != nil {
+		o.end(ctx, err, "", openai.Usage{})
+		return ChatResult{}, err
+	}
+	r := ChatResult{
+		Text:         resp.Choices[0].Message.Content,
+		Model:        resp.Model,
+		FinishReason: string(resp.Choices[0].FinishReason),
+		ToolCalls:    resp.Choices[0].Message.ToolCalls,
+		Raw:          &resp,
+	}
+	o.end(ctx, nil, resp.Model, resp.Usage, r.FinishReason)
+	// Usage is a struct with TotalTokens in the go-openai client
+	r.Tokens = resp.Usage.TotalTokens
+	return r, nil
+}
+
+// prepareRequest builds the request for a chat call and applies redaction and
+// untrusted-content isolation to it.
+func (a *Agent) prepareRequest(ctx context.Context, userPrompt string, p chatParams) (openai.ChatCompletionRequest, *redact.Session, *injection.Verdict, error) {
+	req, err := a.buildRequest(userPrompt, p)
+	if err != nil {
+		return req, nil, nil, err
+	}
+	redaction := a.redactRequest(&req)
+	verdict, err := a.isolateUntrusted(ctx, &req, p, redaction)
+	return req, redaction, verdict, err
+}
+
+// buildRequest turns a user prompt and resolved options into a chat completion request.
+func (a *Agent) buildRequest(userPrompt string, p chatParams) (openai.ChatCompletionRequest, error) {
+	msgs := make([]openai.ChatCompletionMessage, 0, 2+len(p.history))
 	if p.system != "" {
 		msgs = append(msgs, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: p.system})
 	}
-	msgs = append(msgs, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: userPrompt})
+	msgs = append(msgs, p.history...)
+	user := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: userPrompt}
+	if len(p.images) > 0 {
+		parts, err := buildImageParts(userPrompt, p.images)
+		if err != nil {
+			return openai.ChatCompletionRequest{}, err
+		}
+		user.Content = ""
+		user.MultiContent = parts
+	}
+	msgs = append(msgs, user)
 
 	req := openai.ChatCompletionRequest{
 		Model:       a.cfg.Model,
 		Messages:    msgs,
 		Temperature: p.temperature,
+		TopP:        p.topP,
 	}
-	if p.maxTokens > 0 {
-		req.MaxTokens = p.maxTokens
-	}
-	ctx, cancel := context.WithTimeout(ctx, a.cfg.Timeout)
-	defer cancel()
-	resp, err := a.client.CreateChatCompletion(ctx, req)
-	if err != nil {
-		return empty, err
+	if req.Temperature == 0 {
+		// go-openai omits a zero temperature and the API would use its default
+		req.Temperature = math.SmallestNonzeroFloat32
 	}
-	if len(resp.Choices) == 0 {
-		return empty, errors.New("empty response choices")
+	if p.model != "" {
+		req.Model = p.model
 	}
-	r := ChatResult{
-		Text:  resp.Choices[0].Message.Content,
-		Model: resp.Model,
-		Raw:   &resp,
+	if p.maxTokens > 0 {
+		req.MaxTokens = p.maxTokens
 	}
-	if len(resp.Choices) > 0 {
-		r.FinishReason = string(resp.Choices[0].FinishReason)
+	if len(p.tools) > 0 {
+		req.Tools = p.tools
 	}
-	// Usage is a struct with TotalTokens in the go-openai client
-	r.Tokens = resp.Usage.TotalTokens
-	return r, nil
+	return req, nil
 }
 
 // ChatStructuredJSON calls ChatStructured but also attempts to parse the returned text
 // as JSON into an interface{}. It respects the WithOutputSchema option which injects
 // a system instruction asking the model to respond in the requested structured format.
+// A system prompt set with WithSystem is kept and the schema instruction follows it,
+// so profiles and callers can set a persona without losing the format.
+// With WithFields the parsed answer is also validated; a *schema.ValidationError is
+// returned together with the answer. In dry-run mode the parsed value is nil and ChatResult.DryRun holds the request.
 func (a *Agent) ChatStructuredJSON(ctx context.Context, userPrompt string, opts ...ChatOption) (ChatResult, interface{}, error) {
 	// detect schema option
 	var p chatParams
 	for _, o := range opts {
 		o(&p)
 	}
+	if p.fields != nil {
+		js, err := schema.JSONSchema(p.fields)
+		if err != nil {
+			return ChatResult{}, nil, fmt.Errorf("invalid fields: %w", err)
+		}
+		p.outputSchema = js
+	}
 	if p.outputSchema != "" {
 		// validate schema is valid JSON
 		var tmp interface{}
@@ -275,21 +587,32 @@ func (a *Agent) ChatStructuredJSON(ctx context.Context, userPrompt string, opts
 		}
 		// generate a stricter system prompt that includes the schema and an example
 		sys := generateSystemPromptFromJSONSchema(p.outputSchema)
-		// prepend system instruction
+		// keep a caller's system prompt ahead of the schema instruction
+		if p.system != "" {
+			sys = p.system + "\n\n" + sys
+		}
 		newOpts := make([]ChatOption, 0, len(opts)+1)
-		newOpts = append(newOpts, WithSystem(sys))
 		newOpts = append(newOpts, opts...)
+		newOpts = append(newOpts, WithSystem(sys))
 		opts = newOpts
 	}
 
 	res, err := a.ChatStructured(ctx, userPrompt, opts...)
 	if err != nil {
-		return ChatResult{}, nil, err
+		// res carries the guardrail report when the guardrails failed
+		return res, nil, err
+	}
+	if res.DryRun != nil {
+		return res, nil, nil
 	}
 	var parsed interface{}
 	if err := json.Unmarshal([]byte(res.Text), &parsed); err != nil {
 		return res, nil, err
 	}
+	if p.fields != nil {
+		// the answer is returned with the error so callers can log it
+		return res, parsed, schema.Validate(parsed, p.fields)
+	}
 	return res, parsed, nil
 }
 
@@ -312,227 +635,28 @@ func generateSystemPromptFromJSONSchema(schema string) string {
 	return b.String()
 }
 
-// buildExampleFromSchema attempts a tiny, best-effort example JSON from a minimal schema.
-func buildExampleFromSchema(schema string) string {
-	var s map[string]interface{}
-	if err := json.Unmarshal([]byte(schema), &s); err != nil {
-		return ""
-	}
-	// walk top-level properties if available
-	props, ok := s["properties"].(map[string]interface{})
-	if !ok {
-		// fallback: return the raw schema as example
+// buildExampleFromSchema builds an example answer from a JSON Schema, or ""
+// when the schema is not an object schema.
+func buildExampleFromSchema(js string) string {
+	fields, err := schema.FromJSONSchema(js)
+	if err != nil {
 		return ""
 	}
-	example := map[string]interface{}{}
-	for k, v := range props {
-		if mv, ok := v.(map[string]interface{}); ok {
-			if t, ok := mv["type"].(string); ok {
-				switch t {
-				case "string":
-					example[k] = "example"
-				case "number", "integer":
-					example[k] = 0
-				case "boolean":
-					example[k] = true
-				case "object":
-					example[k] = map[string]interface{}{}
-				case "array":
-					// try to get items type
-					if items, ok := mv["items"].(map[string]interface{}); ok {
-						if it, ok := items["type"].(string); ok {
-							switch it {
-							case "string":
-								example[k] = []interface{}{"example"}
-							case "number", "integer":
-								example[k] = []interface{}{0}
-							case "boolean":
-								example[k] = []interface{}{true}
-							default:
-								example[k] = []interface{}{}
-							}
-						} else {
-							example[k] = []interface{}{}
-						}
-					} else {
-						example[k] = []interface{}{}
-					}
-				default:
-					example[k] = nil
-				}
-			} else {
-				example[k] = nil
-			}
-		} else {
-			example[k] = nil
-		}
-	}
-	if out, err := json.Marshal(example); err == nil {
-		return string(out)
-	}
-	return ""
+	return schema.ExampleJSON(fields)
 }
 
-// SchemaFromFields builds a minimal JSON Schema (as a string) from a map of property names -> JSON types.
+// SchemaFromFields builds a JSON Schema (as a string) from a map of property
+// names to types, using the shorthand of schema.FromShorthand.
 // Example:
 //
-//	props := map[string]string{"name":"string", "age":"integer"}
+//	props := map[string]string{"name":"string", "age":"integer", "address.city":"text=Bangkok"}
 //	schema, _ := SchemaFromFields(props, []string{"name"})
 func SchemaFromFields(props map[string]string, required []string) (string, error) {
-	// Build nested schema supporting dot-notation keys
-	schema := map[string]interface{}{
-		"type":       "object",
-		"properties": map[string]interface{}{},
-	}
-	// helper set for required full paths
-	reqSet := map[string]struct{}{}
-	for _, r := range required {
-		reqSet[r] = struct{}{}
-	}
-
-	for key, spec := range props {
-		parts := strings.Split(key, ".")
-		// parse spec for shorthand and default: e.g., "text=hello"
-		typ, def := parseTypeAndDefault(spec)
-		typ = normalizeType(typ)
-
-		// navigate/create nested objects
-		cur := schema
-		for i, part := range parts {
-			propsMap := cur["properties"].(map[string]interface{})
-			if i == len(parts)-1 {
-				// set property at this level
-				prop := map[string]interface{}{"type": typ}
-				if def != nil {
-					prop["default"] = def
-				}
-				propsMap[part] = prop
-				// if this full key is required, add to parent.required
-				fullKey := strings.Join(parts[:i+1], ".")
-				if _, ok := reqSet[fullKey]; ok {
-					addRequiredToObject(cur, part)
-				}
-			} else {
-				// ensure object exists
-				if existing, ok := propsMap[part]; ok {
-					// existing may be map[string]interface{}
-					if em, ok := existing.(map[string]interface{}); ok {
-						// ensure it has properties
-						if _, has := em["properties"]; !has {
-							em["type"] = "object"
-							em["properties"] = map[string]interface{}{}
-						}
-
-						cur = em
-						continue
-					}
-				}
-				// create new object
-				newObj := map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
-				propsMap[part] = newObj
-
-				cur = newObj
-			}
-		}
-	}
-
-	// attach top-level required if any top-level keys were requested
-	if len(required) > 0 {
-		// gather top-level required (those without dot)
-		topReq := []string{}
-		for _, r := range required {
-			if !strings.Contains(r, ".") {
-				topReq = append(topReq, r)
-			}
-		}
-		if len(topReq) > 0 {
-			schema["required"] = topReq
-		}
-	}
-
-	b, err := json.Marshal(schema)
+	fields, err := schema.FromShorthand(props, required)
 	if err != nil {
 		return "", err
 	}
-	return string(b), nil
-}
-
-// parseTypeAndDefault parses a spec like "text=hello" into type and default value (as interface{}).
-func parseTypeAndDefault(spec string) (string, interface{}) {
-	parts := strings.SplitN(spec, "=", 2)
-	typ := strings.TrimSpace(parts[0])
-	if typ == "" {
-		typ = "string"
-	}
-	if len(parts) == 1 {
-		return typ, nil
-	}
-	defStr := strings.TrimSpace(parts[1])
-	// try to convert default according to type
-	switch strings.ToLower(typ) {
-	case "integer", "int":
-		if v, err := strconv.Atoi(defStr); err == nil {
-			return typ, v
-		}
-	case "number", "float":
-		if v, err := strconv.ParseFloat(defStr, 64); err == nil {
-			return typ, v
-		}
-	case "boolean", "bool":
-		if v, err := strconv.ParseBool(defStr); err == nil {
-			return typ, v
-		}
-	}
-	// fallback keep as string
-	return typ, defStr
-}
-
-// normalizeType maps shorthand types to JSON Schema types
-func normalizeType(t string) string {
-	switch strings.ToLower(t) {
-	case "text", "string":
-		return "string"
-	case "int", "integer":
-		return "integer"
-	case "float", "number":
-		return "number"
-	case "bool", "boolean":
-		return "boolean"
-	case "object":
-		return "object"
-	case "array":
-		return "array"
-	default:
-		return t
-	}
-}
-
-// addRequiredToObject ensures the object map has a required array and appends the field
-func addRequiredToObject(obj map[string]interface{}, field string) {
-	if obj == nil {
-		return
-	}
-	if _, ok := obj["required"]; !ok {
-		obj["required"] = []string{field}
-		return
-	}
-	if arr, ok := obj["required"].([]string); ok {
-		obj["required"] = append(arr, field)
-		obj["required"] = arr
-		return
-	}
-	// if it's []interface{}, convert
-	if arr2, ok := obj["required"].([]interface{}); ok {
-		strs := make([]string, 0, len(arr2)+1)
-		for _, v := range arr2 {
-			if s, ok := v.(string); ok {
-				strs = append(strs, s)
-			}
-		}
-		strs = append(strs, field)
-		obj["required"] = strs
-		return
-	}
+	return schema.JSONSchema(fields)
 }
 
 // SchemaFromMap is a convenience wrapper when you don't need required fields.
@@ -2,8 +2,13 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 
+	"go-azure-openai/internal/service/schema"
+
 	openai "github.com/sashabaranov/go-openai"
 )
 
@@ -11,9 +16,11 @@ import (
 type fakeClient struct {
 	resp openai.ChatCompletionResponse
 	err  error
+	reqs []openai.ChatCompletionRequest
 }
 
 func (f *fakeClient) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	f.reqs = append(f.reqs, req)
 	return f.resp, f.err
 }
 
@@ -81,3 +88,63 @@ func TestChatStructuredJSON_Parse(t *testing.T) {
 		t.Fatalf("unexpected greeting: %v", m["greeting"])
 	}
 }
+
+func TestChatStructuredJSON_SystemPromptOrder(t *testing.T) {
+	f := &fakeClient{resp: openai.ChatCompletionResponse{Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: `{}`}}}}}
+	a := &Agent{cfg: Config{Model: "gpt-test"}, client: f}
+	schemaOpt := WithOutputSchema(`{"type":"object","properties":{"band":{"type":"number"}}}`)
+	schemaPrompt := generateSystemPromptFromJSONSchema(`{"type":"object","properties":{"band":{"type":"number"}}}`)
+
+	// the caller's system prompt comes first and no longer replaces the schema
+	// instruction, whichever order the options are given in
+	for _, opts := range [][]ChatOption{{WithSystem("You are an IELTS examiner."), schemaOpt}, {schemaOpt, WithSystem("You are an IELTS examiner.")}} {
+		f.reqs = nil
+		if _, _, err := a.ChatStructuredJSON(context.Background(), "grade", opts...); err != nil {
+			t.Fatal(err)
+		}
+		if sys := f.reqs[0].Messages[0].Content; sys != "You are an IELTS examiner.\n\n"+schemaPrompt {
+			t.Fatalf("unexpected system prompt %q", sys)
+		}
+	}
+}
+
+func TestChatStructured_ZeroTemperatureIsSent(t *testing.T) {
+	f := &fakeClient{resp: openai.ChatCompletionResponse{Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "ok"}}}}}
+	a := &Agent{cfg: Config{Model: "gpt-test"}, client: f}
+	if _, err := a.ChatStructured(context.Background(), "hi", WithTemperature(0)); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(f.reqs[0])
+	if !strings.Contains(string(body), `"temperature":`) || f.reqs[0].Temperature > 1e-6 {
+		t.Fatalf("zero temperature dropped from the request: %s", body)
+	}
+}
+
+func TestChatStructuredJSON_WithFields(t *testing.T) {
+	f := &fakeClient{resp: openai.ChatCompletionResponse{
+		Model:   "gpt-test",
+		Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: `{"score": 4.5, "level": "B1"}`}}},
+	}}
+	a := &Agent{cfg: Config{Model: "gpt-test"}, client: f}
+	_, parsed, err := a.ChatStructuredJSON(context.Background(), "grade",
+		WithFields(schema.Field{Name: "score", Type: schema.Integer}, schema.Field{Name: "level", Type: schema.String, Enum: []any{"A2", "B1", "B2"}}))
+	var verr *schema.ValidationError
+	if !errors.As(err, &verr) || verr.Path != "score" || parsed == nil {
+		t.Fatalf("expected validation error with the answer, got %v %v", parsed, err)
+	}
+	sys := f.reqs[0].Messages[0].Content
+	if !strings.Contains(sys, `"enum":["A2","B1","B2"]`) || !strings.Contains(sys, `Example output:`+"\n"+`{"level":"A2","score":0}`) {
+		t.Fatalf("expected schema and example in the system prompt, got %q", sys)
+	}
+}
+
+func TestSchemaFromFields_NestedRequired(t *testing.T) {
+	s, err := SchemaFromFields(map[string]string{"name": "text", "address.city": "string", "address.zip": "string"}, []string{"name", "address.city", "address.zip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"object","properties":{"address":{"type":"object","properties":{"city":{"type":"string"},"zip":{"type":"string"}},"required":["city","zip"]},"name":{"type":"string"}},"required":["name"]}`
+	if s != want {
+		t.Fatalf("unexpected schema\n%s\n%s", s, want)
+	}
+}
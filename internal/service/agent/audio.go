@@ -0,0 +1,371 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// maxAudioUploadBytes is the Whisper API upload limit (25 MB).
+const maxAudioUploadBytes = 25 << 20
+
+// audioClient is implemented by clients that support the Whisper audio endpoints.
+type audioClient interface {
+	CreateTranscription(ctx context.Context, req openai.AudioRequest) (openai.AudioResponse, error)
+	CreateTranslation(ctx context.Context, req openai.AudioRequest) (openai.AudioResponse, error)
+}
+
+// AudioFormat selects the transcript output format.
+type AudioFormat string
+
+const (
+	AudioFormatJSON        AudioFormat = "json"
+	AudioFormatText        AudioFormat = "text"
+	AudioFormatSRT         AudioFormat = "srt"
+	AudioFormatVTT         AudioFormat = "vtt"
+	AudioFormatVerboseJSON AudioFormat = "verbose_json"
+)
+
+// AudioOption allows customizing a single Transcribe or Translate call.
+type AudioOption func(*audioParams)
+
+type audioParams struct {
+	format      AudioFormat
+	language    string
+	prompt      string
+	temperature float32
+	words       bool
+	segments    bool
+	chunkBytes  int
+}
+
+// WithAudioFormat sets the output format (default json).
+func WithAudioFormat(f AudioFormat) AudioOption { return func(p *audioParams) { p.format = f } }
+
+// WithLanguage hints the spoken language as an ISO-639-1 code (e.g. "en", "th").
+// Translation ignores it.
+func WithLanguage(lang string) AudioOption { return func(p *audioParams) { p.language = lang } }
+
+// WithAudioPrompt gives Whisper context such as names or vocabulary to expect.
+func WithAudioPrompt(prompt string) AudioOption { return func(p *audioParams) { p.prompt = prompt } }
+
+// WithAudioTemperature sets the sampling temperature.
+func WithAudioTemperature(t float32) AudioOption { return func(p *audioParams) { p.temperature = t } }
+
+// WithWordTimestamps requests per-word timings (implies verbose_json).
+func WithWordTimestamps() AudioOption { return func(p *audioParams) { p.words = true } }
+
+// WithSegmentTimestamps requests per-segment timings (implies verbose_json).
+func WithSegmentTimestamps() AudioOption { return func(p *audioParams) { p.segments = true } }
+
+// WithChunkSize overrides the upload size above which files are split (default 25 MB).
+func WithChunkSize(n int) AudioOption { return func(p *audioParams) { p.chunkBytes = n } }
+
+// Segment is a timed span of a transcript, in seconds from the start of the file.
+type Segment struct {
+	ID    int     `json:"id"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// Word is a single timed word, in seconds from the start of the file.
+type Word struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// Transcript is the result of Transcribe or Translate. For srt and vtt formats
+// Text holds the subtitle document; otherwise it holds the plain transcript.
+type Transcript struct {
+	Text     string      `json:"text"`
+	Format   AudioFormat `json:"format"`
+	Language string      `json:"language,omitempty"`
+	Duration float64     `json:"duration,omitempty"`
+	Segments []Segment   `json:"segments,omitempty"`
+	Words    []Word      `json:"words,omitempty"`
+	Chunks   int         `json:"chunks"`
+}
+
+// Transcribe converts speech in the audio file at path to text in the spoken
+// language using Config.WhisperDeployment. WAV and MP3 files larger than the
+// upload limit are split and the results merged with timestamps offset per
+// chunk; larger files in other formats are rejected.
+func (a *Agent) Transcribe(ctx context.Context, path string, opts ...AudioOption) (Transcript, error) {
+	return a.callAudio(ctx, path, false, opts)
+}
+
+// Translate converts speech in the audio file at path to English text.
+func (a *Agent) Translate(ctx context.Context, path string, opts ...AudioOption) (Transcript, error) {
+	return a.callAudio(ctx, path, true, opts)
+}
+
+func (a *Agent) callAudio(ctx context.Context, path string, translate bool, opts []AudioOption) (_ Transcript, err error) {
+	var empty Transcript
+	if a == nil || a.client == nil {
+		return empty, errors.New("agent not initialized")
+	}
+	ac, ok := a.client.(audioClient)
+	if !ok {
+		return empty, errors.New("client does not support audio")
+	}
+	if a.cfg.WhisperDeployment == "" {
+		return empty, errors.New("missing whisper deployment (set AZURE_OPENAI_WHISPER_DEPLOYMENT)")
+	}
+	p := audioParams{format: AudioFormatJSON, chunkBytes: maxAudioUploadBytes}
+	for _, o := range opts {
+		o(&p)
+	}
+	if p.words || p.segments {
+		p.format = AudioFormatVerboseJSON
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return empty, fmt.Errorf("read audio: %w", err)
+	}
+	chunks, err := splitAudio(data, p.chunkBytes)
+	if err != nil {
+		return empty, err
+	}
+
+	call := ac.CreateTranscription
+	if translate {
+		call = ac.CreateTranslation
+		p.language = ""
+	}
+	// A single upload can use the requested format directly. Chunked uploads need
+	// verbose_json so timestamps can be offset; the final format is rendered locally.
+	wire := p.format
+	if len(chunks) > 1 {
+		wire = AudioFormatVerboseJSON
+	}
+	req := openai.AudioRequest{
+		Model:       a.cfg.WhisperDeployment,
+		Prompt:      p.prompt,
+		Temperature: p.temperature,
+		Language:    p.language,
+		Format:      openai.AudioResponseFormat(wire),
+	}
+	if p.words {
+		req.TimestampGranularities = append(req.TimestampGranularities, openai.TranscriptionTimestampGranularityWord)
+	}
+	if p.segments || (len(chunks) > 1 && (p.format == AudioFormatSRT || p.format == AudioFormatVTT)) {
+		req.TimestampGranularities = append(req.TimestampGranularities, openai.TranscriptionTimestampGranularitySegment)
+	}
+
+	operation := opTranscription
+	if translate {
+		operation = opTranslation
+	}
+	ctx, o := a.startOp(ctx, operation, a.cfg.WhisperDeployment, a.cfg.WhisperDeployment)
+	defer func(ctx context.Context) { o.end(ctx, err, "", openai.Usage{}) }(ctx)
+
+	out := Transcript{Format: p.format, Chunks: len(chunks)}
+	texts := make([]string, 0, len(chunks))
+	var offset float64
+	for i, c := range chunks {
+		req.FilePath = chunkName(path, i, len(chunks))
+		req.Reader = bytes.NewReader(c.data)
+		if i > 0 && !translate {
+			// Carry the tail of the previous chunk so Whisper keeps context across the cut.
+			req.Prompt = strings.TrimSpace(p.prompt + " " + tail(texts[i-1], 200))
+		}
+		settle, err := a.reserveCall(ctx)
+		if err != nil {
+			return empty, err
+		}
+		cctx, cancel := context.WithTimeout(ctx, a.cfg.Timeout)
+		var resp openai.AudioResponse
+		err = a.guard(a.cfg.WhisperDeployment, func() (err error) {
+			resp, err = call(cctx, req)
+			return err
+		})
+		cancel()
+		settle(0)
+		if err != nil {
+			return empty, fmt.Errorf("audio chunk %d/%d: %w", i+1, len(chunks), err)
+		}
+		texts = append(texts, strings.TrimSpace(resp.Text))
+		if out.Language == "" {
+			out.Language = resp.Language
+		}
+		for _, s := range resp.Segments {
+			out.Segments = append(out.Segments, Segment{ID: len(out.Segments), Start: s.Start + offset, End: s.End + offset, Text: strings.TrimSpace(s.Text)})
+		}
+		for _, w := range resp.Words {
+			out.Words = append(out.Words, Word{Word: w.Word, Start: w.Start + offset, End: w.End + offset})
+		}
+		d := c.duration
+		if d == 0 {
+			d = resp.Duration
+		}
+		offset += d
+	}
+	out.Duration = offset
+
+	switch {
+	case len(chunks) == 1:
+		out.Text = texts[0]
+	case p.format == AudioFormatSRT:
+		out.Text = RenderSRT(out.Segments)
+	case p.format == AudioFormatVTT:
+		out.Text = RenderVTT(out.Segments)
+	default:
+		out.Text = strings.Join(texts, " ")
+	}
+	return out, nil
+}
+
+// RenderSRT formats segments as a SubRip subtitle document.
+func RenderSRT(segs []Segment) string {
+	var b strings.Builder
+	for i, s := range segs {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1, subtitleTime(s.Start, ","), subtitleTime(s.End, ","), s.Text)
+	}
+	return b.String()
+}
+
+// RenderVTT formats segments as a WebVTT subtitle document.
+func RenderVTT(segs []Segment) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for _, s := range segs {
+		fmt.Fprintf(&b, "%s --> %s\n%s\n\n", subtitleTime(s.Start, "."), subtitleTime(s.End, "."), s.Text)
+	}
+	return b.String()
+}
+
+func subtitleTime(sec float64, msSep string) string {
+	ms := int64(sec*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, msSep, ms%1000)
+}
+
+func tail(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[len(r)-n:])
+}
+
+// chunkName keeps the original extension, which the API uses to detect the format.
+func chunkName(path string, i, n int) string {
+	base := filepath.Base(path)
+	if n == 1 {
+		return base
+	}
+	ext := filepath.Ext(base)
+	return fmt.Sprintf("%s.part%d%s", strings.TrimSuffix(base, ext), i+1, ext)
+}
+
+// audioChunk is one upload. duration is known exactly for WAV chunks and 0 otherwise.
+type audioChunk struct {
+	data     []byte
+	duration float64
+}
+
+// splitAudio splits data into uploads of at most limit bytes. WAV files are cut
+// on sample boundaries with a fresh header per chunk and MP3 files at frame
+// headers. Other containers (m4a, mp4, webm, ogg, flac) keep their header only
+// in the first bytes, so they cannot be split and must be converted first.
+func splitAudio(data []byte, limit int) ([]audioChunk, error) {
+	if limit <= 0 || len(data) <= limit {
+		return []audioChunk{{data: data}}, nil
+	}
+	if w, ok := parseWAV(data); ok {
+		return w.split(limit)
+	}
+	if !isMP3(data) {
+		return nil, fmt.Errorf("audio file is %d bytes, over the %d byte upload limit; convert it to WAV or MP3 so it can be split", len(data), limit)
+	}
+	var chunks []audioChunk
+	for start := 0; start < len(data); {
+		end := min(start+limit, len(data))
+		if end < len(data) {
+			// end the chunk before the last frame header in it
+			for i := end - 1; i > start; i-- {
+				if isFrameSync(data[i], data[i+1]) {
+					end = i
+					break
+				}
+			}
+		}
+		chunks = append(chunks, audioChunk{data: data[start:end]})
+		start = end
+	}
+	return chunks, nil
+}
+
+// isMP3 reports whether data starts with an ID3 tag or an MPEG audio frame.
+func isMP3(data []byte) bool {
+	return len(data) >= 3 && string(data[:3]) == "ID3" || len(data) >= 2 && isFrameSync(data[0], data[1])
+}
+
+// isFrameSync reports whether b0 and b1 begin an MPEG audio frame header:
+// 11 sync bits followed by a known version and layer.
+func isFrameSync(b0, b1 byte) bool {
+	return b0 == 0xFF && b1&0xE0 == 0xE0 && b1&0x18 != 0x08 && b1&0x06 != 0
+}
+
+type wavFile struct {
+	fmtChunk []byte // body of the "fmt " chunk
+	samples  []byte // body of the "data" chunk
+}
+
+func parseWAV(data []byte) (wavFile, bool) {
+	var w wavFile
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return w, false
+	}
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		body := pos + 8
+		end := min(body+size, len(data))
+		switch id {
+		case "fmt ":
+			w.fmtChunk = data[body:end]
+		case "data":
+			w.samples = data[body:end]
+		}
+		pos = end + size%2 // chunks are word aligned
+	}
+	return w, len(w.fmtChunk) >= 16 && w.samples != nil
+}
+
+func (w wavFile) split(limit int) ([]audioChunk, error) {
+	byteRate := int(binary.LittleEndian.Uint32(w.fmtChunk[8:12]))
+	blockAlign := int(binary.LittleEndian.Uint16(w.fmtChunk[12:14]))
+	header := 12 + 8 + len(w.fmtChunk) + 8
+	per := limit - header
+	if blockAlign > 0 {
+		per -= per % blockAlign
+	}
+	if per <= 0 || byteRate == 0 {
+		return nil, fmt.Errorf("chunk size %d too small for wav audio", limit)
+	}
+	var chunks []audioChunk
+	for start := 0; start < len(w.samples); start += per {
+		body := w.samples[start:min(start+per, len(w.samples))]
+		var b bytes.Buffer
+		b.Grow(header + len(body))
+		b.WriteString("RIFF")
+		binary.Write(&b, binary.LittleEndian, uint32(header-8+len(body)))
+		b.WriteString("WAVEfmt ")
+		binary.Write(&b, binary.LittleEndian, uint32(len(w.fmtChunk)))
+		b.Write(w.fmtChunk)
+		b.WriteString("data")
+		binary.Write(&b, binary.LittleEndian, uint32(len(body)))
+		b.Write(body)
+		chunks = append(chunks, audioChunk{data: b.Bytes(), duration: float64(len(body)) / float64(byteRate)})
+	}
+	return chunks, nil
+}
@@ -0,0 +1,147 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// fakeAudioClient adds Whisper support to fakeClient. Every upload returns one
+// segment spanning the whole chunk.
+type fakeAudioClient struct {
+	fakeClient
+	reqs  []openai.AudioRequest
+	sizes []int
+}
+
+func (f *fakeAudioClient) CreateTranscription(ctx context.Context, req openai.AudioRequest) (openai.AudioResponse, error) {
+	b, _ := io.ReadAll(req.Reader)
+	f.reqs = append(f.reqs, req)
+	f.sizes = append(f.sizes, len(b))
+	var resp openai.AudioResponse
+	resp.Text = "part" + string(rune('1'+len(f.reqs)-1))
+	resp.Language = "english"
+	resp.Segments = append(resp.Segments, struct {
+		ID               int     `json:"id"`
+		Seek             int     `json:"seek"`
+		Start            float64 `json:"start"`
+		End              float64 `json:"end"`
+		Text             string  `json:"text"`
+		Tokens           []int   `json:"tokens"`
+		Temperature      float64 `json:"temperature"`
+		AvgLogprob       float64 `json:"avg_logprob"`
+		CompressionRatio float64 `json:"compression_ratio"`
+		NoSpeechProb     float64 `json:"no_speech_prob"`
+		Transient        bool    `json:"transient"`
+	}{Start: 0, End: 0.5, Text: resp.Text})
+	return resp, nil
+}
+
+func (f *fakeAudioClient) CreateTranslation(ctx context.Context, req openai.AudioRequest) (openai.AudioResponse, error) {
+	return f.CreateTranscription(ctx, req)
+}
+
+// writeWAV writes a mono 16-bit wav of the given number of seconds at 1 kHz.
+func writeWAV(t *testing.T, seconds int) string {
+	t.Helper()
+	samples := make([]byte, seconds*2000)
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(36+len(samples)))
+	b.WriteString("WAVEfmt ")
+	for _, v := range []any{uint32(16), uint16(1), uint16(1), uint32(1000), uint32(2000), uint16(2), uint16(16)} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(len(samples)))
+	b.Write(samples)
+	path := filepath.Join(t.TempDir(), "answer.wav")
+	if err := os.WriteFile(path, b.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTranscribe_SingleUpload(t *testing.T) {
+	f := &fakeAudioClient{}
+	a := &Agent{cfg: Config{Model: "gpt-test", WhisperDeployment: "whisper"}, client: f}
+	res, err := a.Transcribe(context.Background(), writeWAV(t, 1), WithLanguage("en"), WithAudioFormat(AudioFormatText))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(f.reqs) != 1 || f.reqs[0].Format != openai.AudioResponseFormatText || f.reqs[0].Language != "en" {
+		t.Fatalf("unexpected request: %+v", f.reqs)
+	}
+	if res.Text != "part1" || res.Chunks != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestTranscribe_ChunkedWAV(t *testing.T) {
+	f := &fakeAudioClient{}
+	a := &Agent{cfg: Config{Model: "gpt-test", WhisperDeployment: "whisper"}, client: f}
+	// 3 seconds = 6000 bytes of samples; 2044-byte chunks hold exactly 1 second each.
+	res, err := a.Transcribe(context.Background(), writeWAV(t, 3), WithChunkSize(2044), WithAudioFormat(AudioFormatSRT))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if res.Chunks != 3 || len(f.reqs) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", res.Chunks)
+	}
+	for i, r := range f.reqs {
+		if r.Format != openai.AudioResponseFormatVerboseJSON {
+			t.Fatalf("chunk %d should request verbose_json, got %s", i, r.Format)
+		}
+		if f.sizes[i] > 2044 {
+			t.Fatalf("chunk %d too large: %d", i, f.sizes[i])
+		}
+	}
+	if !strings.Contains(f.reqs[1].Prompt, "part1") {
+		t.Fatalf("expected previous text as prompt, got %q", f.reqs[1].Prompt)
+	}
+	if res.Duration != 3 || res.Segments[2].Start != 2 {
+		t.Fatalf("segments not offset: %+v", res.Segments)
+	}
+	if !strings.Contains(res.Text, "3\n00:00:02,000 --> 00:00:02,500\npart3") {
+		t.Fatalf("unexpected srt:\n%s", res.Text)
+	}
+}
+
+func TestSplitAudio_MP3AtFramesOtherFormatsRejected(t *testing.T) {
+	// ten 100-byte frames behind an ID3 tag
+	data := []byte("ID3\x04\x00\x00\x00\x00\x00\x00")
+	for range 10 {
+		frame := make([]byte, 100)
+		frame[0], frame[1] = 0xFF, 0xFB
+		data = append(data, frame...)
+	}
+	chunks, err := splitAudio(data, 250)
+	if err != nil || len(chunks) != 5 {
+		t.Fatalf("expected 5 chunks, got %d: %v", len(chunks), err)
+	}
+	for i, c := range chunks[1:] {
+		if len(c.data) > 250 || !isFrameSync(c.data[0], c.data[1]) {
+			t.Fatalf("chunk %d does not start on a frame: % x", i+1, c.data[:2])
+		}
+	}
+
+	m4a := append([]byte("\x00\x00\x00\x20ftypM4A "), make([]byte, 500)...)
+	if _, err := splitAudio(m4a, 250); err == nil || !strings.Contains(err.Error(), "convert it to WAV or MP3") {
+		t.Fatalf("expected a too-large error for m4a, got %v", err)
+	}
+}
+
+func TestRenderVTT(t *testing.T) {
+	got := RenderVTT([]Segment{{Start: 61.25, End: 3723.5, Text: "hello"}})
+	want := "WEBVTT\n\n00:01:01.250 --> 01:02:03.500\nhello\n\n"
+	if got != want {
+		t.Fatalf("unexpected vtt: %q", got)
+	}
+}
@@ -0,0 +1,311 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// BreakerState is the state of a CircuitBreaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets all calls through and records their outcome.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects calls immediately with a *CircuitOpenError.
+	BreakerOpen
+	// BreakerHalfOpen lets a few trial calls through to probe recovery.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// BreakerConfig configures a CircuitBreaker. Zero values use the defaults noted.
+type BreakerConfig struct {
+	WindowSize       int           // recent calls considered for the failure rate (default 20)
+	MinRequests      int           // calls needed in the window before the breaker may trip (default 10)
+	FailureRate      float64       // failure ratio 0..1 that opens the breaker (default 0.5)
+	OpenTimeout      time.Duration // time spent open before probing (default 30s)
+	HalfOpenMaxCalls int           // concurrent trial calls while half-open (default 1)
+	// OnStateChange is called on every transition, for example to report "AI
+	// grading degraded" on a status page. It runs on the goroutine that caused
+	// the transition, after the breaker is unlocked, so it may call State but
+	// should not block.
+	OnStateChange func(BreakerEvent)
+}
+
+func (c BreakerConfig) withDefaults() BreakerConfig {
+	if c.WindowSize <= 0 {
+		c.WindowSize = 20
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = 10
+	}
+	if c.MinRequests > c.WindowSize {
+		c.MinRequests = c.WindowSize
+	}
+	if c.FailureRate <= 0 || c.FailureRate > 1 {
+		c.FailureRate = 0.5
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = 30 * time.Second
+	}
+	if c.HalfOpenMaxCalls <= 0 {
+		c.HalfOpenMaxCalls = 1
+	}
+	return c
+}
+
+// BreakerEvent describes a state transition.
+type BreakerEvent struct {
+	Backend     string // endpoint/deployment
+	From, To    BreakerState
+	FailureRate float64 // failure ratio in the window at the time of the transition
+	At          time.Time
+}
+
+// ErrCircuitOpen is matched by errors.Is for every *CircuitOpenError.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitOpenError is returned without calling the backend while the breaker is open.
+type CircuitOpenError struct {
+	Backend    string
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker open for %s (retry after %s)", e.Backend, e.RetryAfter.Round(time.Second))
+}
+
+// Is reports whether target is ErrCircuitOpen.
+func (e *CircuitOpenError) Is(target error) bool { return target == ErrCircuitOpen }
+
+// CircuitBreaker tracks the health of one backend (an endpoint and
+// deployment) over a sliding window of recent calls. It is safe for
+// concurrent use.
+type CircuitBreaker struct {
+	backend string
+	cfg     BreakerConfig
+	now     func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	window   []bool // ring buffer, true = failure
+	next     int
+	filled   int
+	failures int
+	openedAt time.Time
+	inflight int // trial calls while half-open
+}
+
+// NewCircuitBreaker creates a closed breaker for the named backend.
+func NewCircuitBreaker(backend string, cfg BreakerConfig) *CircuitBreaker {
+	cfg = cfg.withDefaults()
+	return &CircuitBreaker{backend: backend, cfg: cfg, now: time.Now, window: make([]bool, cfg.WindowSize)}
+}
+
+// Backend returns the backend name the breaker guards.
+func (b *CircuitBreaker) Backend() string { return b.backend }
+
+// State returns the current state, moving from open to half-open if the open timeout passed.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	ev := b.maybeHalfOpen()
+	s := b.state
+	b.mu.Unlock()
+	b.notify(ev)
+	return s
+}
+
+// Allow asks to make a call. On success the caller must report the outcome
+// through done; otherwise a *CircuitOpenError is returned.
+func (b *CircuitBreaker) Allow() (done func(err error), err error) {
+	var ev *BreakerEvent
+	defer func() { b.notify(ev) }()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ev = b.maybeHalfOpen()
+	switch b.state {
+	case BreakerOpen:
+		return nil, &CircuitOpenError{Backend: b.backend, RetryAfter: b.cfg.OpenTimeout - b.now().Sub(b.openedAt)}
+	case BreakerHalfOpen:
+		if b.inflight >= b.cfg.HalfOpenMaxCalls {
+			return nil, &CircuitOpenError{Backend: b.backend}
+		}
+		b.inflight++
+		return func(err error) { b.finishTrial(err) }, nil
+	}
+	return func(err error) { b.record(err) }, nil
+}
+
+func (b *CircuitBreaker) maybeHalfOpen() *BreakerEvent {
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		return b.transition(BreakerHalfOpen)
+	}
+	return nil
+}
+
+func (b *CircuitBreaker) record(err error) {
+	failed, counted := classifyBackendError(err)
+	if !counted {
+		return
+	}
+	var ev *BreakerEvent
+	defer func() { b.notify(ev) }()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != BreakerClosed {
+		return // a late result from before the breaker opened
+	}
+	if b.filled == len(b.window) && b.window[b.next] {
+		b.failures--
+	}
+	b.window[b.next] = failed
+	if failed {
+		b.failures++
+	}
+	b.next = (b.next + 1) % len(b.window)
+	if b.filled < len(b.window) {
+		b.filled++
+	}
+	if b.filled >= b.cfg.MinRequests && b.rate() >= b.cfg.FailureRate {
+		ev = b.transition(BreakerOpen)
+	}
+}
+
+func (b *CircuitBreaker) finishTrial(err error) {
+	failed, counted := classifyBackendError(err)
+	var ev *BreakerEvent
+	defer func() { b.notify(ev) }()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.inflight--
+	if !counted || b.state != BreakerHalfOpen {
+		return
+	}
+	if failed {
+		ev = b.transition(BreakerOpen)
+		return
+	}
+	ev = b.transition(BreakerClosed)
+}
+
+func (b *CircuitBreaker) rate() float64 {
+	if b.filled == 0 {
+		return 0
+	}
+	return float64(b.failures) / float64(b.filled)
+}
+
+// transition must be called with b.mu held. The event it returns is passed
+// to notify once b.mu is released.
+func (b *CircuitBreaker) transition(to BreakerState) *BreakerEvent {
+	from := b.state
+	if from == to {
+		return nil
+	}
+	ev := BreakerEvent{Backend: b.backend, From: from, To: to, FailureRate: b.rate(), At: b.now()}
+	b.state = to
+	switch to {
+	case BreakerOpen:
+		b.openedAt = ev.At
+	case BreakerClosed:
+		b.filled, b.next, b.failures = 0, 0, 0
+	}
+	return &ev
+}
+
+// notify reports a transition to OnStateChange; b.mu must not be held.
+func (b *CircuitBreaker) notify(ev *BreakerEvent) {
+	if ev != nil && b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(*ev)
+	}
+}
+
+// classifyBackendError decides whether err says something about backend health.
+// Caller cancellation is ignored; client errors (4xx other than 429) count as
+// a healthy backend; throttling, 5xx, timeouts and network errors are failures.
+func classifyBackendError(err error) (failed, counted bool) {
+	if err == nil {
+		return false, true
+	}
+	if errors.Is(err, context.Canceled) {
+		return false, false
+	}
+	status := 0
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &reqErr):
+		status = reqErr.HTTPStatusCode
+	}
+	if status != 0 && status < 500 && status != http.StatusTooManyRequests {
+		return false, true
+	}
+	return true, true
+}
+
+// errCallPanicked is recorded for a guarded call that panicked.
+var errCallPanicked = errors.New("backend call panicked")
+
+// guard runs fn, a call to deployment, through that deployment's circuit
+// breaker when Config.CircuitBreaker is set. A panic in fn is recorded as a
+// failure, so a half-open trial slot is not held forever, and then goes on.
+func (a *Agent) guard(deployment string, fn func() error) error {
+	b := a.Breaker(deployment)
+	if b == nil {
+		return fn()
+	}
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	panicked := true
+	defer func() {
+		if panicked {
+			done(errCallPanicked)
+		}
+	}()
+	err = fn()
+	panicked = false
+	done(err)
+	return err
+}
+
+// Breaker returns the circuit breaker of deployment, or nil if
+// Config.CircuitBreaker is unset. Every deployment (chat, embeddings,
+// Whisper, TTS, images) has its own breaker, so one failing deployment does
+// not stop calls to the others.
+func (a *Agent) Breaker(deployment string) *CircuitBreaker {
+	if a.cfg.CircuitBreaker == nil {
+		return nil
+	}
+	a.breakerMu.Lock()
+	defer a.breakerMu.Unlock()
+	b, ok := a.breakers[deployment]
+	if !ok {
+		if a.breakers == nil {
+			a.breakers = map[string]*CircuitBreaker{}
+		}
+		b = NewCircuitBreaker(a.cfg.Endpoint+"/"+deployment, *a.cfg.CircuitBreaker)
+		a.breakers[deployment] = b
+	}
+	return b
+}
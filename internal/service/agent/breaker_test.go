@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestCircuitBreaker_Transitions(t *testing.T) {
+	var events []BreakerEvent
+	b := NewCircuitBreaker("test", BreakerConfig{
+		WindowSize: 4, MinRequests: 4, FailureRate: 0.5, OpenTimeout: time.Minute,
+		OnStateChange: func(e BreakerEvent) { events = append(events, e) },
+	})
+	now := time.Unix(0, 0)
+	b.now = func() time.Time { return now }
+
+	serverErr := &openai.APIError{HTTPStatusCode: 503}
+	for _, err := range []error{nil, serverErr, nil, serverErr} {
+		done, aerr := b.Allow()
+		if aerr != nil {
+			t.Fatalf("unexpected rejection: %v", aerr)
+		}
+		done(err)
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("expected open, got %s", b.State())
+	}
+	if _, err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	done, err := b.Allow()
+	if err != nil || b.State() != BreakerHalfOpen {
+		t.Fatalf("expected half-open trial, got %v %s", err, b.State())
+	}
+	if _, err := b.Allow(); err == nil {
+		t.Fatalf("expected second trial to be rejected")
+	}
+	done(nil)
+	if b.State() != BreakerClosed {
+		t.Fatalf("expected closed after successful trial, got %s", b.State())
+	}
+	if len(events) != 3 || events[0].To != BreakerOpen || events[1].To != BreakerHalfOpen || events[2].To != BreakerClosed {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+}
+
+func TestCircuitBreaker_IgnoresClientErrors(t *testing.T) {
+	b := NewCircuitBreaker("test", BreakerConfig{WindowSize: 2, MinRequests: 2})
+	for _, err := range []error{&openai.APIError{HTTPStatusCode: 400}, context.Canceled, &openai.APIError{HTTPStatusCode: 400}} {
+		done, _ := b.Allow()
+		done(err)
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("client errors should not open the breaker")
+	}
+}
+
+func TestAgent_BreakerFailsFast(t *testing.T) {
+	f := &fakeClient{err: &openai.APIError{HTTPStatusCode: 500}}
+	a := newAgent(Config{Model: "gpt-test", Timeout: time.Second, CircuitBreaker: &BreakerConfig{WindowSize: 2, MinRequests: 2}}, f)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		a.ChatStructured(ctx, "hi")
+	}
+	if len(f.reqs) != 2 {
+		t.Fatalf("expected 2 backend calls before opening, got %d", len(f.reqs))
+	}
+	var open *CircuitOpenError
+	if _, err := a.ChatStructured(ctx, "hi"); !errors.As(err, &open) {
+		t.Fatalf("expected *CircuitOpenError, got %v", err)
+	}
+}
+
+func TestCircuitBreaker_CallbackMayCallBack(t *testing.T) {
+	var b *CircuitBreaker
+	var states []BreakerState
+	b = NewCircuitBreaker("test", BreakerConfig{
+		WindowSize: 1, MinRequests: 1,
+		OnStateChange: func(e BreakerEvent) { states = append(states, b.State()) },
+	})
+	done, _ := b.Allow()
+	done(&openai.APIError{HTTPStatusCode: 503})
+	if len(states) != 1 || states[0] != BreakerOpen {
+		t.Fatalf("unexpected states seen by the callback: %v", states)
+	}
+}
+
+func TestAgent_BreakerPerDeployment(t *testing.T) {
+	f := &fakeClient{err: &openai.APIError{HTTPStatusCode: 500}}
+	a := newAgent(Config{Model: "gpt-test", Deployment: "chat", EmbeddingDeployment: "embed", Timeout: time.Second, CircuitBreaker: &BreakerConfig{WindowSize: 2, MinRequests: 2}}, f)
+	for i := 0; i < 2; i++ {
+		a.ChatStructured(context.Background(), "hi")
+	}
+	if a.Breaker("chat").State() != BreakerOpen || a.Breaker("embed").State() != BreakerClosed {
+		t.Fatalf("expected only the chat breaker to open, got chat=%s embed=%s", a.Breaker("chat").State(), a.Breaker("embed").State())
+	}
+}
+
+func TestGuard_PanicEndsTheTrial(t *testing.T) {
+	a := &Agent{cfg: Config{CircuitBreaker: &BreakerConfig{WindowSize: 1, MinRequests: 1, OpenTimeout: time.Minute}}}
+	b := a.Breaker("chat")
+	now := time.Unix(0, 0)
+	b.now = func() time.Time { return now }
+
+	a.guard("chat", func() error { return &openai.APIError{HTTPStatusCode: 503} })
+	now = now.Add(time.Minute)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("expected the panic to reach the caller")
+			}
+		}()
+		a.guard("chat", func() error { panic("boom") })
+	}()
+	if b.State() != BreakerOpen {
+		t.Fatalf("expected the panicked trial to reopen the breaker, got %s", b.State())
+	}
+	now = now.Add(time.Minute)
+	if err := a.guard("chat", func() error { return nil }); err != nil || b.State() != BreakerClosed {
+		t.Fatalf("expected a new trial to close the breaker, got %v %s", err, b.State())
+	}
+}
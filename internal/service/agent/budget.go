@@ -0,0 +1,484 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// defaultCompletionEstimate is the answer size assumed when a request sets
+// no max_tokens, until the backend reports the actual usage.
+const defaultCompletionEstimate = 1024
+
+// CallLimits caps a single chat call; zero fields are unlimited. MaxTokens
+// covers the estimated prompt plus max_tokens: a request without max_tokens
+// gets max_tokens lowered to fit, one asking for more fails. MaxCost is the
+// estimated cost in USD; a model without a price (see Config.Pricing) fails
+// with ErrNoPrice while a cost limit applies.
+type CallLimits struct {
+	MaxTokens int     `json:"max_tokens,omitempty"`
+	MaxCost   float64 `json:"max_cost,omitempty"`
+}
+
+// tighter returns the stricter of each limit in l and o.
+func (l CallLimits) tighter(o CallLimits) CallLimits {
+	if o.MaxTokens > 0 && (l.MaxTokens == 0 || o.MaxTokens < l.MaxTokens) {
+		l.MaxTokens = o.MaxTokens
+	}
+	if o.MaxCost > 0 && (l.MaxCost == 0 || o.MaxCost < l.MaxCost) {
+		l.MaxCost = o.MaxCost
+	}
+	return l
+}
+
+// Limits caps the cumulative spend of a Budget; zero fields are unlimited.
+type Limits struct {
+	MaxCalls  int64   `json:"max_calls,omitempty"`
+	MaxTokens int64   `json:"max_tokens,omitempty"`
+	MaxCost   float64 `json:"max_cost,omitempty"` // USD
+	// Wait makes a call that does not fit only because of calls still in
+	// flight wait for them to finish, instead of failing. Calls that would
+	// exceed the budget even after that fail at once.
+	Wait bool `json:"wait,omitempty"`
+}
+
+// Spend is an amount charged to a Budget.
+type Spend struct {
+	Calls  int64   `json:"calls"`
+	Tokens int64   `json:"tokens"`
+	Cost   float64 `json:"cost"` // USD; zero for unpriced models without a cost limit
+}
+
+func (s Spend) plus(o Spend) Spend {
+	return Spend{Calls: s.Calls + o.Calls, Tokens: s.Tokens + o.Tokens, Cost: s.Cost + o.Cost}
+}
+
+func (s Spend) minus(o Spend) Spend {
+	return Spend{Calls: s.Calls - o.Calls, Tokens: s.Tokens - o.Tokens, Cost: s.Cost - o.Cost}
+}
+
+// Remaining is what is left of a Budget; unlimited fields are -1.
+type Remaining struct {
+	Calls  int64   `json:"calls"`
+	Tokens int64   `json:"tokens"`
+	Cost   float64 `json:"cost"`
+}
+
+// Budget is a spending pool shared by many calls, e.g. one batch run. Each
+// call reserves its estimate before it is sent and is settled with the
+// reported usage afterwards. Embedding, audio, speech and image calls are
+// charged one call each, plus the tokens embeddings report; their cost is not
+// tracked, so bound them with MaxCalls. A Budget is safe for concurrent use.
+type Budget struct {
+	name   string
+	tenant string
+	limits Limits
+
+	mu       sync.Mutex
+	spent    Spend
+	reserved Spend
+	changed  chan struct{} // closed and replaced when a reservation is released
+}
+
+// NewBudget returns an empty budget; name appears in errors.
+func NewBudget(name string, limits Limits) *Budget {
+	return &Budget{name: name, limits: limits, changed: make(chan struct{})}
+}
+
+// Name returns the budget's name.
+func (b *Budget) Name() string { return b.name }
+
+// Limits returns the budget's limits.
+func (b *Budget) Limits() Limits {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.limits
+}
+
+// Spent returns what settled calls have used.
+func (b *Budget) Spent() Spend {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.spent
+}
+
+// Remaining returns what a new call may still use; calls in flight count
+// with their estimate until they are settled.
+func (b *Budget) Remaining() Remaining {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	used := b.spent.plus(b.reserved)
+	r := Remaining{Calls: -1, Tokens: -1, Cost: -1}
+	if b.limits.MaxCalls > 0 {
+		r.Calls = max(b.limits.MaxCalls-used.Calls, 0)
+	}
+	if b.limits.MaxTokens > 0 {
+		r.Tokens = max(b.limits.MaxTokens-used.Tokens, 0)
+	}
+	if b.limits.MaxCost > 0 {
+		r.Cost = max(b.limits.MaxCost-used.Cost, 0)
+	}
+	return r
+}
+
+// exceeded returns the first limit that used+s goes over, or nil.
+func (b *Budget) exceeded(used, s Spend) *BudgetError {
+	l := b.limits
+	e := &BudgetError{Budget: b.name, Tenant: b.tenant}
+	switch {
+	case l.MaxCalls > 0 && used.Calls+s.Calls > l.MaxCalls:
+		e.Limit, e.Requested, e.Remaining = "calls", float64(s.Calls), float64(l.MaxCalls-used.Calls)
+	case l.MaxTokens > 0 && used.Tokens+s.Tokens > l.MaxTokens:
+		e.Limit, e.Requested, e.Remaining = "tokens", float64(s.Tokens), float64(l.MaxTokens-used.Tokens)
+	case l.MaxCost > 0 && used.Cost+s.Cost > l.MaxCost:
+		e.Limit, e.Requested, e.Remaining = "cost", s.Cost, l.MaxCost-used.Cost
+	default:
+		return nil
+	}
+	e.Remaining = max(e.Remaining, 0)
+	return e
+}
+
+// tryReserve reserves s. When s does not fit and Limits.Wait is set and
+// settling the calls in flight could make room, it returns a channel that is
+// closed when a reservation is released.
+func (b *Budget) tryReserve(s Spend) (wait <-chan struct{}, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if e := b.exceeded(b.spent.plus(b.reserved), s); e != nil {
+		if b.limits.Wait && b.exceeded(b.spent, s) == nil {
+			return b.changed, nil
+		}
+		return nil, e
+	}
+	b.reserved = b.reserved.plus(s)
+	return nil, nil
+}
+
+// charge adds s to the spend without a reservation.
+func (b *Budget) charge(s Spend) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.spent = b.spent.plus(s)
+}
+
+// settle replaces the reservation with the actual spend.
+func (b *Budget) settle(reserved, actual Spend) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reserved = b.reserved.minus(reserved)
+	b.spent = b.spent.plus(actual)
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// TenantBudgets gives every tenant a Budget that starts over each day.
+type TenantBudgets struct {
+	// Daily is the limit of tenants without an override.
+	Daily Limits
+	// Location decides when a day starts; nil is UTC.
+	Location *time.Location
+
+	now       func() time.Time
+	mu        sync.Mutex
+	day       string
+	overrides map[string]Limits
+	budgets   map[string]*Budget
+}
+
+// NewTenantBudgets returns per-tenant daily budgets with the given default.
+func NewTenantBudgets(daily Limits) *TenantBudgets {
+	return &TenantBudgets{Daily: daily, now: time.Now}
+}
+
+// Set overrides the daily limits of one tenant from the next call on.
+func (t *TenantBudgets) Set(tenant string, daily Limits) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.overrides == nil {
+		t.overrides = map[string]Limits{}
+	}
+	t.overrides[tenant] = daily
+	if b, ok := t.budgets[tenant]; ok {
+		b.mu.Lock()
+		b.limits = daily
+		b.mu.Unlock()
+	}
+}
+
+// Budget returns today's budget of tenant.
+func (t *TenantBudgets) Budget(tenant string) *Budget {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now
+	if t.now != nil {
+		now = t.now
+	}
+	loc := t.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	day := now().In(loc).Format(time.DateOnly)
+	if day != t.day || t.budgets == nil {
+		t.day, t.budgets = day, map[string]*Budget{}
+	}
+	b, ok := t.budgets[tenant]
+	if !ok {
+		limits, ok := t.overrides[tenant]
+		if !ok {
+			limits = t.Daily
+		}
+		b = NewBudget(fmt.Sprintf("tenant %s %s", tenant, day), limits)
+		b.tenant = tenant
+		t.budgets[tenant] = b
+	}
+	return b
+}
+
+// Remaining returns what tenant may still use today.
+func (t *TenantBudgets) Remaining(tenant string) Remaining { return t.Budget(tenant).Remaining() }
+
+// ErrBudgetExceeded is matched by errors.Is for every *BudgetError.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// BudgetError is returned without calling the backend when a call would go
+// over a limit.
+type BudgetError struct {
+	Budget string `json:"budget"` // budget name, or "call" for CallLimits
+	Tenant string `json:"tenant,omitempty"`
+	// Limit is calls, tokens or cost for a Budget and tokens or cost for
+	// CallLimits. Requested is the call's estimate and Remaining what was
+	// left, both in the limit's unit.
+	Limit     string  `json:"limit"`
+	Requested float64 `json:"requested"`
+	Remaining float64 `json:"remaining"`
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("budget %q exceeded: %s limit (requested %g, remaining %g)", e.Budget, e.Limit, e.Requested, e.Remaining)
+}
+
+// Is reports whether target is ErrBudgetExceeded.
+func (e *BudgetError) Is(target error) bool { return target == ErrBudgetExceeded }
+
+// ErrNoPrice is returned without calling the backend when a cost limit
+// applies to a call whose deployment and model have no price, so the limit
+// cannot be enforced. Add the deployment to Config.Pricing.
+var ErrNoPrice = errors.New("no price for deployment")
+
+type budgetKey struct{}
+
+// budgetScope is what the context carries for budgeting.
+type budgetScope struct {
+	budgets []*Budget
+	tenant  string
+	limits  CallLimits
+}
+
+func scopeFrom(ctx context.Context) budgetScope {
+	s, _ := ctx.Value(budgetKey{}).(budgetScope)
+	return s
+}
+
+func withScope(ctx context.Context, s budgetScope) context.Context {
+	return context.WithValue(ctx, budgetKey{}, s)
+}
+
+// ContextWithBudget charges calls made with ctx to b, in addition to
+// budgets already on ctx, e.g. a batch budget inside a job budget.
+func ContextWithBudget(ctx context.Context, b *Budget) context.Context {
+	s := scopeFrom(ctx)
+	s.budgets = append(s.budgets[:len(s.budgets):len(s.budgets)], b)
+	return withScope(ctx, s)
+}
+
+// ContextWithTenant charges calls made with ctx to the tenant's daily
+// budget in Config.TenantBudgets.
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	s := scopeFrom(ctx)
+	s.tenant = tenant
+	return withScope(ctx, s)
+}
+
+// ContextWithCallLimits applies l to each chat call made with ctx; the
+// stricter of these and Config.CallLimits wins.
+func ContextWithCallLimits(ctx context.Context, l CallLimits) context.Context {
+	s := scopeFrom(ctx)
+	s.limits = s.limits.tighter(l)
+	return withScope(ctx, s)
+}
+
+// WithBudget charges the call to b; see ContextWithBudget.
+func WithBudget(b *Budget) ChatOption {
+	return func(p *chatParams) { p.budgets = append(p.budgets, b) }
+}
+
+// WithCallLimits caps this call; see ContextWithCallLimits.
+func WithCallLimits(l CallLimits) ChatOption {
+	return func(p *chatParams) { p.callLimits = p.callLimits.tighter(l) }
+}
+
+// WithDefaultCallLimits caps every chat call of the agent.
+func WithDefaultCallLimits(l CallLimits) Option { return func(c *Config) { c.CallLimits = l } }
+
+// WithTenantBudgets enables per-tenant daily budgets; see ContextWithTenant.
+func WithTenantBudgets(t *TenantBudgets) Option { return func(c *Config) { c.TenantBudgets = t } }
+
+// budgetContext moves budgets given as chat options onto ctx.
+func (p chatParams) budgetContext(ctx context.Context) context.Context {
+	for _, b := range p.budgets {
+		ctx = ContextWithBudget(ctx, b)
+	}
+	if p.callLimits != (CallLimits{}) {
+		ctx = ContextWithCallLimits(ctx, p.callLimits)
+	}
+	return ctx
+}
+
+// Budgets returns the budgets calls made with ctx are charged to: those
+// on ctx, then the tenant's daily budget. Use Remaining on them to show what
+// is left.
+func (a *Agent) Budgets(ctx context.Context) []*Budget {
+	s := scopeFrom(ctx)
+	out := append([]*Budget(nil), s.budgets...)
+	if s.tenant != "" && a.cfg.TenantBudgets != nil {
+		out = append(out, a.cfg.TenantBudgets.Budget(s.tenant))
+	}
+	return out
+}
+
+type hedgeChargeKey struct{}
+
+// chargeHedge charges a hedged duplicate of the call reserved on ctx to the
+// same budgets. The duplicate is charged its estimate when it is launched,
+// since a cancelled request reports no usage.
+func chargeHedge(ctx context.Context) {
+	if charge, ok := ctx.Value(hedgeChargeKey{}).(func()); ok {
+		charge()
+	}
+}
+
+// reserve checks req against the call limits and budgets of ctx, lowering
+// max_tokens to fit CallLimits.MaxTokens when it is unset, and reserves the
+// estimated spend. The returned settle must be called with the reported
+// usage once the call is done, and the returned context used for the call so
+// hedged duplicates are charged too (see chargeHedge).
+func (a *Agent) reserve(ctx context.Context, req *openai.ChatCompletionRequest) (_ context.Context, settle func(openai.Usage, error), err error) {
+	s := scopeFrom(ctx)
+	limits := a.cfg.CallLimits.tighter(s.limits)
+	budgets := a.Budgets(ctx)
+	if limits == (CallLimits{}) && len(budgets) == 0 {
+		return ctx, func(openai.Usage, error) {}, nil
+	}
+
+	prompt := estimateTokens(*req)
+	if limits.MaxTokens > 0 {
+		room := limits.MaxTokens - prompt
+		switch {
+		case room <= 0 || req.MaxTokens > room:
+			return ctx, nil, &BudgetError{Budget: "call", Tenant: s.tenant, Limit: "tokens", Requested: float64(prompt + req.MaxTokens), Remaining: float64(limits.MaxTokens)}
+		case req.MaxTokens == 0:
+			req.MaxTokens = room
+		}
+	}
+	completion := req.MaxTokens
+	if completion == 0 {
+		completion = defaultCompletionEstimate
+	}
+	deployment := a.resolveDeployment(req.Model)
+	price, priced := a.price(deployment, req.Model)
+	if !priced {
+		// fail closed: an unpriced call would slip through every cost limit
+		if limits.MaxCost > 0 {
+			return ctx, nil, fmt.Errorf("%w %q: call cost limit cannot be checked", ErrNoPrice, deployment)
+		}
+		for _, b := range budgets {
+			if b.Limits().MaxCost > 0 {
+				return ctx, nil, fmt.Errorf("%w %q: cost limit of budget %q cannot be checked", ErrNoPrice, deployment, b.Name())
+			}
+		}
+	}
+	cost := func(in, out int) float64 {
+		if !priced {
+			return 0
+		}
+		return (float64(in)*price.Input + float64(out)*price.Output) / 1e6
+	}
+	est := Spend{Calls: 1, Tokens: int64(prompt + completion), Cost: cost(prompt, completion)}
+	if limits.MaxCost > 0 && est.Cost > limits.MaxCost {
+		return ctx, nil, &BudgetError{Budget: "call", Tenant: s.tenant, Limit: "cost", Requested: est.Cost, Remaining: limits.MaxCost}
+	}
+
+	if err := reserveAll(ctx, budgets, est); err != nil {
+		return ctx, nil, err
+	}
+	ctx = context.WithValue(ctx, hedgeChargeKey{}, func() {
+		for _, b := range budgets {
+			b.charge(est)
+		}
+	})
+	return ctx, func(u openai.Usage, err error) {
+		actual := Spend{Calls: 1}
+		if err == nil {
+			in, out := u.PromptTokens, u.CompletionTokens
+			if u.TotalTokens == 0 {
+				in, out = prompt, 0
+			}
+			actual.Tokens, actual.Cost = int64(in+out), cost(in, out)
+		}
+		for _, b := range budgets {
+			b.settle(est, actual)
+		}
+	}, nil
+}
+
+// reserveCall charges one embedding, audio, speech or image call to the
+// budgets of ctx. settle must be called with the tokens the backend reported,
+// if any, once the call is done.
+func (a *Agent) reserveCall(ctx context.Context) (settle func(tokens int), err error) {
+	budgets := a.Budgets(ctx)
+	est := Spend{Calls: 1}
+	if err := reserveAll(ctx, budgets, est); err != nil {
+		return nil, err
+	}
+	return func(tokens int) {
+		for _, b := range budgets {
+			b.settle(est, Spend{Calls: 1, Tokens: int64(tokens)})
+		}
+	}, nil
+}
+
+// reserveAll reserves est on every budget or none. Everything is released
+// before waiting, so calls holding one budget never wait on another.
+func reserveAll(ctx context.Context, budgets []*Budget, est Spend) error {
+	for {
+		var wait <-chan struct{}
+		held := 0
+		for _, b := range budgets {
+			w, err := b.tryReserve(est)
+			if err != nil || w != nil {
+				for _, h := range budgets[:held] {
+					h.settle(est, Spend{})
+				}
+				if err != nil {
+					return err
+				}
+				wait = w
+				break
+			}
+			held++
+		}
+		if wait == nil {
+			return nil
+		}
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
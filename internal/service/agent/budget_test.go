@@ -0,0 +1,182 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func usageChat(prompt, completion int) *fakeClient {
+	return &fakeClient{resp: openai.ChatCompletionResponse{
+		Model:   "gpt-4o",
+		Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "ok"}}},
+		Usage:   openai.Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion},
+	}}
+}
+
+func TestBudget_BatchLimitStopsLoop(t *testing.T) {
+	f := usageChat(100, 50)
+	a := &Agent{cfg: Config{Model: "gpt-4o"}, client: f}
+	batch := NewBudget("nightly batch", Limits{MaxCalls: 3, MaxTokens: 10000})
+	ctx := ContextWithBudget(context.Background(), batch)
+
+	var err error
+	calls := 0
+	for ; calls < 10; calls++ {
+		if _, err = a.ChatStructured(ctx, "grade this", WithMaxTokens(200)); err != nil {
+			break
+		}
+	}
+	var berr *BudgetError
+	if calls != 3 || len(f.reqs) != 3 || !errors.As(err, &berr) || !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected 3 calls then a budget error, got %d calls (%d sent): %v", calls, len(f.reqs), err)
+	}
+	if berr.Budget != "nightly batch" || berr.Limit != "calls" || berr.Remaining != 0 {
+		t.Fatalf("unexpected error %+v", berr)
+	}
+	spent := batch.Spent()
+	if spent.Calls != 3 || spent.Tokens != 450 || math.Abs(spent.Cost-0.00225) > 1e-12 {
+		t.Fatalf("expected actual usage to be charged, got %+v", spent)
+	}
+	if r := batch.Remaining(); r.Calls != 0 || r.Tokens != 10000-450 || r.Cost != -1 {
+		t.Fatalf("unexpected remaining %+v", r)
+	}
+	if b := a.Budgets(ctx); len(b) != 1 || b[0] != batch {
+		t.Fatalf("unexpected budgets %v", b)
+	}
+}
+
+func TestBudget_CallLimits(t *testing.T) {
+	f := usageChat(10, 10)
+	a := &Agent{cfg: Config{Model: "gpt-4o", CallLimits: CallLimits{MaxTokens: 500}}, client: f}
+
+	// max_tokens unset: lowered to what is left after the prompt
+	if _, err := a.ChatStructured(context.Background(), "hi"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := f.reqs[0].MaxTokens; got <= 0 || got >= 500 {
+		t.Fatalf("expected max_tokens clamped below 500, got %d", got)
+	}
+	// asking for more than the limit fails before sending
+	_, err := a.ChatStructured(context.Background(), "hi", WithMaxTokens(1000))
+	var berr *BudgetError
+	if !errors.As(err, &berr) || berr.Budget != "call" || berr.Limit != "tokens" || len(f.reqs) != 1 {
+		t.Fatalf("expected call token error, got %v", err)
+	}
+	// the stricter cost limit from the option applies: 400 output tokens of gpt-4o cost $0.004
+	_, err = a.ChatStructured(context.Background(), "hi", WithMaxTokens(400), WithCallLimits(CallLimits{MaxCost: 0.001}))
+	if !errors.As(err, &berr) || berr.Limit != "cost" || len(f.reqs) != 1 {
+		t.Fatalf("expected call cost error, got %v", err)
+	}
+	// unpriced deployments fail closed under a cost limit, from a call or a budget
+	a.cfg.Model = "exam-grader"
+	if _, err := a.ChatStructured(context.Background(), "hi", WithMaxTokens(400), WithCallLimits(CallLimits{MaxCost: 0.001})); !errors.Is(err, ErrNoPrice) || len(f.reqs) != 1 {
+		t.Fatalf("expected no price error, got %v", err)
+	}
+	if _, err := a.ChatStructured(context.Background(), "hi", WithBudget(NewBudget("tenant", Limits{MaxCost: 5}))); !errors.Is(err, ErrNoPrice) || len(f.reqs) != 1 {
+		t.Fatalf("expected no price error for a budget, got %v", err)
+	}
+	a.cfg.Pricing = map[string]Price{"exam-grader": {Input: 2.5, Output: 10}}
+	if _, err := a.ChatStructured(context.Background(), "hi", WithMaxTokens(40), WithCallLimits(CallLimits{MaxCost: 0.001})); err != nil {
+		t.Fatalf("expected a priced call to pass, got %v", err)
+	}
+}
+
+func TestBudget_TenantDaily(t *testing.T) {
+	now := time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)
+	tb := NewTenantBudgets(Limits{MaxTokens: 300})
+	tb.now = func() time.Time { return now }
+	tb.Set("school-b", Limits{MaxTokens: 1000})
+	a := &Agent{cfg: Config{Model: "gpt-4o", TenantBudgets: tb}, client: usageChat(100, 100)}
+	ctxA := ContextWithTenant(context.Background(), "school-a")
+
+	if _, err := a.ChatStructured(ctxA, "hi", WithMaxTokens(100)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	_, err := a.ChatStructured(ctxA, "hi", WithMaxTokens(100))
+	var berr *BudgetError
+	if !errors.As(err, &berr) || berr.Tenant != "school-a" || berr.Limit != "tokens" {
+		t.Fatalf("expected tenant budget error, got %v", err)
+	}
+	if r := tb.Remaining("school-a"); r.Tokens != 100 {
+		t.Fatalf("expected 100 tokens left, got %+v", r)
+	}
+	if _, err := a.ChatStructured(ContextWithTenant(context.Background(), "school-b"), "hi", WithMaxTokens(100)); err != nil {
+		t.Fatalf("expected override to allow the call, got %v", err)
+	}
+	now = now.Add(2 * time.Hour) // next day
+	if _, err := a.ChatStructured(ctxA, "hi", WithMaxTokens(100)); err != nil {
+		t.Fatalf("expected a fresh budget the next day, got %v", err)
+	}
+}
+
+func TestBudget_WaitForInFlight(t *testing.T) {
+	b := NewBudget("pool", Limits{MaxCalls: 1, Wait: true})
+	a := &Agent{cfg: Config{Model: "gpt-4o"}}
+	ctx := ContextWithBudget(context.Background(), b)
+	req := openai.ChatCompletionRequest{Model: "gpt-4o", MaxTokens: 10}
+
+	_, settle, err := a.reserve(ctx, &req)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		wctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		_, _, err := a.reserve(wctx, &req)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	// the first call settles; the waiter then finds the call limit spent
+	settle(openai.Usage{TotalTokens: 5, PromptTokens: 5}, nil)
+	if err := <-done; !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected budget error once the first call settled, got %v", err)
+	}
+
+	// a failed call releases its tokens but still counts as a call
+	b2 := NewBudget("pool", Limits{MaxTokens: 100, Wait: true})
+	ctx = ContextWithBudget(context.Background(), b2)
+	_, settle, _ = a.reserve(ctx, &req)
+	if r := b2.Remaining(); r.Tokens >= 100 {
+		t.Fatalf("expected the reservation to count, got %+v", r)
+	}
+	settle(openai.Usage{}, errors.New("boom"))
+	if s := b2.Spent(); s.Calls != 1 || s.Tokens != 0 {
+		t.Fatalf("unexpected spend after failure %+v", s)
+	}
+}
+
+func TestBudget_ChargesHedgedDuplicate(t *testing.T) {
+	c := &slowClient{delay: 2 * time.Second}
+	a := &Agent{cfg: Config{Model: "gpt-4o", Timeout: 5 * time.Second, Hedge: &HedgePolicy{MinDelay: 20 * time.Millisecond, MaxDelay: 20 * time.Millisecond}}, client: c}
+	b := NewBudget("batch", Limits{MaxCalls: 10})
+
+	if _, err := a.ChatStructured(context.Background(), "hi", WithBudget(b), WithMaxTokens(100)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// the winner is settled with its usage and the duplicate with its estimate
+	if s := b.Spent(); s.Calls != 2 || s.Tokens <= 15 || s.Cost == 0 {
+		t.Fatalf("expected both requests charged, got %+v", s)
+	}
+}
+
+func TestBudget_ChargesNonChatCalls(t *testing.T) {
+	f := &fakeEmbedClient{}
+	a := &Agent{cfg: Config{Model: "gpt-test", EmbeddingDeployment: "embed-test"}, client: f}
+	b := NewBudget("overnight", Limits{MaxCalls: 2})
+	ctx := ContextWithBudget(context.Background(), b)
+
+	_, err := a.Embed(ctx, []string{"a", "bb", "ccc"}, WithBatchSize(1))
+	var be *BudgetError
+	if !errors.As(err, &be) || be.Limit != "calls" || len(f.batches) != 2 {
+		t.Fatalf("expected the third batch to be refused, got %v after %d batches", err, len(f.batches))
+	}
+	if s := b.Spent(); s.Calls != 2 || s.Tokens != 2 {
+		t.Fatalf("unexpected spend %+v", s)
+	}
+}
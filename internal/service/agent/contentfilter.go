@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ContentFilterError is returned when the service's content filter rejects
+// a prompt or a generated result.
+type ContentFilterError struct {
+	Code       string // error code from the service, e.g. "content_policy_violation"
+	Message    string
+	Categories []string // filtered categories (hate, self_harm, sexual, violence, ...) when reported
+	Err        error    // the underlying API error
+}
+
+func (e *ContentFilterError) Error() string {
+	if len(e.Categories) > 0 {
+		return fmt.Sprintf("content filter (%s): %s", strings.Join(e.Categories, ", "), e.Message)
+	}
+	return "content filter: " + e.Message
+}
+
+func (e *ContentFilterError) Unwrap() error { return e.Err }
+
+// contentFilterCodes are the API error codes used for content-filter rejections.
+var contentFilterCodes = map[string]bool{
+	"content_filter":               true,
+	"content_policy_violation":     true,
+	"contentFilter":                true,
+	"ResponsibleAIPolicyViolation": true,
+}
+
+// asContentFilterError returns err as a *ContentFilterError when it is a
+// content-filter rejection, and err unchanged otherwise.
+func asContentFilterError(err error) error {
+	var apiErr *openai.APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	code, _ := apiErr.Code.(string)
+	inner := apiErr.InnerError
+	if !contentFilterCodes[code] && (inner == nil || !contentFilterCodes[inner.Code]) {
+		return err
+	}
+	cf := &ContentFilterError{Code: code, Message: apiErr.Message, Err: err}
+	if inner != nil {
+		r := inner.ContentFilterResults
+		for _, c := range []struct {
+			name     string
+			filtered bool
+		}{
+			{"hate", r.Hate.Filtered},
+			{"self_harm", r.SelfHarm.Filtered},
+			{"sexual", r.Sexual.Filtered},
+			{"violence", r.Violence.Filtered},
+			{"jailbreak", r.JailBreak.Filtered},
+			{"profanity", r.Profanity.Filtered},
+		} {
+			if c.filtered {
+				cf.Categories = append(cf.Categories, c.name)
+			}
+		}
+	}
+	return cf
+}
@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Price is the cost of a model in USD per million tokens.
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// DefaultPricing holds list prices (USD per million tokens, Azure global
+// standard) used by dry runs. Config.Pricing overrides or extends it. Names
+// match exactly or as a prefix, so "gpt-4o-2024-08-06" uses "gpt-4o".
+var DefaultPricing = map[string]Price{
+	"gpt-4o":       {Input: 2.50, Output: 10.00},
+	"gpt-4o-mini":  {Input: 0.15, Output: 0.60},
+	"gpt-4.1":      {Input: 2.00, Output: 8.00},
+	"gpt-4.1-mini": {Input: 0.40, Output: 1.60},
+	"gpt-4.1-nano": {Input: 0.10, Output: 0.40},
+	"gpt-35-turbo": {Input: 0.50, Output: 1.50},
+	"o3-mini":      {Input: 1.10, Output: 4.40},
+	"o4-mini":      {Input: 1.10, Output: 4.40},
+}
+
+// DryRun describes a chat request that was built but not sent.
+type DryRun struct {
+	Request    openai.ChatCompletionRequest `json:"request"`
+	Provider   Provider                     `json:"provider"`
+	Deployment string                       `json:"deployment"` // deployment (Azure) or model the request would go to
+	// PromptTokens is estimated from the request size. MaxCompletionTokens is
+	// the request's max_tokens; 0 means the model's limit.
+	PromptTokens        int `json:"prompt_tokens"`
+	MaxCompletionTokens int `json:"max_completion_tokens,omitempty"`
+	// Costs are in USD. MaxCompletionCost is the cost if the answer used all
+	// of MaxCompletionTokens. EstimatedCost adds the prompt and, without
+	// max_tokens, the same answer size budgets reserve (1024 tokens). Priced
+	// is false when no price is known for the deployment or model, and the
+	// costs are then zero.
+	PromptCost        float64 `json:"prompt_cost"`
+	MaxCompletionCost float64 `json:"max_completion_cost"`
+	EstimatedCost     float64 `json:"estimated_cost"`
+	Priced            bool    `json:"priced"`
+}
+
+// WithDryRun makes chat calls return the request they would send in
+// ChatResult.DryRun instead of calling the backend. Untrusted content is
+// still spotlighted but not classified, and calls are not moderated, since
+// the classifiers may be remote.
+func WithDryRun() Option { return func(c *Config) { c.DryRun = true } }
+
+// dryRun describes req for ChatResult.DryRun.
+func (a *Agent) dryRun(req openai.ChatCompletionRequest) *DryRun {
+	d := &DryRun{
+		Request:             req,
+		Provider:            normalizeProvider(a.cfg.Provider),
+		Deployment:          a.resolveDeployment(req.Model),
+		PromptTokens:        estimateTokens(req),
+		MaxCompletionTokens: req.MaxTokens,
+	}
+	price, ok := a.price(d.Deployment, req.Model)
+	if ok {
+		d.Priced = true
+		d.PromptCost = float64(d.PromptTokens) * price.Input / 1e6
+		d.MaxCompletionCost = float64(d.MaxCompletionTokens) * price.Output / 1e6
+		d.EstimatedCost = d.PromptCost + d.MaxCompletionCost
+		if d.MaxCompletionTokens == 0 {
+			d.EstimatedCost += defaultCompletionEstimate * price.Output / 1e6
+		}
+	}
+	return d
+}
+
+// resolveDeployment returns where a request for model is sent, following the
+// Azure model mapping in clientConfig.
+func (a *Agent) resolveDeployment(model string) string {
+	if normalizeProvider(a.cfg.Provider).isAzure() && model == a.cfg.Model && a.cfg.Deployment != "" {
+		return a.cfg.Deployment
+	}
+	return model
+}
+
+// price looks up names in Config.Pricing, then DefaultPricing.
+func (a *Agent) price(names ...string) (Price, bool) {
+	for _, table := range []map[string]Price{a.cfg.Pricing, DefaultPricing} {
+		for _, name := range names {
+			if p, ok := lookupPrice(table, name); ok {
+				return p, true
+			}
+		}
+	}
+	return Price{}, false
+}
+
+func lookupPrice(table map[string]Price, name string) (Price, bool) {
+	if p, ok := table[name]; ok {
+		return p, true
+	}
+	best := ""
+	for k := range table {
+		if strings.HasPrefix(name, k+"-") && len(k) > len(best) {
+			best = k
+		}
+	}
+	p, ok := table[best]
+	return p, ok && best != ""
+}
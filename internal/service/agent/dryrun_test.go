@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"go-azure-openai/internal/service/injection"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestDryRun_SchemaPromptNoCall(t *testing.T) {
+	f := &fakeClient{}
+	a := &Agent{cfg: Config{Model: "gpt-4o-mini", Deployment: "exam-4o-mini", DryRun: true}, client: f}
+
+	res, parsed, err := a.ChatStructuredJSON(context.Background(), "Grade this essay.",
+		WithSystem("You are an IELTS examiner."),
+		WithOutputSchema(`{"type":"object","properties":{"band":{"type":"number"}}}`),
+		WithMaxTokens(500))
+	if err != nil || parsed != nil {
+		t.Fatalf("expected dry run without parse, got %v %v", parsed, err)
+	}
+	if len(f.reqs) != 0 {
+		t.Fatalf("expected no backend call, got %d", len(f.reqs))
+	}
+	d := res.DryRun
+	if d == nil {
+		t.Fatalf("expected dry run result")
+	}
+	sys := d.Request.Messages[0].Content
+	if d.Request.Messages[0].Role != openai.ChatMessageRoleSystem || !strings.HasPrefix(sys, "You are an IELTS examiner.") || !strings.Contains(sys, `"band"`) {
+		t.Fatalf("expected caller and schema system prompts, got %q", sys)
+	}
+	if d.Deployment != "exam-4o-mini" || d.Provider != ProviderAzure || d.MaxCompletionTokens != 500 {
+		t.Fatalf("unexpected dry run: %+v", d)
+	}
+	if d.PromptTokens != estimateTokens(d.Request) || d.PromptTokens == 0 {
+		t.Fatalf("unexpected token estimate %d", d.PromptTokens)
+	}
+	want := float64(d.PromptTokens)*0.15/1e6 + 500*0.60/1e6
+	if !d.Priced || math.Abs(d.EstimatedCost-want) > 1e-12 {
+		t.Fatalf("expected cost %v, got %+v", want, d)
+	}
+}
+
+func TestDryRun_StreamAndPricing(t *testing.T) {
+	a := &Agent{cfg: Config{Provider: ProviderOpenAI, Model: "gpt-4o-2024-08-06", DryRun: true}, client: &fakeClient{}}
+	called := false
+	res, err := a.ChatStream(context.Background(), "hi", func(string) bool { called = true; return true })
+	if err != nil || called || res.DryRun == nil || !res.DryRun.Request.Stream {
+		t.Fatalf("unexpected stream dry run: %+v %v", res, err)
+	}
+	if d := res.DryRun; d.Deployment != "gpt-4o-2024-08-06" || !d.Priced || d.MaxCompletionCost != 0 {
+		t.Fatalf("expected gpt-4o prefix pricing, got %+v", d)
+	}
+	// without max_tokens the estimate assumes the answer size budgets reserve
+	if d := res.DryRun; math.Abs(d.EstimatedCost-d.PromptCost-defaultCompletionEstimate*10.0/1e6) > 1e-12 {
+		t.Fatalf("expected the default completion estimate in the cost, got %+v", d)
+	}
+
+	a.cfg.Model = "my-finetune"
+	if res, _ := a.ChatStructured(context.Background(), "hi"); res.DryRun.Priced || res.DryRun.EstimatedCost != 0 {
+		t.Fatalf("expected unpriced model, got %+v", res.DryRun)
+	}
+	a.cfg.Pricing = map[string]Price{"my-finetune": {Input: 1e6}}
+	if res, _ := a.ChatStructured(context.Background(), "hi"); !res.DryRun.Priced || res.DryRun.PromptCost != float64(res.DryRun.PromptTokens) {
+		t.Fatalf("expected Config.Pricing to apply, got %+v", res.DryRun)
+	}
+}
+
+func TestDryRun_UntrustedNotClassified(t *testing.T) {
+	remote := injection.LLM(func(context.Context, string, string) (string, error) {
+		t.Fatalf("classifier called in dry run")
+		return "", nil
+	}, 0)
+	a := &Agent{cfg: Config{Model: "gpt-test", DryRun: true, InjectionClassifier: remote}, client: &fakeClient{}}
+	res, err := a.ChatStructured(context.Background(), "Grade:", WithUntrustedContent("my essay"))
+	if err != nil || res.Injection != nil {
+		t.Fatalf("expected no classification, got %+v %v", res.Injection, err)
+	}
+	if msgs := res.DryRun.Request.Messages; !strings.Contains(msgs[len(msgs)-1].Content, "my essay") {
+		t.Fatalf("expected spotlighted content in request, got %+v", msgs)
+	}
+}
@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	openai "github.com/sashabaranov/go-openai"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// defaultEmbedBatchSize is the number of inputs sent per embeddings request.
+// Azure embedding deployments reject larger batches for older models.
+const defaultEmbedBatchSize = 16
+
+// embeddingClient is implemented by clients that can create embeddings.
+// It is kept separate from oaiClient so chat-only fakes keep working.
+type embeddingClient interface {
+	CreateEmbeddings(ctx context.Context, conv openai.EmbeddingRequestConverter) (openai.EmbeddingResponse, error)
+}
+
+// EmbedOption allows customizing a single Embed call.
+type EmbedOption func(*embedParams)
+
+type embedParams struct {
+	batchSize  int
+	dimensions int
+}
+
+// WithBatchSize sets how many inputs are sent per request (default 16).
+func WithBatchSize(n int) EmbedOption { return func(p *embedParams) { p.batchSize = n } }
+
+// WithDimensions requests vectors of the given size (text-embedding-3 and later only).
+func WithDimensions(n int) EmbedOption { return func(p *embedParams) { p.dimensions = n } }
+
+// EmbedResult holds one vector per input, in input order.
+type EmbedResult struct {
+	Vectors [][]float32 `json:"vectors"`
+	Model   string      `json:"model,omitempty"`
+	Tokens  int         `json:"tokens,omitempty"`
+}
+
+// Embed returns embedding vectors for inputs using Config.EmbeddingDeployment.
+// Inputs are split into batches and the results are stitched back in order.
+func (a *Agent) Embed(ctx context.Context, inputs []string, opts ...EmbedOption) (EmbedResult, error) {
+	var empty EmbedResult
+	if a == nil || a.client == nil {
+		return empty, errors.New("agent not initialized")
+	}
+	ec, ok := a.client.(embeddingClient)
+	if !ok {
+		return empty, errors.New("client does not support embeddings")
+	}
+	if a.cfg.EmbeddingDeployment == "" {
+		return empty, errors.New("missing embedding deployment (set AZURE_OPENAI_EMBEDDING_DEPLOYMENT)")
+	}
+	p := embedParams{batchSize: defaultEmbedBatchSize}
+	for _, o := range opts {
+		o(&p)
+	}
+	if p.batchSize <= 0 {
+		p.batchSize = defaultEmbedBatchSize
+	}
+
+	ctx, o := a.startOp(ctx, semconv.GenAIOperationNameEmbeddings, a.cfg.EmbeddingDeployment, a.cfg.EmbeddingDeployment)
+	res, usage, err := a.embed(ctx, ec, inputs, p)
+	o.end(ctx, err, res.Model, usage)
+	return res, err
+}
+
+func (a *Agent) embed(ctx context.Context, ec embeddingClient, inputs []string, p embedParams) (EmbedResult, openai.Usage, error) {
+	var empty EmbedResult
+	var usage openai.Usage
+	res := EmbedResult{Vectors: make([][]float32, len(inputs))}
+	for start := 0; start < len(inputs); start += p.batchSize {
+		end := min(start+p.batchSize, len(inputs))
+		req := openai.EmbeddingRequestStrings{
+			Input:      inputs[start:end],
+			Model:      openai.EmbeddingModel(a.cfg.EmbeddingDeployment),
+			Dimensions: p.dimensions,
+		}
+		settle, err := a.reserveCall(ctx)
+		if err != nil {
+			return empty, usage, err
+		}
+		bctx, cancel := context.WithTimeout(ctx, a.cfg.Timeout)
+		var resp openai.EmbeddingResponse
+		err = a.guard(a.cfg.EmbeddingDeployment, func() (err error) {
+			resp, err = ec.CreateEmbeddings(bctx, req)
+			return err
+		})
+		cancel()
+		settle(resp.Usage.TotalTokens)
+		if err != nil {
+			return empty, usage, fmt.Errorf("embed batch %d-%d: %w", start, end, err)
+		}
+		if len(resp.Data) != end-start {
+			return empty, usage, fmt.Errorf("embed batch %d-%d: got %d vectors, want %d", start, end, len(resp.Data), end-start)
+		}
+		for _, d := range resp.Data {
+			if d.Index < 0 || d.Index >= end-start {
+				return empty, usage, fmt.Errorf("embed batch %d-%d: vector index %d out of range", start, end, d.Index)
+			}
+			res.Vectors[start+d.Index] = d.Embedding
+		}
+		res.Model = string(resp.Model)
+		res.Tokens += resp.Usage.TotalTokens
+		usage.PromptTokens += resp.Usage.PromptTokens
+		usage.TotalTokens += resp.Usage.TotalTokens
+	}
+	return res, usage, nil
+}
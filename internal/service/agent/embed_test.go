@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// fakeEmbedClient adds embeddings support to fakeClient.
+type fakeEmbedClient struct {
+	fakeClient
+	batches [][]string
+}
+
+func (f *fakeEmbedClient) CreateEmbeddings(ctx context.Context, conv openai.EmbeddingRequestConverter) (openai.EmbeddingResponse, error) {
+	req := conv.Convert()
+	in := req.Input.([]string)
+	f.batches = append(f.batches, in)
+	resp := openai.EmbeddingResponse{Model: req.Model, Usage: openai.Usage{TotalTokens: len(in)}}
+	// return in reverse order to check that Index is honoured
+	for i := len(in) - 1; i >= 0; i-- {
+		resp.Data = append(resp.Data, openai.Embedding{Index: i, Embedding: []float32{float32(len(in[i]))}})
+	}
+	return resp, nil
+}
+
+func TestEmbed_Batches(t *testing.T) {
+	f := &fakeEmbedClient{}
+	a := &Agent{cfg: Config{Model: "gpt-test", EmbeddingDeployment: "embed-test"}, client: f}
+	res, err := a.Embed(context.Background(), []string{"a", "bb", "ccc"}, WithBatchSize(2))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(f.batches) != 2 {
+		t.Fatalf("expected 2 batches, got %d", len(f.batches))
+	}
+	for i, want := range []float32{1, 2, 3} {
+		if res.Vectors[i][0] != want {
+			t.Fatalf("vector %d out of order: %v", i, res.Vectors[i])
+		}
+	}
+	if res.Tokens != 3 || res.Model != "embed-test" {
+		t.Fatalf("unexpected result meta: %+v", res)
+	}
+}
+
+func TestEmbed_Unsupported(t *testing.T) {
+	a := &Agent{cfg: Config{Model: "gpt-test", EmbeddingDeployment: "embed-test"}, client: &fakeClient{}}
+	if _, err := a.Embed(context.Background(), []string{"a"}); err == nil {
+		t.Fatalf("expected error for chat-only client")
+	}
+}
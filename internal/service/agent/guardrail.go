@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"context"
+
+	"go-azure-openai/internal/service/guardrail"
+	"go-azure-openai/internal/service/redact"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// WithGuardrails checks the answer of ChatStructured (and ChatStructuredJSON)
+// with the policy's validators. Fixes are applied to the returned text; when
+// a validator fails, the model is asked again with the failures explained,
+// up to Policy.MaxReasks times, and then Policy.Fallback is used. Without a
+// fallback the last answer is returned with a *guardrail.Error. The report is
+// in ChatResult.Guardrails.
+func WithGuardrails(p guardrail.Policy) ChatOption {
+	return func(cp *chatParams) { cp.guardrails = &p }
+}
+
+// applyGuardrails validates r and re-asks until it passes or the policy gives up.
+func (a *Agent) applyGuardrails(ctx context.Context, req openai.ChatCompletionRequest, s *redact.Session, p chatParams, r ChatResult) (ChatResult, error) {
+	policy := *p.guardrails
+	report := &guardrail.Report{}
+	tokens := 0
+	for attempt := 1; ; attempt++ {
+		report.Attempts = attempt
+		tokens += r.Tokens
+		text, violations, failed := policy.Check(ctx, r.Text, attempt)
+		report.Violations = append(report.Violations, violations...)
+		r.Text = text
+		if !failed {
+			report.Passed = true
+			break
+		}
+		if attempt > policy.Reasks() {
+			break
+		}
+		// Continue the conversation with the failed answer as the model sent it
+		// (still redacted) and the failures to fix.
+		reask := guardrail.ReaskPrompt(report.Failures(attempt))
+		if s != nil {
+			reask = s.Redact(reask)
+		}
+		req.Messages = append(req.Messages,
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: r.Raw.Choices[0].Message.Content},
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: reask},
+		)
+		next, err := a.complete(ctx, req)
+		if err != nil {
+			r.Tokens, r.Guardrails = tokens, report
+			return r, err
+		}
+		next.Injection = r.Injection
+		finishRedaction(s, p, &next)
+		r = next
+	}
+	r.Tokens, r.Guardrails = tokens, report
+	if report.Passed {
+		return r, nil
+	}
+	if policy.Fallback == nil {
+		return r, &guardrail.Error{Report: *report}
+	}
+	text, err := policy.Fallback(ctx, *report)
+	if err != nil {
+		return r, err
+	}
+	r.Text, report.Fallback = text, true
+	return r, nil
+}
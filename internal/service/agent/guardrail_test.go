@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go-azure-openai/internal/service/guardrail"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// scriptedChat answers successive chat calls with the given contents.
+type scriptedChat struct {
+	fakeClient
+	answers []string
+}
+
+func (s *scriptedChat) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	s.reqs = append(s.reqs, req)
+	text := s.answers[min(len(s.reqs), len(s.answers))-1]
+	return openai.ChatCompletionResponse{
+		Model:   "gpt-test",
+		Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: "assistant", Content: text}, FinishReason: "stop"}},
+		Usage:   openai.Usage{TotalTokens: 10},
+	}, nil
+}
+
+var scoreBelow10 = guardrail.NumberRange("score", 0, 10)
+
+func TestGuardrails_ReaskThenPass(t *testing.T) {
+	c := &scriptedChat{answers: []string{`{"score": 12, "feedback": "Damn good"}`, `{"score": 9, "feedback": "Damn good"}`}}
+	a := &Agent{cfg: Config{Model: "gpt-test"}, client: c}
+	res, err := a.ChatStructured(context.Background(), "Grade it",
+		WithGuardrails(guardrail.Policy{Validators: []guardrail.Validator{guardrail.NoProfanity(), scoreBelow10}}))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if res.Text != `{"score": 9, "feedback": "D*** good"}` || res.Tokens != 20 {
+		t.Fatalf("unexpected result %q tokens=%d", res.Text, res.Tokens)
+	}
+	rep := res.Guardrails
+	if rep == nil || !rep.Passed || rep.Attempts != 2 || len(rep.Violations) != 3 || len(rep.Failures(1)) != 1 {
+		t.Fatalf("unexpected report %+v", rep)
+	}
+	reask := c.reqs[1].Messages
+	if len(reask) != 3 || reask[1].Role != openai.ChatMessageRoleAssistant || !strings.Contains(reask[2].Content, "score is 12, must be between 0 and 10") {
+		t.Fatalf("unexpected re-ask messages %+v", reask)
+	}
+}
+
+func TestGuardrails_FailAndFallback(t *testing.T) {
+	c := &scriptedChat{answers: []string{`{"score": 12}`}}
+	a := &Agent{cfg: Config{Model: "gpt-test"}, client: c}
+	res, err := a.ChatStructured(context.Background(), "Grade it",
+		WithGuardrails(guardrail.Policy{Validators: []guardrail.Validator{scoreBelow10}, MaxReasks: 2}))
+	var gerr *guardrail.Error
+	if !errors.As(err, &gerr) || gerr.Report.Attempts != 3 || len(c.reqs) != 3 {
+		t.Fatalf("expected guardrail error after 3 attempts, got %v (%d calls)", err, len(c.reqs))
+	}
+	if res.Text != `{"score": 12}` || res.Guardrails == nil || res.Guardrails.Passed {
+		t.Fatalf("expected the last answer with the report, got %+v", res)
+	}
+
+	c.reqs = nil
+	_, _, err = a.ChatStructuredJSON(context.Background(), "Grade it",
+		WithGuardrails(guardrail.Policy{Validators: []guardrail.Validator{scoreBelow10}, MaxReasks: -1}))
+	if !errors.As(err, &gerr) || len(c.reqs) != 1 {
+		t.Fatalf("expected failure without re-ask, got %v (%d calls)", err, len(c.reqs))
+	}
+
+	res, err = a.ChatStructured(context.Background(), "Grade it", WithGuardrails(guardrail.Policy{
+		Validators: []guardrail.Validator{scoreBelow10},
+		MaxReasks:  -1,
+		Fallback: func(_ context.Context, r guardrail.Report) (string, error) {
+			return `{"score": null, "needs_review": true}`, nil
+		},
+	}))
+	if err != nil || !res.Guardrails.Fallback || !strings.Contains(res.Text, "needs_review") {
+		t.Fatalf("expected fallback, got %+v %v", res, err)
+	}
+}
@@ -0,0 +1,203 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// HedgeBackend is a chat backend that can receive hedged duplicates.
+// *openai.Client satisfies it.
+type HedgeBackend interface {
+	CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
+	CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (*openai.ChatCompletionStream, error)
+}
+
+// HedgePolicy enables hedged requests: if the first request has not answered
+// (or sent its first stream chunk) within a delay taken from the recent latency
+// percentile, a duplicate is sent and the first success wins. Zero values use
+// the defaults noted.
+type HedgePolicy struct {
+	Percentile float64       // latency percentile used as the delay, 0..1 (default 0.95)
+	MinDelay   time.Duration // lower bound for the delay (default 500ms)
+	MaxDelay   time.Duration // upper bound, also used until MinSamples latencies are seen (default 10s)
+	MinSamples int           // latencies needed before the percentile is trusted (default 20)
+	// Backend receives the duplicate. Nil sends it to the agent's own client.
+	Backend HedgeBackend
+}
+
+func (p HedgePolicy) withDefaults() HedgePolicy {
+	if p.Percentile <= 0 || p.Percentile > 1 {
+		p.Percentile = 0.95
+	}
+	if p.MinDelay <= 0 {
+		p.MinDelay = 500 * time.Millisecond
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 10 * time.Second
+	}
+	if p.MaxDelay < p.MinDelay {
+		p.MaxDelay = p.MinDelay
+	}
+	if p.MinSamples <= 0 {
+		p.MinSamples = 20
+	}
+	return p
+}
+
+// latencyWindow keeps the most recent successful call latencies.
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+const latencyWindowSize = 200
+
+func (w *latencyWindow) observe(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.samples) < latencyWindowSize {
+		w.samples = append(w.samples, d)
+		return
+	}
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % latencyWindowSize
+}
+
+// percentile returns the q-th latency and the number of samples it is based on.
+func (w *latencyWindow) percentile(q float64) (time.Duration, int) {
+	w.mu.Lock()
+	s := append([]time.Duration(nil), w.samples...)
+	w.mu.Unlock()
+	if len(s) == 0 {
+		return 0, 0
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	i := int(q*float64(len(s)-1) + 0.5)
+	return s[i], len(s)
+}
+
+// hedgeDelay returns how long to wait before sending a duplicate.
+func (a *Agent) hedgeDelay(p HedgePolicy) time.Duration {
+	d, n := a.latency.percentile(p.Percentile)
+	if n < p.MinSamples {
+		return p.MaxDelay
+	}
+	return min(max(d, p.MinDelay), p.MaxDelay)
+}
+
+// attempt is the outcome of one (possibly duplicated) call.
+type attempt[T any] struct {
+	val     T
+	err     error
+	dup     bool
+	elapsed time.Duration
+	cancel  context.CancelFunc
+}
+
+// runHedged starts call against the primary backend and, if it has not
+// finished within delay, a duplicate against the hedge backend. The first
+// success is returned with its context still live; the caller must call its
+// cancel. Losers are cancelled and handed to discard on another goroutine.
+// An error from the primary before the delay is returned as is: hedging is
+// not a retry.
+func runHedged[T any](ctx context.Context, delay time.Duration, call func(ctx context.Context, dup bool) (T, error), onHedge func(), discard func(attempt[T])) attempt[T] {
+	results := make(chan attempt[T], 2)
+	launch := func(dup bool) {
+		actx, cancel := context.WithCancel(ctx)
+		go func() {
+			start := time.Now()
+			v, err := call(actx, dup)
+			results <- attempt[T]{val: v, err: err, dup: dup, elapsed: time.Since(start), cancel: cancel}
+		}()
+	}
+	launch(false)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	pending, hedged := 1, false
+	var failed attempt[T]
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if !hedged {
+				hedged = true
+				pending++
+				onHedge()
+				launch(true)
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				if pending > 0 {
+					go func() {
+						l := <-results
+						l.cancel()
+						discard(l)
+					}()
+				}
+				return r
+			}
+			r.cancel()
+			if failed.err == nil {
+				failed = r
+			} else {
+				discard(r)
+			}
+			if !hedged {
+				return failed
+			}
+		}
+	}
+	return failed
+}
+
+// createChat sends a chat completion through the circuit breaker, hedging it
+// when Config.Hedge is set, and records token usage.
+func (a *Agent) createChat(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	if a.cfg.Hedge == nil {
+		var resp openai.ChatCompletionResponse
+		err := a.guard(a.resolveDeployment(req.Model), func() (err error) {
+			resp, err = a.client.CreateChatCompletion(ctx, req)
+			return err
+		})
+		if err == nil {
+			a.usage.add(resp.Usage)
+		}
+		return resp, err
+	}
+	p := a.cfg.Hedge.withDefaults()
+	call := func(ctx context.Context, dup bool) (resp openai.ChatCompletionResponse, err error) {
+		if dup && p.Backend != nil {
+			return p.Backend.CreateChatCompletion(ctx, req)
+		}
+		err = a.guard(a.resolveDeployment(req.Model), func() (err error) {
+			resp, err = a.client.CreateChatCompletion(ctx, req)
+			return err
+		})
+		return resp, err
+	}
+	discard := func(l attempt[openai.ChatCompletionResponse]) {
+		if l.err == nil {
+			a.usage.add(l.val.Usage)
+		} else if errors.Is(l.err, context.Canceled) {
+			a.usage.addEstimated(estimateTokens(req))
+		}
+	}
+	r := runHedged(ctx, a.hedgeDelay(p), call, func() { a.usage.hedged(false); chargeHedge(ctx) }, discard)
+	r.cancel()
+	if r.err != nil {
+		return r.val, r.err
+	}
+	a.latency.observe(r.elapsed)
+	a.usage.add(r.val.Usage)
+	if r.dup {
+		a.usage.hedged(true)
+	}
+	return r.val, nil
+}
@@ -0,0 +1,150 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// slowClient answers the first call after delay and later calls immediately.
+// Calls honour context cancellation like the real client.
+type slowClient struct {
+	fakeClient
+	delay time.Duration
+	calls atomic.Int32
+}
+
+func (s *slowClient) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	n := s.calls.Add(1)
+	if n == 1 {
+		select {
+		case <-time.After(s.delay):
+		case <-ctx.Done():
+			return openai.ChatCompletionResponse{}, ctx.Err()
+		}
+	}
+	resp := textResponse(fmt.Sprintf("answer %d", n))
+	resp.Usage = openai.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+	return resp, nil
+}
+
+func TestChatStructured_HedgeWins(t *testing.T) {
+	c := &slowClient{delay: 2 * time.Second}
+	a := &Agent{cfg: Config{Model: "gpt-test", Timeout: 5 * time.Second, Hedge: &HedgePolicy{MinDelay: 20 * time.Millisecond, MaxDelay: 20 * time.Millisecond}}, client: c}
+	start := time.Now()
+	res, err := a.ChatStructured(context.Background(), "hi")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if res.Text != "answer 2" || time.Since(start) > time.Second {
+		t.Fatalf("expected the duplicate to win quickly, got %q after %s", res.Text, time.Since(start))
+	}
+	// the cancelled primary is accounted asynchronously
+	deadline := time.Now().Add(time.Second)
+	for a.Usage().Requests < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	u := a.Usage()
+	if u.HedgedRequests != 1 || u.HedgeWins != 1 || u.TotalTokens != 15 || u.EstimatedTokens == 0 || u.Requests != 2 {
+		t.Fatalf("unexpected usage: %+v", u)
+	}
+}
+
+func TestChatStructured_NoHedgeWhenFast(t *testing.T) {
+	c := &slowClient{delay: 0}
+	a := &Agent{cfg: Config{Model: "gpt-test", Timeout: 5 * time.Second, Hedge: &HedgePolicy{MinDelay: time.Second}}, client: c}
+	if _, err := a.ChatStructured(context.Background(), "hi"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if c.calls.Load() != 1 || a.Usage().HedgedRequests != 0 {
+		t.Fatalf("unexpected duplicate: calls=%d usage=%+v", c.calls.Load(), a.Usage())
+	}
+}
+
+func TestHedgeDelay_Percentile(t *testing.T) {
+	a := &Agent{}
+	p := HedgePolicy{Percentile: 0.9, MinDelay: time.Millisecond, MaxDelay: time.Minute, MinSamples: 10}.withDefaults()
+	if d := a.hedgeDelay(p); d != time.Minute {
+		t.Fatalf("expected max delay without samples, got %s", d)
+	}
+	for i := 1; i <= 10; i++ {
+		a.latency.observe(time.Duration(i) * time.Second)
+	}
+	if d := a.hedgeDelay(p); d != 9*time.Second {
+		t.Fatalf("expected p90 of 9s, got %s", d)
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, tok := range []string{"hel", "lo"} {
+			fmt.Fprintf(w, "data: {\"model\":\"gpt-test\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", tok)
+		}
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":2,\"total_tokens\":5}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+	oc := openai.DefaultConfig("")
+	oc.BaseURL = srv.URL
+	a := &Agent{cfg: Config{Model: "gpt-test", Timeout: 5 * time.Second}, client: openai.NewClientWithConfig(oc)}
+
+	var deltas []string
+	res, err := a.ChatStream(context.Background(), "hi", func(d string) bool {
+		deltas = append(deltas, d)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if res.Text != "hello" || len(deltas) != 2 || res.FinishReason != "stop" || res.Tokens != 5 {
+		t.Fatalf("unexpected stream result: %+v %v", res, deltas)
+	}
+}
+
+func TestChatStream_HedgeWaitsForFirstToken(t *testing.T) {
+	var calls atomic.Int32
+	var body atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		b, _ := io.ReadAll(r.Body)
+		body.Store(string(b))
+		w.Header().Set("Content-Type", "text/event-stream")
+		// the role arrives at once; the first request's content is late
+		fmt.Fprint(w, "data: {\"model\":\"gpt-test\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		if n == 1 {
+			select {
+			case <-time.After(2 * time.Second):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"answer %d\"},\"finish_reason\":\"stop\"}]}\n\n", n)
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":2,\"total_tokens\":5}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+	oc := openai.DefaultConfig("")
+	oc.BaseURL = srv.URL
+	a := &Agent{cfg: Config{Model: "gpt-test", Timeout: 5 * time.Second, Hedge: &HedgePolicy{MinDelay: 50 * time.Millisecond, MaxDelay: 50 * time.Millisecond}}, client: openai.NewClientWithConfig(oc)}
+
+	res, err := a.ChatStream(context.Background(), "hi", nil)
+	if err != nil || res.Text != "answer 2" || res.Tokens != 5 {
+		t.Fatalf("expected the duplicate to win on its first token, got %+v %v", res, err)
+	}
+	if !strings.Contains(body.Load().(string), `"stream_options":{"include_usage":true}`) {
+		t.Fatalf("usage not requested: %s", body.Load())
+	}
+	if u := a.Usage(); u.HedgeWins != 1 || u.TotalTokens != 5 {
+		t.Fatalf("expected the winner's reported usage, got %+v", u)
+	}
+}
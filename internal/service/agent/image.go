@@ -0,0 +1,276 @@
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// imageClient is implemented by clients that support image generation.
+type imageClient interface {
+	CreateImage(ctx context.Context, req openai.ImageRequest) (openai.ImageResponse, error)
+}
+
+// ImageFormat selects how GenerateImage returns images.
+type ImageFormat string
+
+const (
+	ImageFormatURL    ImageFormat = "url"      // short-lived URLs hosted by the service
+	ImageFormatBase64 ImageFormat = "b64_json" // base64-encoded PNG in the response
+)
+
+// ImageOption allows customizing a single GenerateImage call.
+type ImageOption func(*imageParams)
+
+type imageParams struct {
+	size    string
+	quality string
+	style   string
+	n       int
+	format  ImageFormat
+}
+
+// WithImageSize sets the image size, e.g. "1024x1024" (default) or "1792x1024".
+func WithImageSize(s string) ImageOption { return func(p *imageParams) { p.size = s } }
+
+// WithImageQuality sets the quality: "standard" (default) or "hd" for dall-e-3,
+// "low", "medium" or "high" for gpt-image-1.
+func WithImageQuality(q string) ImageOption { return func(p *imageParams) { p.quality = q } }
+
+// WithImageStyle sets the dall-e-3 style, "vivid" or "natural".
+func WithImageStyle(s string) ImageOption { return func(p *imageParams) { p.style = s } }
+
+// WithImageCount sets how many images to generate, 1 to 10 (default 1). Each
+// image is a separate request because dall-e-3 returns one per request.
+func WithImageCount(n int) ImageOption { return func(p *imageParams) { p.n = n } }
+
+// WithImageFormat selects URLs (default) or base64 data.
+func WithImageFormat(f ImageFormat) ImageOption { return func(p *imageParams) { p.format = f } }
+
+// Image is one generated image. Exactly one of URL and B64JSON is set,
+// following the requested format.
+type Image struct {
+	URL           string `json:"url,omitempty"`
+	B64JSON       string `json:"b64_json,omitempty"`
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
+	StoredID      string `json:"stored_id,omitempty"` // set when Config.ImageStore saved it
+}
+
+// ImageResult is the result of GenerateImage.
+type ImageResult struct {
+	Prompt  string    `json:"prompt"`
+	Size    string    `json:"size"`
+	Quality string    `json:"quality"`
+	Images  []Image   `json:"images"`
+	Created time.Time `json:"created"`
+}
+
+// GenerateImage creates images for prompt with Config.ImageDeployment. When
+// Config.ImageStore is set every image is saved with its prompt; URL results
+// are downloaded for this because the service deletes them after a day.
+//
+// A prompt or image rejected by the content filter returns a *ContentFilterError.
+func (a *Agent) GenerateImage(ctx context.Context, prompt string, opts ...ImageOption) (_ ImageResult, err error) {
+	var empty ImageResult
+	if a == nil || a.client == nil {
+		return empty, errors.New("agent not initialized")
+	}
+	ic, ok := a.client.(imageClient)
+	if !ok {
+		return empty, errors.New("client does not support image generation")
+	}
+	if a.cfg.ImageDeployment == "" {
+		return empty, errors.New("missing image deployment (set AZURE_OPENAI_IMAGE_DEPLOYMENT)")
+	}
+	if strings.TrimSpace(prompt) == "" {
+		return empty, errors.New("empty image prompt")
+	}
+	p := imageParams{size: openai.CreateImageSize1024x1024, quality: openai.CreateImageQualityStandard, n: 1, format: ImageFormatURL}
+	for _, o := range opts {
+		o(&p)
+	}
+	if p.n < 1 || p.n > 10 {
+		return empty, fmt.Errorf("image count %d out of range 1-10", p.n)
+	}
+	switch p.format {
+	case ImageFormatURL, ImageFormatBase64:
+	default:
+		return empty, fmt.Errorf("unsupported image format %q", p.format)
+	}
+
+	ctx, o := a.startOp(ctx, opImage, a.cfg.ImageDeployment, a.cfg.ImageDeployment)
+	defer func(ctx context.Context) { o.end(ctx, err, "", openai.Usage{}) }(ctx)
+
+	res := ImageResult{Prompt: prompt, Size: p.size, Quality: p.quality}
+	for len(res.Images) < p.n {
+		settle, err := a.reserveCall(ctx)
+		if err != nil {
+			return empty, err
+		}
+		cctx, cancel := context.WithTimeout(ctx, a.cfg.Timeout)
+		var resp openai.ImageResponse
+		err = a.guard(a.cfg.ImageDeployment, func() (err error) {
+			resp, err = ic.CreateImage(cctx, openai.ImageRequest{
+				Prompt:         prompt,
+				Model:          a.cfg.ImageDeployment,
+				N:              1,
+				Size:           p.size,
+				Quality:        p.quality,
+				Style:          p.style,
+				ResponseFormat: string(p.format),
+			})
+			return err
+		})
+		cancel()
+		settle(0)
+		if err != nil {
+			return empty, asContentFilterError(err)
+		}
+		if len(resp.Data) == 0 {
+			return empty, errors.New("image response has no data")
+		}
+		if res.Created.IsZero() && resp.Created > 0 {
+			res.Created = time.Unix(resp.Created, 0).UTC()
+		}
+		for _, d := range resp.Data {
+			res.Images = append(res.Images, Image{URL: d.URL, B64JSON: d.B64JSON, RevisedPrompt: d.RevisedPrompt})
+		}
+	}
+	if res.Created.IsZero() {
+		res.Created = time.Now().UTC()
+	}
+
+	if a.cfg.ImageStore != nil {
+		for i := range res.Images {
+			id, err := a.storeImage(ctx, res, res.Images[i])
+			if err != nil {
+				return res, fmt.Errorf("store image %d: %w", i, err)
+			}
+			res.Images[i].StoredID = id
+		}
+	}
+	return res, nil
+}
+
+func (a *Agent) storeImage(ctx context.Context, res ImageResult, img Image) (string, error) {
+	si := StoredImage{
+		Prompt:        res.Prompt,
+		RevisedPrompt: img.RevisedPrompt,
+		Deployment:    a.cfg.ImageDeployment,
+		Size:          res.Size,
+		Quality:       res.Quality,
+		SourceURL:     img.URL,
+		CreatedAt:     res.Created,
+	}
+	var err error
+	if img.B64JSON != "" {
+		si.Data, err = base64.StdEncoding.DecodeString(img.B64JSON)
+	} else {
+		si.Data, err = a.download(ctx, img.URL)
+	}
+	if err != nil {
+		return "", err
+	}
+	return a.cfg.ImageStore.SaveImage(ctx, si)
+}
+
+// download fetches a generated image URL through the agent's transport.
+func (a *Agent) download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	hc := a.httpClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download image: HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// ImageStore keeps generated images with the prompts that produced them.
+type ImageStore interface {
+	// SaveImage stores img and returns its ID; img.ID is ignored.
+	SaveImage(ctx context.Context, img StoredImage) (string, error)
+	// LoadImage returns a stored image with its data.
+	LoadImage(ctx context.Context, id string) (StoredImage, error)
+}
+
+// StoredImage is an image with its generation settings.
+type StoredImage struct {
+	ID            string    `json:"id"`
+	Prompt        string    `json:"prompt"`
+	RevisedPrompt string    `json:"revised_prompt,omitempty"`
+	Deployment    string    `json:"deployment"`
+	Size          string    `json:"size"`
+	Quality       string    `json:"quality"`
+	SourceURL     string    `json:"source_url,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	Data          []byte    `json:"-"`
+}
+
+// FileImageStore saves each image as dir/<id>.png next to dir/<id>.json
+// holding its prompt and settings.
+type FileImageStore struct {
+	dir string
+}
+
+// NewFileImageStore returns a store writing to dir, creating it if needed.
+func NewFileImageStore(dir string) (*FileImageStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileImageStore{dir: dir}, nil
+}
+
+// SaveImage writes the image, then its metadata, so an image with metadata is
+// always complete.
+func (s *FileImageStore) SaveImage(_ context.Context, img StoredImage) (string, error) {
+	b := make([]byte, 12)
+	rand.Read(b)
+	img.ID = img.CreatedAt.UTC().Format("20060102-150405") + "-" + hex.EncodeToString(b)
+	if err := os.WriteFile(filepath.Join(s.dir, img.ID+".png"), img.Data, 0o644); err != nil {
+		return "", err
+	}
+	meta, err := json.MarshalIndent(img, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return img.ID, os.WriteFile(filepath.Join(s.dir, img.ID+".json"), meta, 0o644)
+}
+
+// LoadImage reads an image saved by SaveImage.
+func (s *FileImageStore) LoadImage(_ context.Context, id string) (StoredImage, error) {
+	var img StoredImage
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return img, fmt.Errorf("invalid image id %q", id)
+	}
+	meta, err := os.ReadFile(filepath.Join(s.dir, id+".json"))
+	if err != nil {
+		return img, err
+	}
+	if err := json.Unmarshal(meta, &img); err != nil {
+		return img, err
+	}
+	img.Data, err = os.ReadFile(filepath.Join(s.dir, id+".png"))
+	return img, err
+}
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// fakeImageClient adds image generation to fakeClient.
+type fakeImageClient struct {
+	fakeClient
+	url  string // returned for url requests
+	err  error
+	reqs []openai.ImageRequest
+}
+
+func (f *fakeImageClient) CreateImage(ctx context.Context, req openai.ImageRequest) (openai.ImageResponse, error) {
+	f.reqs = append(f.reqs, req)
+	if f.err != nil {
+		return openai.ImageResponse{}, f.err
+	}
+	d := openai.ImageResponseDataInner{RevisedPrompt: "A photo of " + req.Prompt}
+	if req.ResponseFormat == openai.CreateImageResponseFormatB64JSON {
+		d.B64JSON = base64.StdEncoding.EncodeToString([]byte("png:" + req.Prompt))
+	} else {
+		d.URL = f.url
+	}
+	return openai.ImageResponse{Created: 1700000000, Data: []openai.ImageResponseDataInner{d}}, nil
+}
+
+func TestGenerateImage_Base64Stored(t *testing.T) {
+	store, err := NewFileImageStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	f := &fakeImageClient{}
+	a := &Agent{cfg: Config{Model: "gpt-test", ImageDeployment: "dalle3", ImageStore: store}, client: f}
+
+	res, err := a.GenerateImage(context.Background(), "a busy Bangkok market", WithImageCount(2), WithImageQuality("hd"), WithImageFormat(ImageFormatBase64))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(f.reqs) != 2 || f.reqs[0].Model != "dalle3" || f.reqs[0].N != 1 || f.reqs[0].Quality != "hd" || f.reqs[0].Size != "1024x1024" {
+		t.Fatalf("unexpected requests: %+v", f.reqs)
+	}
+	if len(res.Images) != 2 || res.Images[0].B64JSON == "" || res.Images[0].StoredID == "" || res.Images[0].StoredID == res.Images[1].StoredID {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	img, err := store.LoadImage(context.Background(), res.Images[1].StoredID)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if string(img.Data) != "png:a busy Bangkok market" || img.Prompt != "a busy Bangkok market" || img.RevisedPrompt == "" || img.Quality != "hd" || img.Deployment != "dalle3" {
+		t.Fatalf("unexpected stored image: %+v", img)
+	}
+	if _, err := store.LoadImage(context.Background(), "../x"); err == nil {
+		t.Fatalf("expected invalid id error")
+	}
+}
+
+func TestGenerateImage_URLDownloadedForStore(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("png-bytes")) }))
+	defer srv.Close()
+	store, _ := NewFileImageStore(t.TempDir())
+	a := &Agent{cfg: Config{Model: "gpt-test", ImageDeployment: "dalle3", ImageStore: store}, client: &fakeImageClient{url: srv.URL + "/img.png"}}
+
+	res, err := a.GenerateImage(context.Background(), "two students at a library")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if res.Images[0].URL != srv.URL+"/img.png" {
+		t.Fatalf("expected url result, got %+v", res.Images[0])
+	}
+	img, err := store.LoadImage(context.Background(), res.Images[0].StoredID)
+	if err != nil || string(img.Data) != "png-bytes" || img.SourceURL != srv.URL+"/img.png" {
+		t.Fatalf("unexpected stored image: %+v %v", img, err)
+	}
+}
+
+func TestGenerateImage_ContentFilter(t *testing.T) {
+	apiErr := &openai.APIError{
+		Code:           "content_policy_violation",
+		Message:        "Your request was rejected as a result of our safety system.",
+		HTTPStatusCode: 400,
+		InnerError: &openai.InnerError{
+			Code:                 "ResponsibleAIPolicyViolation",
+			ContentFilterResults: openai.ContentFilterResults{Violence: openai.Violence{Filtered: true, Severity: "medium"}},
+		},
+	}
+	a := &Agent{cfg: Config{Model: "gpt-test", ImageDeployment: "dalle3"}, client: &fakeImageClient{err: apiErr}}
+	_, err := a.GenerateImage(context.Background(), "a street fight")
+	var cf *ContentFilterError
+	if !errors.As(err, &cf) {
+		t.Fatalf("expected ContentFilterError, got %v", err)
+	}
+	if cf.Code != "content_policy_violation" || len(cf.Categories) != 1 || cf.Categories[0] != "violence" {
+		t.Fatalf("unexpected content filter error: %+v", cf)
+	}
+	var unwrapped *openai.APIError
+	if !errors.As(err, &unwrapped) {
+		t.Fatalf("expected the API error to be wrapped")
+	}
+
+	// other API errors are returned unchanged
+	other := &openai.APIError{Code: "rate_limit_exceeded", HTTPStatusCode: 429}
+	a.client = &fakeImageClient{err: other}
+	if _, err := a.GenerateImage(context.Background(), "a market"); !errors.Is(err, other) || errors.As(err, &cf) {
+		t.Fatalf("expected the original error, got %v", err)
+	}
+}
+
+func TestGenerateImage_Validation(t *testing.T) {
+	a := &Agent{cfg: Config{Model: "gpt-test", ImageDeployment: "dalle3"}, client: &fakeImageClient{}}
+	if _, err := a.GenerateImage(context.Background(), "x", WithImageCount(0)); err == nil {
+		t.Fatalf("expected count error")
+	}
+	if _, err := a.GenerateImage(context.Background(), "x", WithImageFormat("gif")); err == nil {
+		t.Fatalf("expected format error")
+	}
+	a.cfg.ImageDeployment = ""
+	if _, err := a.GenerateImage(context.Background(), "x"); err == nil {
+		t.Fatalf("expected missing deployment error")
+	}
+	a = &Agent{cfg: Config{Model: "gpt-test", ImageDeployment: "dalle3"}, client: &fakeClient{}}
+	if _, err := a.GenerateImage(context.Background(), "x"); err == nil {
+		t.Fatalf("expected unsupported client error")
+	}
+}
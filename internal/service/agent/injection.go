@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"go-azure-openai/internal/service/injection"
+	"go-azure-openai/internal/service/prompt"
+	"go-azure-openai/internal/service/redact"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// WithInjectionClassifier sets the classifier run on untrusted content.
+func WithInjectionClassifier(c injection.Classifier) Option {
+	return func(cfg *Config) { cfg.InjectionClassifier = c }
+}
+
+// WithSpotlight sets how untrusted content is marked in the prompt.
+func WithSpotlight(m injection.Mode) Option { return func(c *Config) { c.Spotlight = m } }
+
+// WithUntrustedContent appends text the model must evaluate but never obey,
+// such as a student answer. It is classified for injection attempts, has its
+// section markers escaped and is spotlighted; the verdict is returned in
+// ChatResult.Injection.
+func WithUntrustedContent(text string) ChatOption {
+	return func(p *chatParams) { p.untrusted = text; p.hasUntrusted = true }
+}
+
+// InjectionClassifier returns an LLM-based injection classifier that uses this
+// agent as the judge. Chain it after injection.Heuristic for paraphrased attacks.
+func (a *Agent) InjectionClassifier(threshold float64) injection.Classifier {
+	return injection.LLM(func(ctx context.Context, system, user string) (string, error) {
+		res, err := a.ChatStructured(ctx, user, WithSystem(system), WithTemperature(0))
+		return res.Text, err
+	}, threshold)
+}
+
+// isolateUntrusted classifies p.untrusted and adds it to req in isolated form.
+// With redaction on, the text is redacted first so neither the classifier nor
+// an encoded spotlight can leak personal data.
+func (a *Agent) isolateUntrusted(ctx context.Context, req *openai.ChatCompletionRequest, p chatParams, s *redact.Session) (*injection.Verdict, error) {
+	if !p.hasUntrusted {
+		return nil, nil
+	}
+	text := p.untrusted
+	if s != nil {
+		text = s.Redact(text)
+	}
+	var verdict *injection.Verdict
+	if !a.cfg.DryRun {
+		cl := a.cfg.InjectionClassifier
+		if cl == nil {
+			cl = injection.Heuristic(0)
+		}
+		v, err := cl.Classify(ctx, text)
+		if err != nil {
+			return nil, fmt.Errorf("injection classifier: %w", err)
+		}
+		verdict = &v
+	}
+	content, instruction := injection.Spotlight(prompt.EscapeSectionMarkers(text), a.cfg.Spotlight)
+
+	if len(req.Messages) > 0 && req.Messages[0].Role == openai.ChatMessageRoleSystem {
+		req.Messages[0].Content += "\n\n" + instruction
+	} else {
+		sys := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: instruction}
+		req.Messages = append([]openai.ChatCompletionMessage{sys}, req.Messages...)
+	}
+	user := &req.Messages[len(req.Messages)-1]
+	if len(user.MultiContent) > 0 {
+		user.MultiContent = append(user.MultiContent, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: content})
+	} else {
+		user.Content += "\n\n" + content
+	}
+	return verdict, nil
+}
@@ -0,0 +1,183 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyProvider supplies the API keys in preference order, e.g. the primary
+// and secondary key of an Azure resource. Keys is called before every
+// request, so providers that reload from a source pick up rotated keys
+// without a restart.
+type KeyProvider interface {
+	Keys() ([]string, error)
+}
+
+// StaticKeys is a fixed key set.
+type StaticKeys []string
+
+func (k StaticKeys) Keys() ([]string, error) { return k, nil }
+
+// EnvKeys reads keys from the named environment variables on every call;
+// unset variables are skipped.
+func EnvKeys(names ...string) KeyProvider { return envKeys(names) }
+
+type envKeys []string
+
+func (e envKeys) Keys() ([]string, error) {
+	var keys []string
+	for _, name := range e {
+		if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+			keys = append(keys, v)
+		}
+	}
+	return keys, nil
+}
+
+// FileKeys reads keys from a file with one key per line; blank lines and
+// lines starting with # are ignored. The file is re-read when its
+// modification time or size changes.
+func FileKeys(path string) KeyProvider { return &fileKeys{path: path} }
+
+type fileKeys struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	keys    []string
+}
+
+func (f *fileKeys) Keys() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	st, err := os.Stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+	if f.keys != nil && st.ModTime().Equal(f.modTime) && st.Size() == f.size {
+		return f.keys, nil
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			keys = append(keys, line)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys in %s", f.path)
+	}
+	f.keys, f.modTime, f.size = keys, st.ModTime(), st.Size()
+	return keys, nil
+}
+
+// WithKeyProvider sets where API keys come from; Config.Key is then ignored.
+func WithKeyProvider(p KeyProvider) Option { return func(c *Config) { c.KeyProvider = p } }
+
+// keyFingerprint identifies a key in logs without revealing it.
+func keyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:4])
+}
+
+// keyTransport sets the API key on each request and, when the service
+// answers 401, retries with the next key and keeps using the one that works.
+type keyTransport struct {
+	base     http.RoundTripper
+	provider KeyProvider
+	bearer   bool // Authorization: Bearer instead of api-key
+
+	mu     sync.Mutex
+	keys   []string
+	active int
+}
+
+// current returns the key set and the index of the key to try first,
+// logging when the provider's keys changed.
+func (t *keyTransport) current() ([]string, int, error) {
+	keys, err := t.provider.Keys()
+	if err != nil {
+		return nil, 0, fmt.Errorf("load api keys: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, 0, errors.New("key provider returned no keys")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !slices.Equal(keys, t.keys) {
+		active := 0
+		if t.keys != nil {
+			if i := slices.Index(keys, t.keys[t.active]); i >= 0 {
+				active = i
+			}
+			log.Printf("agent: api keys reloaded (%d keys, active %s)", len(keys), keyFingerprint(keys[active]))
+		}
+		t.keys, t.active = slices.Clone(keys), active
+	}
+	return t.keys, t.active, nil
+}
+
+// rotate makes keys[to] active after keys[from] was rejected.
+func (t *keyTransport) rotate(keys []string, from, to int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !slices.Equal(keys, t.keys) || t.active != from {
+		return // reloaded or rotated concurrently
+	}
+	t.active = to
+	log.Printf("agent: api key %s rejected with 401, rotated to key %s", keyFingerprint(keys[from]), keyFingerprint(keys[to]))
+}
+
+func (t *keyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	keys, active, err := t.current()
+	if err != nil {
+		return nil, err
+	}
+	for n := 0; ; n++ {
+		i := (active + n) % len(keys)
+		r := req.Clone(req.Context())
+		if n > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, errors.New("cannot retry request body with another key")
+			}
+			if r.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		if t.bearer {
+			r.Header.Set("Authorization", "Bearer "+keys[i])
+		} else {
+			r.Header.Set("api-key", keys[i])
+		}
+		resp, err := t.base.RoundTrip(r)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized || n == len(keys)-1 {
+			if err == nil && n > 0 && resp.StatusCode != http.StatusUnauthorized {
+				t.rotate(keys, active, i)
+			}
+			if err == nil && resp.StatusCode == http.StatusUnauthorized && len(keys) > 1 {
+				log.Printf("agent: all %d api keys rejected with 401", len(keys))
+			}
+			return resp, err
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,151 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// keyServer answers chat completions for requests carrying an accepted key
+// and records the keys it saw.
+type keyServer struct {
+	mu       sync.Mutex
+	accepted map[string]bool
+	seen     []string
+}
+
+func (s *keyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("api-key")
+	if key == "" {
+		key = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	s.mu.Lock()
+	s.seen = append(s.seen, key)
+	ok := s.accepted[key]
+	s.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"code":"401","message":"Access denied due to invalid subscription key."}}`))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(chatJSON))
+}
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestKeyProvider_FailoverOn401(t *testing.T) {
+	logs := captureLog(t)
+	ks := &keyServer{accepted: map[string]bool{"secondary-key": true}}
+	srv := httptest.NewServer(ks)
+	defer srv.Close()
+
+	a, err := New(Config{Endpoint: srv.URL, Model: "gpt-test", KeyProvider: StaticKeys{"primary-key", "secondary-key"}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if res, err := a.ChatStructured(context.Background(), "hi"); err != nil || res.Text != "via proxy" {
+			t.Fatalf("call %d: unexpected result %+v %v", i, res, err)
+		}
+	}
+	if got := strings.Join(ks.seen, ","); got != "primary-key,secondary-key,secondary-key" {
+		t.Fatalf("expected failover then sticky secondary, got %s", got)
+	}
+	out := logs.String()
+	if !strings.Contains(out, "rotated to key "+keyFingerprint("secondary-key")) || strings.Contains(out, "secondary-key") {
+		t.Fatalf("expected rotation log without the key, got %q", out)
+	}
+
+	// every key rejected: the 401 reaches the caller
+	ks.accepted = map[string]bool{}
+	_, err = a.ChatStructured(context.Background(), "hi")
+	var apiErr *openai.APIError
+	if !errors.As(err, &apiErr) || apiErr.HTTPStatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 API error, got %v", err)
+	}
+	if !strings.Contains(logs.String(), "all 2 api keys rejected") {
+		t.Fatalf("expected log of rejected keys, got %q", logs.String())
+	}
+}
+
+func TestKeyProvider_FileReload(t *testing.T) {
+	logs := captureLog(t)
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte("# primary\nold-key\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ks := &keyServer{accepted: map[string]bool{"old-key": true, "new-key": true}}
+	srv := httptest.NewServer(ks)
+	defer srv.Close()
+	hc := &http.Client{Transport: &keyTransport{base: http.DefaultTransport, provider: FileKeys(path), bearer: true}}
+
+	get := func() {
+		t.Helper()
+		resp, err := hc.Get(srv.URL)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected response %v %v", resp, err)
+		}
+		resp.Body.Close()
+	}
+	get()
+	// rotate the key in place; a later mtime and size mark the change
+	if err := os.WriteFile(path, []byte("new-key\n\nold-key-retired\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	get()
+	if got := strings.Join(ks.seen, ","); got != "old-key,new-key" {
+		t.Fatalf("expected reloaded key to be used, got %s", got)
+	}
+	if !strings.Contains(logs.String(), "api keys reloaded (2 keys, active "+keyFingerprint("new-key")+")") {
+		t.Fatalf("expected reload log, got %q", logs.String())
+	}
+
+	os.WriteFile(path, []byte("# none\n"), 0o600)
+	os.Chtimes(path, later.Add(time.Minute), later.Add(time.Minute))
+	if _, err := hc.Get(srv.URL); err == nil || !strings.Contains(err.Error(), "no keys") {
+		t.Fatalf("expected no keys error, got %v", err)
+	}
+}
+
+func TestKeyProvider_EnvAndConfig(t *testing.T) {
+	t.Setenv("TEST_KEY_A", "a")
+	t.Setenv("TEST_KEY_B", "")
+	keys, _ := EnvKeys("TEST_KEY_A", "TEST_KEY_B").Keys()
+	if len(keys) != 1 || keys[0] != "a" {
+		t.Fatalf("unexpected env keys %v", keys)
+	}
+	t.Setenv("TEST_KEY_B", "b")
+	if keys, _ := EnvKeys("TEST_KEY_A", "TEST_KEY_B").Keys(); len(keys) != 2 {
+		t.Fatalf("expected env changes to be picked up, got %v", keys)
+	}
+
+	if err := (&Config{Endpoint: "https://x", Model: "m", KeyProvider: StaticKeys{"k"}}).Validate(); err != nil {
+		t.Fatalf("expected key provider to satisfy validation, got %v", err)
+	}
+	t.Setenv("AZURE_OPENAI_KEY_SECONDARY", "second")
+	cfg := Config{}
+	cfg.LoadEnv()
+	if _, ok := cfg.KeyProvider.(envKeys); !ok {
+		t.Fatalf("expected env key provider, got %T", cfg.KeyProvider)
+	}
+}
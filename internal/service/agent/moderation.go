@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"context"
+
+	"go-azure-openai/internal/service/moderation"
+	"go-azure-openai/internal/service/redact"
+)
+
+// WithDefaultModeration moderates every chat call with p unless the call
+// sets its own policy with WithModeration.
+func WithDefaultModeration(p moderation.Policy) Option {
+	return func(c *Config) { c.Moderation = &p }
+}
+
+// WithModeration moderates this call's input (the prompt and untrusted
+// content) before it is sent and the answer after it arrives. A blocked
+// input is never sent; a blocked answer is withheld. Either way the call
+// returns a *moderation.BlockedError. The report is in ChatResult.Moderation.
+func WithModeration(p moderation.Policy) ChatOption {
+	return func(cp *chatParams) { cp.moderation = &p }
+}
+
+// withoutModeration keeps the moderator's own calls from being moderated.
+func withoutModeration() ChatOption {
+	return func(cp *chatParams) { cp.moderation = &moderation.Policy{} }
+}
+
+// ModerationClassifier returns an LLM-based moderation classifier that uses
+// this agent as the judge. The text is sent as untrusted content, so
+// instructions inside it are not followed by the judge.
+func (a *Agent) ModerationClassifier() moderation.Classifier {
+	return moderation.LLM(func(ctx context.Context, system, text string) (string, error) {
+		res, err := a.ChatStructured(ctx, "Rate the student answer.", WithSystem(system), WithUntrustedContent(text),
+			WithTemperature(0), withoutModeration())
+		return res.Text, err
+	})
+}
+
+// moderation returns the policy of a call, or nil when it is not moderated.
+func (a *Agent) moderation(p chatParams) *moderation.Policy {
+	pol := p.moderation
+	if pol == nil {
+		pol = a.cfg.Moderation
+	}
+	if pol == nil || pol.Classifier == nil {
+		return nil
+	}
+	return pol
+}
+
+// moderate checks text at stage and adds the check to rep. With redaction
+// on, the text is redacted first, since the classifier may be remote.
+func moderate(ctx context.Context, pol *moderation.Policy, rep *moderation.Report, stage moderation.Stage, text string, s *redact.Session) error {
+	skip := pol.SkipInput
+	if stage == moderation.Output {
+		skip = pol.SkipOutput
+	}
+	if skip {
+		return nil
+	}
+	if s != nil {
+		text = s.Redact(text)
+	}
+	c, err := pol.Moderate(ctx, stage, text)
+	if err != nil {
+		return err
+	}
+	rep.Add(c)
+	// notify right away, so a flag is reported even when the call fails later
+	if c.Action != moderation.Allow && pol.OnFlag != nil {
+		pol.OnFlag(ctx, *rep)
+	}
+	if c.Action == moderation.Block {
+		return &moderation.BlockedError{Report: *rep}
+	}
+	return nil
+}
+
+// moderateInput runs the input check of a call. It returns a nil report when
+// the call is not moderated.
+func (a *Agent) moderateInput(ctx context.Context, userPrompt string, p chatParams, s *redact.Session) (*moderation.Policy, *moderation.Report, error) {
+	pol := a.moderation(p)
+	if pol == nil {
+		return nil, nil, nil
+	}
+	rep := &moderation.Report{}
+	return pol, rep, moderate(ctx, pol, rep, moderation.Input, moderationInput(userPrompt, p), s)
+}
+
+// moderateOutput runs the output check of a call on r.Text, withholding the
+// text when it is blocked.
+func moderateOutput(ctx context.Context, pol *moderation.Policy, rep *moderation.Report, s *redact.Session, r *ChatResult) error {
+	if pol == nil {
+		return nil
+	}
+	r.Moderation = rep
+	if err := moderate(ctx, pol, rep, moderation.Output, r.Text, s); err != nil {
+		r.Text = ""
+		return err
+	}
+	return nil
+}
+
+// moderationInput is the text of a call that is moderated before sending.
+func moderationInput(userPrompt string, p chatParams) string {
+	if p.hasUntrusted {
+		return userPrompt + "\n\n" + p.untrusted
+	}
+	return userPrompt
+}
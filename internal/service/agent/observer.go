@@ -0,0 +1,27 @@
+package agent
+
+import "time"
+
+// Observer receives a callback when a backend call starts and finishes and on
+// every cache lookup. Implementations must be safe for concurrent use and
+// should return quickly; they run on the caller's goroutine.
+type Observer interface {
+	CallStarted(operation, deployment string)
+	CallFinished(CallStats)
+	CacheLookup(cache string, hit bool)
+}
+
+// CallStats describes one finished backend call.
+type CallStats struct {
+	Operation        string // "chat", "embeddings", "transcription", "translation", "speech" or "image_generation"
+	Deployment       string
+	Duration         time.Duration
+	PromptTokens     int
+	CompletionTokens int
+	Err              error
+}
+
+// Cache names passed to Observer.CacheLookup.
+const (
+	CacheSpeech = "speech"
+)
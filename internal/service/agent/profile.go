@@ -0,0 +1,435 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"gopkg.in/yaml.v3"
+)
+
+// ProfileSpec is a named bundle of chat settings, e.g. "b1-writing-examiner",
+// as stored in a YAML or JSON file:
+//
+//	name: b1-writing-examiner
+//	version: 3
+//	deployment: gpt-4o-exam
+//	system: |
+//	  You are a strict CEFR B1 examiner for {{.task}}.
+//	temperature: 0.2
+//	max_tokens: 800
+//	output_schema: {"score": "number", "feedback": "string"}
+//
+// Every change to a profile must raise its version.
+type ProfileSpec struct {
+	Name        string `yaml:"name" json:"name"`
+	Version     int    `yaml:"version" json:"version"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	// Deployment is the model (or Azure deployment) to use; empty uses
+	// Config.Model.
+	Deployment string `yaml:"deployment,omitempty" json:"deployment,omitempty"`
+	// System is a text/template rendered with the variables passed with
+	// WithProfileVars; a missing variable is an error.
+	System      string   `yaml:"system,omitempty" json:"system,omitempty"`
+	Temperature *float32 `yaml:"temperature,omitempty" json:"temperature,omitempty"` // nil keeps the default 0.7
+	TopP        float32  `yaml:"top_p,omitempty" json:"top_p,omitempty"`
+	MaxTokens   int      `yaml:"max_tokens,omitempty" json:"max_tokens,omitempty"`
+	// OutputSchema is a JSON schema or example object, given as a mapping or
+	// a JSON string. Profiles with a schema always answer through
+	// ChatStructuredJSON.
+	OutputSchema any           `yaml:"output_schema,omitempty" json:"output_schema,omitempty"`
+	Tools        []ProfileTool `yaml:"tools,omitempty" json:"tools,omitempty"`
+}
+
+// ProfileTool is a function tool offered by a profile.
+type ProfileTool struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	Parameters  any    `yaml:"parameters,omitempty" json:"parameters,omitempty"` // JSON schema
+}
+
+// Ref returns "name@version".
+func (s ProfileSpec) Ref() string { return s.Name + "@" + strconv.Itoa(s.Version) }
+
+// profile is a validated ProfileSpec ready to use.
+type profile struct {
+	spec   ProfileSpec
+	system *template.Template
+	schema string
+	tools  []openai.Tool
+	digest [32]byte
+}
+
+func compileProfile(spec ProfileSpec) (*profile, error) {
+	if spec.Name == "" || strings.Contains(spec.Name, "@") {
+		return nil, fmt.Errorf("profile name %q is empty or contains @", spec.Name)
+	}
+	if spec.Version <= 0 {
+		return nil, fmt.Errorf("profile %s: version must be positive", spec.Name)
+	}
+	p := &profile{spec: spec}
+	var err error
+	if p.system, err = template.New(spec.Name).Option("missingkey=error").Parse(spec.System); err != nil {
+		return nil, fmt.Errorf("profile %s: system template: %w", spec.Name, err)
+	}
+	switch s := spec.OutputSchema.(type) {
+	case nil:
+	case string:
+		if !json.Valid([]byte(s)) {
+			return nil, fmt.Errorf("profile %s: output_schema is not valid JSON", spec.Name)
+		}
+		p.schema = s
+	default:
+		b, err := json.Marshal(s)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: output_schema: %w", spec.Name, err)
+		}
+		p.schema = string(b)
+	}
+	for _, t := range spec.Tools {
+		if t.Name == "" {
+			return nil, fmt.Errorf("profile %s: tool without a name", spec.Name)
+		}
+		fd := &openai.FunctionDefinition{Name: t.Name, Description: t.Description}
+		if t.Parameters != nil {
+			b, err := json.Marshal(t.Parameters)
+			if err != nil {
+				return nil, fmt.Errorf("profile %s: tool %s parameters: %w", spec.Name, t.Name, err)
+			}
+			fd.Parameters = json.RawMessage(b)
+		}
+		p.tools = append(p.tools, openai.Tool{Type: openai.ToolTypeFunction, Function: fd})
+	}
+	canon, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %w", spec.Name, err)
+	}
+	p.digest = sha256.Sum256(canon)
+	return p, nil
+}
+
+// options turns the profile into chat options, rendering the system prompt
+// with vars.
+func (p *profile) options(vars map[string]any) ([]ChatOption, error) {
+	var sys strings.Builder
+	if err := p.system.Execute(&sys, vars); err != nil {
+		return nil, fmt.Errorf("profile %s: %w", p.spec.Ref(), err)
+	}
+	s := p.spec
+	return []ChatOption{func(cp *chatParams) {
+		cp.system = sys.String()
+		cp.model = s.Deployment
+		if s.Temperature != nil {
+			cp.temperature = *s.Temperature
+		}
+		cp.topP = s.TopP
+		cp.maxTokens = s.MaxTokens
+		cp.outputSchema = p.schema
+		cp.tools = append(cp.tools, p.tools...)
+	}}, nil
+}
+
+// ProfileRegistry holds named profiles with every version loaded so far. It
+// is safe for concurrent use; a reload never disturbs calls in flight.
+type ProfileRegistry struct {
+	dir string
+
+	mu       sync.RWMutex
+	versions map[string][]*profile // by name, ascending version
+	files    map[string]fileStamp
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewProfileRegistry returns a registry holding specs.
+func NewProfileRegistry(specs ...ProfileSpec) (*ProfileRegistry, error) {
+	r := &ProfileRegistry{versions: map[string][]*profile{}, files: map[string]fileStamp{}}
+	for _, s := range specs {
+		if err := r.Register(s); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// LoadProfiles reads every .yaml, .yml and .json file in dir, one profile
+// per file. Call Reload or Watch to pick up later changes.
+func LoadProfiles(dir string) (*ProfileRegistry, error) {
+	r, _ := NewProfileRegistry()
+	r.dir = dir
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ParseProfile reads a profile from YAML or JSON.
+func ParseProfile(data []byte) (ProfileSpec, error) {
+	var s ProfileSpec
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil {
+		return s, fmt.Errorf("parse profile: %w", err)
+	}
+	return s, nil
+}
+
+// Register adds a profile version. Registering the same version again with
+// the same content is a no-op; changed content needs a higher version.
+func (r *ProfileRegistry) Register(spec ProfileSpec) error {
+	p, err := compileProfile(spec)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	vs := r.versions[spec.Name]
+	if len(vs) > 0 {
+		latest := vs[len(vs)-1]
+		switch {
+		case spec.Version == latest.spec.Version && p.digest == latest.digest:
+			return nil
+		case spec.Version <= latest.spec.Version:
+			return fmt.Errorf("profile %s changed without a version bump (loaded version %d)", spec.Ref(), latest.spec.Version)
+		}
+		log.Printf("agent: profile %s updated from version %d to %d", spec.Name, latest.spec.Version, spec.Version)
+	}
+	r.versions[spec.Name] = append(vs, p)
+	return nil
+}
+
+// Reload re-reads the files in the registry's directory that are new or whose
+// modification time or size changed. Files that fail to load are reported in
+// the error and keep their previous version; deleting a file does not
+// unregister its profile.
+func (r *ProfileRegistry) Reload() error {
+	if r.dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, e := range entries {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		path := filepath.Join(r.dir, e.Name())
+		st, err := os.Stat(path)
+		if err != nil || st.IsDir() {
+			continue
+		}
+		stamp := fileStamp{modTime: st.ModTime(), size: st.Size()}
+		r.mu.RLock()
+		seen := r.files[path] == stamp
+		r.mu.RUnlock()
+		if seen {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err == nil {
+			var spec ProfileSpec
+			if spec, err = ParseProfile(data); err == nil {
+				err = r.Register(spec)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.Name(), err))
+			continue
+		}
+		r.mu.Lock()
+		r.files[path] = stamp
+		r.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// Watch calls Reload every interval until ctx is done, logging failures.
+func (r *ProfileRegistry) Watch(ctx context.Context, interval time.Duration) {
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if err := r.Reload(); err != nil {
+					log.Printf("agent: profile reload: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// get returns the latest version of name, or the version pinned with
+// "name@version".
+func (r *ProfileRegistry) get(ref string) (*profile, error) {
+	name, ver, pinned := strings.Cut(ref, "@")
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	vs := r.versions[name]
+	if len(vs) == 0 {
+		return nil, fmt.Errorf("unknown profile %q", name)
+	}
+	if !pinned {
+		return vs[len(vs)-1], nil
+	}
+	for _, p := range vs {
+		if strconv.Itoa(p.spec.Version) == ver {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("profile %s has no version %s", name, ver)
+}
+
+// Get returns the spec of the latest version of name, or of "name@version".
+func (r *ProfileRegistry) Get(ref string) (ProfileSpec, error) {
+	p, err := r.get(ref)
+	if err != nil {
+		return ProfileSpec{}, err
+	}
+	return p.spec, nil
+}
+
+// Names lists the registered profiles.
+func (r *ProfileRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.versions))
+	for n := range r.versions {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Versions lists the loaded versions of name in ascending order.
+func (r *ProfileRegistry) Versions(name string) []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []int
+	for _, p := range r.versions[name] {
+		out = append(out, p.spec.Version)
+	}
+	return out
+}
+
+// WithProfiles sets the registry used by Agent.Profile.
+func WithProfiles(r *ProfileRegistry) Option { return func(c *Config) { c.Profiles = r } }
+
+// WithProfileVars sets the variables a profile's system template is
+// rendered with.
+func WithProfileVars(vars map[string]any) ChatOption {
+	return func(p *chatParams) { p.profileVars = vars }
+}
+
+// ProfileAgent runs chat calls with a named profile. The profile is looked
+// up on every call, so reloaded versions apply immediately unless the name
+// pins one with "name@version". Options given to a call override the
+// profile's.
+type ProfileAgent struct {
+	a   *Agent
+	ref string
+}
+
+// Profile returns the profile called ref ("name" or "name@version") from
+// Config.Profiles. Unknown profiles fail when a call is made.
+func (a *Agent) Profile(ref string) *ProfileAgent { return &ProfileAgent{a: a, ref: ref} }
+
+// Spec returns the profile version calls would use now.
+func (pa *ProfileAgent) Spec() (ProfileSpec, error) {
+	p, err := pa.resolve()
+	if err != nil {
+		return ProfileSpec{}, err
+	}
+	return p.spec, nil
+}
+
+func (pa *ProfileAgent) resolve() (*profile, error) {
+	if pa.a == nil || pa.a.cfg.Profiles == nil {
+		return nil, errors.New("no profile registry configured")
+	}
+	return pa.a.cfg.Profiles.get(pa.ref)
+}
+
+// options resolves the profile and puts its options ahead of opts.
+func (pa *ProfileAgent) options(opts []ChatOption) (*profile, []ChatOption, error) {
+	p, err := pa.resolve()
+	if err != nil {
+		return nil, nil, err
+	}
+	var cp chatParams
+	for _, o := range opts {
+		o(&cp)
+	}
+	popts, err := p.options(cp.profileVars)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, append(popts, opts...), nil
+}
+
+// Chat returns the answer text; see ChatStructured.
+func (pa *ProfileAgent) Chat(ctx context.Context, userPrompt string, opts ...ChatOption) (string, error) {
+	r, err := pa.ChatStructured(ctx, userPrompt, opts...)
+	return r.Text, err
+}
+
+// ChatStructured sends userPrompt with the profile's settings. Profiles with
+// an output schema go through ChatStructuredJSON. ChatResult.Profile names
+// the version used.
+func (pa *ProfileAgent) ChatStructured(ctx context.Context, userPrompt string, opts ...ChatOption) (ChatResult, error) {
+	r, _, err := pa.ChatStructuredJSON(ctx, userPrompt, opts...)
+	return r, err
+}
+
+// ChatStructuredJSON is ChatStructured returning the parsed answer when the
+// profile has an output schema; the parsed value is nil otherwise.
+func (pa *ProfileAgent) ChatStructuredJSON(ctx context.Context, userPrompt string, opts ...ChatOption) (ChatResult, any, error) {
+	p, opts, err := pa.options(opts)
+	if err != nil {
+		return ChatResult{}, nil, err
+	}
+	var (
+		r      ChatResult
+		parsed any
+	)
+	if p.schema != "" {
+		r, parsed, err = pa.a.ChatStructuredJSON(ctx, userPrompt, opts...)
+	} else {
+		r, err = pa.a.ChatStructured(ctx, userPrompt, opts...)
+	}
+	r.Profile = p.spec.Ref()
+	return r, parsed, err
+}
+
+// ChatStream streams the answer with the profile's settings.
+func (pa *ProfileAgent) ChatStream(ctx context.Context, userPrompt string, handler StreamHandler, opts ...ChatOption) (ChatResult, error) {
+	p, opts, err := pa.options(opts)
+	if err != nil {
+		return ChatResult{}, err
+	}
+	r, err := pa.a.ChatStream(ctx, userPrompt, handler, opts...)
+	r.Profile = p.spec.Ref()
+	return r, err
+}
@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const examinerV1 = `name: b1-writing-examiner
+version: 1
+deployment: gpt-4o-exam
+system: |
+  You are a strict CEFR B1 examiner for {{.task}}.
+temperature: 0.2
+max_tokens: 800
+output_schema:
+  score: number
+  feedback: string
+tools:
+  - name: lookup_rubric
+    description: Fetch the rubric of a task
+    parameters:
+      type: object
+      properties:
+        task: {type: string}
+`
+
+func writeProfile(t *testing.T, path, content string, mod time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, mod, mod)
+}
+
+func TestProfile_ChatUsesSpec(t *testing.T) {
+	dir := t.TempDir()
+	writeProfile(t, filepath.Join(dir, "examiner.yaml"), examinerV1, time.Now())
+	writeProfile(t, filepath.Join(dir, "tutor.json"), `{"name": "tutor", "version": 2, "system": "Be kind.", "top_p": 0.9}`, time.Now())
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0o600)
+	reg, err := LoadProfiles(dir)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := strings.Join(reg.Names(), ","); got != "b1-writing-examiner,tutor" {
+		t.Fatalf("unexpected profiles %s", got)
+	}
+
+	f := &fakeClient{resp: openai.ChatCompletionResponse{
+		Model:   "gpt-4o",
+		Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: `{"score": 4, "feedback": "ok"}`}}},
+	}}
+	a := &Agent{cfg: Config{Model: "gpt-4o-mini", Profiles: reg}, client: f}
+	res, parsed, err := a.Profile("b1-writing-examiner").ChatStructuredJSON(context.Background(), "My holiday...",
+		WithProfileVars(map[string]any{"task": "an email to a friend"}), WithMaxTokens(300))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if res.Profile != "b1-writing-examiner@1" || parsed.(map[string]any)["score"] != 4.0 {
+		t.Fatalf("unexpected result %+v %v", res, parsed)
+	}
+	req := f.reqs[0]
+	sys := req.Messages[0].Content
+	if req.Model != "gpt-4o-exam" || req.Temperature != 0.2 || req.MaxTokens != 300 || len(req.Tools) != 1 || req.Tools[0].Function.Name != "lookup_rubric" {
+		t.Fatalf("unexpected request %+v", req)
+	}
+	if !strings.HasPrefix(sys, "You are a strict CEFR B1 examiner for an email to a friend.") || !strings.Contains(sys, `"feedback"`) {
+		t.Fatalf("expected rendered template and schema, got %q", sys)
+	}
+
+	if _, err := a.Profile("tutor").Chat(context.Background(), "hi"); err != nil || f.reqs[1].TopP != 0.9 || f.reqs[1].Model != "gpt-4o-mini" {
+		t.Fatalf("unexpected tutor request %+v %v", f.reqs[1], err)
+	}
+	if _, err := a.Profile("b1-writing-examiner").Chat(context.Background(), "hi"); err == nil || !strings.Contains(err.Error(), "task") {
+		t.Fatalf("expected missing template variable error, got %v", err)
+	}
+	if _, err := a.Profile("translator").Chat(context.Background(), "hi"); err == nil || !strings.Contains(err.Error(), "unknown profile") {
+		t.Fatalf("expected unknown profile error, got %v", err)
+	}
+}
+
+func TestProfile_VersionedReload(t *testing.T) {
+	logs := captureLog(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "examiner.yaml")
+	start := time.Now()
+	writeProfile(t, path, examinerV1, start)
+	reg, err := LoadProfiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a change without a version bump is rejected and version 1 stays
+	writeProfile(t, path, strings.Replace(examinerV1, "0.2", "0.0", 1), start.Add(time.Minute))
+	if err := reg.Reload(); err == nil || !strings.Contains(err.Error(), "without a version bump") {
+		t.Fatalf("expected version bump error, got %v", err)
+	}
+	if s, _ := reg.Get("b1-writing-examiner"); *s.Temperature != 0.2 {
+		t.Fatalf("expected version 1 to stay active, got %+v", s)
+	}
+
+	v2 := strings.Replace(strings.Replace(examinerV1, "version: 1", "version: 2", 1), "0.2", "0.0", 1)
+	writeProfile(t, path, v2, start.Add(2*time.Minute))
+	if err := reg.Reload(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := reg.Versions("b1-writing-examiner"); len(got) != 2 || got[1] != 2 {
+		t.Fatalf("unexpected versions %v", got)
+	}
+	if !strings.Contains(logs.String(), "profile b1-writing-examiner updated from version 1 to 2") {
+		t.Fatalf("expected update log, got %q", logs.String())
+	}
+
+	a := &Agent{cfg: Config{Profiles: reg}}
+	if s, _ := a.Profile("b1-writing-examiner").Spec(); s.Version != 2 {
+		t.Fatalf("expected latest version, got %d", s.Version)
+	}
+	if s, err := a.Profile("b1-writing-examiner@1").Spec(); err != nil || *s.Temperature != 0.2 {
+		t.Fatalf("expected pinned version 1, got %+v %v", s, err)
+	}
+	if _, err := a.Profile("b1-writing-examiner@7").Spec(); err == nil {
+		t.Fatalf("expected error for unknown version")
+	}
+}
+
+func TestProfile_Validation(t *testing.T) {
+	for _, spec := range []string{
+		"name: x\n",                             // no version
+		"name: x\nversion: 1\nsystem: '{{.a'\n", // bad template
+		"name: x\nversion: 1\noutput_schema: '{bad'\n",
+		"name: x\nversion: 1\ntools: [{description: no name}]\n",
+	} {
+		s, err := ParseProfile([]byte(spec))
+		if err == nil {
+			_, err = NewProfileRegistry(s)
+		}
+		if err == nil {
+			t.Fatalf("expected error for %q", spec)
+		}
+	}
+	if _, err := ParseProfile([]byte("name: x\nversion: 1\nmodel: gpt\n")); err == nil {
+		t.Fatalf("expected unknown field error")
+	}
+	if _, err := (&Agent{}).Profile("x").Spec(); err == nil {
+		t.Fatalf("expected error without a registry")
+	}
+}
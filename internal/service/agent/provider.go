@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Provider selects the API flavour the Agent talks to.
+type Provider string
+
+const (
+	// ProviderAzure is Azure OpenAI with an api-key header (default).
+	ProviderAzure Provider = "azure"
+	// ProviderAzureAD is Azure OpenAI with a Microsoft Entra ID bearer token in Config.Key.
+	ProviderAzureAD Provider = "azure_ad"
+	// ProviderOpenAI is api.openai.com. Config.Endpoint may override the base URL.
+	ProviderOpenAI Provider = "openai"
+	// ProviderCompatible is any OpenAI-compatible server (llama.cpp server, vLLM, Ollama, ...)
+	// at Config.Endpoint, e.g. http://localhost:11434/v1. Config.Key is optional.
+	ProviderCompatible Provider = "compatible"
+)
+
+// isAzure reports whether the provider uses Azure deployments and api-version.
+func (p Provider) isAzure() bool { return p == ProviderAzure || p == ProviderAzureAD }
+
+// normalizeProvider maps an empty or differently cased provider name to a known value.
+func normalizeProvider(p Provider) Provider {
+	switch v := Provider(strings.ToLower(strings.TrimSpace(string(p)))); v {
+	case "":
+		return ProviderAzure
+	case "azuread", "azure-ad":
+		return ProviderAzureAD
+	case "local":
+		return ProviderCompatible
+	default:
+		return v
+	}
+}
+
+// clientConfig builds the go-openai client configuration for cfg.Provider.
+// Deployment mapping and api-version are applied only for Azure providers.
+func clientConfig(cfg Config) (openai.ClientConfig, error) {
+	switch cfg.Provider {
+	case ProviderAzure, ProviderAzureAD:
+		oaiCfg := openai.DefaultAzureConfig(cfg.Key, cfg.Endpoint)
+		if cfg.Provider == ProviderAzureAD {
+			oaiCfg.APIType = openai.APITypeAzureAD
+		}
+		if cfg.APIVersion != "" {
+			oaiCfg.APIVersion = cfg.APIVersion
+		}
+		// Map logical model -> deployment
+		oaiCfg.AzureModelMapperFunc = func(model string) string {
+			// Always return the explicit deployment for our configured model.
+			if model == cfg.Model {
+				return cfg.Deployment
+			}
+			// fallback: echo original (allows direct deployment usage)
+			return model
+		}
+		return oaiCfg, nil
+	case ProviderOpenAI, ProviderCompatible:
+		oaiCfg := openai.DefaultConfig(cfg.Key)
+		if cfg.Endpoint != "" {
+			oaiCfg.BaseURL = strings.TrimRight(cfg.Endpoint, "/")
+		}
+		return oaiCfg, nil
+	default:
+		return openai.ClientConfig{}, fmt.Errorf("unknown provider %q", cfg.Provider)
+	}
+}
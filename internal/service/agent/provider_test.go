@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestClientConfig_Providers(t *testing.T) {
+	az, err := clientConfig(Config{Provider: ProviderAzure, Key: "k", Endpoint: "https://x.openai.azure.com", Model: "gpt", Deployment: "gpt-dep", APIVersion: "2024-06-01"})
+	if err != nil {
+		t.Fatalf("azure: %v", err)
+	}
+	if az.APIType != openai.APITypeAzure || az.APIVersion != "2024-06-01" || az.GetAzureDeploymentByModel("gpt") != "gpt-dep" {
+		t.Fatalf("unexpected azure config: %+v", az)
+	}
+	ad, _ := clientConfig(Config{Provider: ProviderAzureAD, Key: "token", Endpoint: "https://x.openai.azure.com", Model: "gpt"})
+	if ad.APIType != openai.APITypeAzureAD {
+		t.Fatalf("expected azure ad api type, got %s", ad.APIType)
+	}
+	oa, _ := clientConfig(Config{Provider: ProviderOpenAI, Key: "sk", Model: "gpt-4o-mini"})
+	if oa.APIType != openai.APITypeOpenAI || oa.BaseURL != "https://api.openai.com/v1" || oa.AzureModelMapperFunc != nil {
+		t.Fatalf("unexpected openai config: %+v", oa)
+	}
+	local, _ := clientConfig(Config{Provider: ProviderCompatible, Endpoint: "http://localhost:11434/v1/", Model: "llama3"})
+	if local.BaseURL != "http://localhost:11434/v1" || local.APIVersion != "" {
+		t.Fatalf("unexpected compatible config: %+v", local)
+	}
+	if _, err := clientConfig(Config{Provider: "bedrock"}); err == nil {
+		t.Fatalf("expected unknown provider error")
+	}
+}
+
+func TestValidate_PerProvider(t *testing.T) {
+	cases := []struct {
+		cfg Config
+		ok  bool
+	}{
+		{Config{Key: "k", Endpoint: "e", Model: "m"}, true},
+		{Config{Endpoint: "e", Model: "m"}, false},
+		{Config{Provider: ProviderOpenAI, Key: "k", Model: "m"}, true},
+		{Config{Provider: ProviderCompatible, Endpoint: "http://localhost:8080/v1", Model: "m"}, true},
+		{Config{Provider: ProviderCompatible, Model: "m"}, false},
+		{Config{Provider: "LOCAL", Endpoint: "http://localhost:8080/v1", Model: "m"}, true},
+	}
+	for i, c := range cases {
+		if err := c.cfg.Validate(); (err == nil) != c.ok {
+			t.Fatalf("case %d: expected ok=%v, got %v", i, c.ok, err)
+		}
+	}
+}
+
+func TestLoadEnv_ReadsOnlyTheProvidersVariables(t *testing.T) {
+	t.Setenv("AZURE_OPENAI_KEY", "azure-key")
+	t.Setenv("AZURE_OPENAI_ENDPOINT", "https://x.openai.azure.com")
+	t.Setenv("AZURE_OPENAI_KEY_SECONDARY", "azure-second")
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("OPENAI_BASE_URL", "http://localhost:11434/v1")
+
+	t.Setenv("AI_PROVIDER", "compatible")
+	cfg := Config{}
+	cfg.LoadEnv()
+	if cfg.Key != "" || cfg.Endpoint != "http://localhost:11434/v1" || cfg.KeyProvider != nil {
+		t.Fatalf("expected no azure settings for a compatible provider, got %+v", cfg)
+	}
+
+	t.Setenv("AI_PROVIDER", "azure")
+	cfg = Config{}
+	cfg.LoadEnv()
+	if cfg.Key != "azure-key" || cfg.Endpoint != "https://x.openai.azure.com" || cfg.KeyProvider == nil {
+		t.Fatalf("expected azure settings, got %+v", cfg)
+	}
+}
+
+func TestNewAuto_ProviderOptionSelectsTheEnv(t *testing.T) {
+	t.Setenv("AI_PROVIDER", "")
+	t.Setenv("AZURE_OPENAI_KEY", "azure-key")
+	t.Setenv("AZURE_OPENAI_ENDPOINT", "https://x.openai.azure.com")
+	t.Setenv("AZURE_OPENAI_KEY_SECONDARY", "azure-second")
+	t.Setenv("OPENAI_BASE_URL", "")
+
+	a, err := NewAuto(WithProvider(ProviderOpenAI), WithKey("k"), WithModel("gpt-4o-mini"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := a.GetConfig()
+	if cfg.Provider != ProviderOpenAI || cfg.Key != "k" || cfg.Endpoint != "" || cfg.KeyProvider != nil {
+		t.Fatalf("azure settings leaked into an openai agent: %+v", cfg)
+	}
+}
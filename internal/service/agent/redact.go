@@ -0,0 +1,55 @@
+package agent
+
+import (
+	"go-azure-openai/internal/service/redact"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// WithRedactor redacts personal data from chat prompts before they are sent.
+func WithRedactor(r *redact.Redactor) Option { return func(c *Config) { c.Redactor = r } }
+
+// WithRedactedOutput keeps placeholders such as [NAME_1] in the returned text
+// instead of restoring the original values.
+func WithRedactedOutput() ChatOption { return func(p *chatParams) { p.redactedOutput = true } }
+
+// redactRequest replaces personal data in the text of every message with
+// placeholders. It returns nil when no Redactor is configured.
+func (a *Agent) redactRequest(req *openai.ChatCompletionRequest) *redact.Session {
+	if a.cfg.Redactor == nil {
+		return nil
+	}
+	s := a.cfg.Redactor.NewSession()
+	for i := range req.Messages {
+		m := &req.Messages[i]
+		m.Content = s.Redact(m.Content)
+		if len(m.MultiContent) > 0 {
+			parts := append([]openai.ChatMessagePart(nil), m.MultiContent...)
+			for j := range parts {
+				parts[j].Text = s.Redact(parts[j].Text)
+			}
+			m.MultiContent = parts
+		}
+	}
+	return s
+}
+
+// finishRedaction restores placeholders in r unless the caller asked to keep
+// them, and attaches the session's report.
+func finishRedaction(s *redact.Session, p chatParams, r *ChatResult) {
+	if s == nil {
+		return
+	}
+	if !p.redactedOutput {
+		r.Text = s.Restore(r.Text)
+		if len(r.ToolCalls) > 0 {
+			calls := append([]openai.ToolCall(nil), r.ToolCalls...)
+			for i := range calls {
+				calls[i].Function.Arguments = s.Restore(calls[i].Function.Arguments)
+			}
+			r.ToolCalls = calls
+		}
+	}
+	rep := s.Report()
+	r.Redaction = &rep
+}
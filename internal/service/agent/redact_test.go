@@ -0,0 +1,37 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go-azure-openai/internal/service/redact"
+)
+
+func TestChatStructured_Redaction(t *testing.T) {
+	fc := &fakeClient{resp: textResponse("Dear [NAME_1], your score is 16.")}
+	a := &Agent{cfg: Config{Model: "gpt-test", Redactor: redact.New(append(redact.Default(), redact.Names("John Doe"))...)}, client: fc}
+
+	res, err := a.ChatStructured(context.Background(), "Sincerely,\nJohn Doe\njohn@example.com", WithSystem("Grade the email."))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	sent := fc.reqs[0].Messages[1].Content
+	if strings.Contains(sent, "John Doe") || strings.Contains(sent, "john@example.com") {
+		t.Fatalf("personal data sent to the backend: %q", sent)
+	}
+	if res.Text != "Dear John Doe, your score is 16." {
+		t.Fatalf("expected restored text, got %q", res.Text)
+	}
+	if res.Redaction == nil || res.Redaction.Total != 2 || res.Redaction.Restored != 1 {
+		t.Fatalf("unexpected report: %+v", res.Redaction)
+	}
+
+	res, err = a.ChatStructured(context.Background(), "John Doe", WithRedactedOutput())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if res.Text != "Dear [NAME_1], your score is 16." {
+		t.Fatalf("expected placeholders kept, got %q", res.Text)
+	}
+}
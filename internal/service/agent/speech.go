@@ -0,0 +1,159 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// speechClient is implemented by clients that support text-to-speech.
+type speechClient interface {
+	CreateSpeech(ctx context.Context, req openai.CreateSpeechRequest) (openai.RawResponse, error)
+}
+
+// SpeechFormat is the audio container returned by Speak.
+type SpeechFormat string
+
+const (
+	SpeechFormatMP3  SpeechFormat = "mp3"
+	SpeechFormatWAV  SpeechFormat = "wav"
+	SpeechFormatOpus SpeechFormat = "opus"
+)
+
+// SpeechOption allows customizing a single Speak call.
+type SpeechOption func(*speechParams)
+
+type speechParams struct {
+	voice  string
+	speed  float64
+	format SpeechFormat
+}
+
+// WithVoice selects the voice (default "alloy").
+func WithVoice(v string) SpeechOption { return func(p *speechParams) { p.voice = v } }
+
+// WithSpeed sets the playback speed, 0.25 to 4.0 (default 1.0).
+func WithSpeed(s float64) SpeechOption { return func(p *speechParams) { p.speed = s } }
+
+// WithSpeechFormat sets the audio format (default mp3).
+func WithSpeechFormat(f SpeechFormat) SpeechOption { return func(p *speechParams) { p.format = f } }
+
+// SpeechResult describes audio written by Speak.
+type SpeechResult struct {
+	Bytes  int64  `json:"bytes"`
+	Cached bool   `json:"cached"`
+	Key    string `json:"key"` // hash of text and settings; the cache file name without extension
+}
+
+// Speak renders text to audio with Config.SpeechDeployment and streams it to w.
+// When Config.SpeechCacheDir is set, audio is cached by a hash of the text,
+// voice, speed, format and deployment, and repeated calls are served from disk.
+func (a *Agent) Speak(ctx context.Context, text string, w io.Writer, opts ...SpeechOption) (_ SpeechResult, err error) {
+	var empty SpeechResult
+	if a == nil || a.client == nil {
+		return empty, errors.New("agent not initialized")
+	}
+	sc, ok := a.client.(speechClient)
+	if !ok {
+		return empty, errors.New("client does not support speech")
+	}
+	if a.cfg.SpeechDeployment == "" {
+		return empty, errors.New("missing speech deployment (set AZURE_OPENAI_TTS_DEPLOYMENT)")
+	}
+	if text == "" {
+		return empty, errors.New("empty speech text")
+	}
+	p := speechParams{voice: "alloy", speed: 1, format: SpeechFormatMP3}
+	for _, o := range opts {
+		o(&p)
+	}
+	switch p.format {
+	case SpeechFormatMP3, SpeechFormatWAV, SpeechFormatOpus:
+	default:
+		return empty, fmt.Errorf("unsupported speech format %q", p.format)
+	}
+	if p.speed < 0.25 || p.speed > 4 {
+		return empty, fmt.Errorf("speech speed %v out of range 0.25-4.0", p.speed)
+	}
+
+	res := SpeechResult{Key: a.speechKey(text, p)}
+	var cachePath string
+	if a.cfg.SpeechCacheDir != "" {
+		cachePath = filepath.Join(a.cfg.SpeechCacheDir, res.Key+"."+string(p.format))
+		f, err := os.Open(cachePath)
+		if a.cfg.Observer != nil {
+			a.cfg.Observer.CacheLookup(CacheSpeech, err == nil)
+		}
+		if err == nil {
+			defer f.Close()
+			n, err := io.Copy(w, f)
+			res.Bytes, res.Cached = n, true
+			return res, err
+		}
+	}
+
+	ctx, o := a.startOp(ctx, opSpeech, a.cfg.SpeechDeployment, a.cfg.SpeechDeployment)
+	defer func(ctx context.Context) { o.end(ctx, err, "", openai.Usage{}) }(ctx)
+	settle, err := a.reserveCall(ctx)
+	if err != nil {
+		return empty, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, a.cfg.Timeout)
+	defer cancel()
+	var body openai.RawResponse
+	err = a.guard(a.cfg.SpeechDeployment, func() (err error) {
+		body, err = sc.CreateSpeech(ctx, openai.CreateSpeechRequest{
+			Model:          openai.SpeechModel(a.cfg.SpeechDeployment),
+			Input:          text,
+			Voice:          openai.SpeechVoice(p.voice),
+			ResponseFormat: openai.SpeechResponseFormat(p.format),
+			Speed:          p.speed,
+		})
+		return err
+	})
+	settle(0)
+	if err != nil {
+		return empty, err
+	}
+	defer body.Close()
+
+	if cachePath == "" {
+		res.Bytes, err = io.Copy(w, body)
+		return res, err
+	}
+	// Stream to the caller and a temp file at once; only complete audio is cached.
+	if err := os.MkdirAll(a.cfg.SpeechCacheDir, 0o755); err != nil {
+		return empty, err
+	}
+	tmp, err := os.CreateTemp(a.cfg.SpeechCacheDir, res.Key+".*.tmp")
+	if err != nil {
+		return empty, err
+	}
+	defer os.Remove(tmp.Name())
+	res.Bytes, err = io.Copy(io.MultiWriter(w, tmp), body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return res, err
+	}
+	return res, os.Rename(tmp.Name(), cachePath)
+}
+
+// speechKey hashes everything that changes the rendered audio.
+func (a *Agent) speechKey(text string, p speechParams) string {
+	h := sha256.New()
+	for _, s := range []string{a.cfg.SpeechDeployment, p.voice, strconv.FormatFloat(p.speed, 'f', -1, 64), string(p.format), text} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
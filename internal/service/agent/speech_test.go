@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// fakeSpeechClient adds text-to-speech support to fakeClient.
+type fakeSpeechClient struct {
+	fakeClient
+	reqs []openai.CreateSpeechRequest
+}
+
+func (f *fakeSpeechClient) CreateSpeech(ctx context.Context, req openai.CreateSpeechRequest) (openai.RawResponse, error) {
+	f.reqs = append(f.reqs, req)
+	return openai.RawResponse{ReadCloser: io.NopCloser(strings.NewReader("audio:" + req.Input))}, nil
+}
+
+func TestSpeak_Cache(t *testing.T) {
+	f := &fakeSpeechClient{}
+	a := &Agent{cfg: Config{Model: "gpt-test", SpeechDeployment: "tts", SpeechCacheDir: t.TempDir()}, client: f}
+	ctx := context.Background()
+
+	var first bytes.Buffer
+	res, err := a.Speak(ctx, "Describe your hometown.", &first, WithVoice("nova"), WithSpeed(0.9))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if res.Cached || first.String() != "audio:Describe your hometown." {
+		t.Fatalf("unexpected first result: %+v %q", res, first.String())
+	}
+	if r := f.reqs[0]; r.Voice != "nova" || r.Speed != 0.9 || r.ResponseFormat != openai.SpeechResponseFormatMp3 {
+		t.Fatalf("unexpected request: %+v", r)
+	}
+
+	var second bytes.Buffer
+	res, err = a.Speak(ctx, "Describe your hometown.", &second, WithVoice("nova"), WithSpeed(0.9))
+	if err != nil || !res.Cached || second.String() != first.String() {
+		t.Fatalf("expected cache hit, got %+v %v", res, err)
+	}
+	if len(f.reqs) != 1 {
+		t.Fatalf("expected one API call, got %d", len(f.reqs))
+	}
+
+	// a different voice is a different cache entry
+	if res, _ := a.Speak(ctx, "Describe your hometown.", io.Discard, WithVoice("echo")); res.Cached {
+		t.Fatalf("expected cache miss for another voice")
+	}
+}
+
+func TestSpeak_Validation(t *testing.T) {
+	a := &Agent{cfg: Config{Model: "gpt-test", SpeechDeployment: "tts"}, client: &fakeSpeechClient{}}
+	if _, err := a.Speak(context.Background(), "hi", io.Discard, WithSpeechFormat("aac")); err == nil {
+		t.Fatalf("expected unsupported format error")
+	}
+	if _, err := a.Speak(context.Background(), "hi", io.Discard, WithSpeed(5)); err == nil {
+		t.Fatalf("expected speed range error")
+	}
+}
@@ -0,0 +1,222 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// StreamHandler receives incremental tokens. Return false to stop early.
+type StreamHandler func(delta string) bool
+
+// firstChunk is an open stream together with the chunks read from it up to
+// the first one carrying content, a tool call or a finish reason. Role-only chunks come
+// early and do not show that the model is answering.
+type firstChunk struct {
+	stream *openai.ChatCompletionStream
+	head   []openai.ChatCompletionStreamResponse
+	eof    bool // the stream ended while reading head
+}
+
+// next returns the buffered chunks, then the rest of the stream.
+func (fc *firstChunk) next() (openai.ChatCompletionStreamResponse, error) {
+	if len(fc.head) > 0 {
+		c := fc.head[0]
+		fc.head = fc.head[1:]
+		return c, nil
+	}
+	if fc.eof {
+		return openai.ChatCompletionStreamResponse{}, io.EOF
+	}
+	return fc.stream.Recv()
+}
+
+// answering reports whether c carries content, a tool call or a finish reason.
+func answering(c openai.ChatCompletionStreamResponse) bool {
+	for _, ch := range c.Choices {
+		if ch.Delta.Content != "" || len(ch.Delta.ToolCalls) > 0 || ch.FinishReason != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ChatStream sends a single-turn user prompt as a streaming request and calls
+// handler with each content delta. Returning false from handler stops early.
+// With Config.Hedge set, a duplicate is sent if the first token is late.
+// With Config.Redactor set, deltas still carry placeholders; only the returned
+// text is restored. Moderation of the answer runs after the stream ends.
+func (a *Agent) ChatStream(ctx context.Context, userPrompt string, handler StreamHandler, opts ...ChatOption) (ChatResult, error) {
+	var empty ChatResult
+	if a == nil || a.client == nil {
+		return empty, errors.New("agent not initialized")
+	}
+	p := chatParams{temperature: 0.7}
+	for _, o := range opts {
+		o(&p)
+	}
+	req, redaction, verdict, err := a.prepareRequest(ctx, userPrompt, p)
+	if err != nil {
+		return empty, err
+	}
+	req.Stream = true
+	// ask for usage in the last chunk, so streamed tokens are counted, not estimated
+	req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	if a.cfg.DryRun {
+		return ChatResult{Model: req.Model, DryRun: a.dryRun(req)}, nil
+	}
+	pol, mod, err := a.moderateInput(ctx, userPrompt, p, redaction)
+	if err != nil {
+		return ChatResult{Model: req.Model, Injection: verdict, Moderation: mod}, err
+	}
+	ctx, settle, err := a.reserve(p.budgetContext(ctx), &req)
+	if err != nil {
+		return ChatResult{Moderation: mod}, err
+	}
+	ctx, o := a.startOp(ctx, semconv.GenAIOperationNameChat, req.Model, a.resolveDeployment(req.Model), requestAttrs(req)...)
+	res, usage, err := a.stream(ctx, req, handler)
+	if err == nil && usage.TotalTokens == 0 {
+		// estimate what an answer without reported usage cost
+		prompt, completion := estimateTokens(req), len(res.Text)/4
+		settle(openai.Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}, nil)
+	} else {
+		settle(usage, err)
+	}
+	if err != nil {
+		o.end(ctx, err, "", usage)
+		return ChatResult{Moderation: mod}, err
+	}
+	o.end(ctx, nil, res.Model, usage, res.FinishReason)
+	res.Injection = verdict
+	finishRedaction(redaction, p, &res)
+	// the deltas already reached handler; a blocked answer is still withheld
+	// from the result and reported
+	if err := moderateOutput(ctx, pol, mod, redaction, &res); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// stream opens a (possibly hedged) stream for req and reads it to the end.
+func (a *Agent) stream(ctx context.Context, req openai.ChatCompletionRequest, handler StreamHandler) (ChatResult, openai.Usage, error) {
+	var empty ChatResult
+	var usage openai.Usage
+	ctx, cancel := context.WithTimeout(ctx, a.cfg.Timeout)
+	defer cancel()
+
+	open := func(ctx context.Context, c HedgeBackend, guarded bool) (fc firstChunk, err error) {
+		do := func() error {
+			fc.stream, err = c.CreateChatCompletionStream(ctx, req)
+			if err != nil {
+				return err
+			}
+			if fc.stream == nil {
+				return errors.New("client returned no stream")
+			}
+			for {
+				chunk, err := fc.stream.Recv()
+				if errors.Is(err, io.EOF) {
+					fc.eof = true
+					return nil
+				}
+				if err != nil {
+					fc.stream.Close()
+					return err
+				}
+				fc.head = append(fc.head, chunk)
+				if answering(chunk) {
+					return nil
+				}
+			}
+		}
+		if guarded {
+			err = a.guard(a.resolveDeployment(req.Model), do)
+		} else {
+			err = do()
+		}
+		return fc, err
+	}
+
+	var fc firstChunk
+	if a.cfg.Hedge == nil {
+		var err error
+		fc, err = open(ctx, a.client, true)
+		if err != nil {
+			return empty, usage, err
+		}
+	} else {
+		hp := a.cfg.Hedge.withDefaults()
+		call := func(ctx context.Context, dup bool) (firstChunk, error) {
+			if dup && hp.Backend != nil {
+				return open(ctx, hp.Backend, false)
+			}
+			return open(ctx, a.client, true)
+		}
+		discard := func(l attempt[firstChunk]) {
+			if l.val.stream != nil {
+				l.val.stream.Close()
+			}
+			a.usage.addEstimated(estimateTokens(req))
+		}
+		r := runHedged(ctx, a.hedgeDelay(hp), call, func() { a.usage.hedged(false); chargeHedge(ctx) }, discard)
+		defer r.cancel()
+		if r.err != nil {
+			return empty, usage, r.err
+		}
+		a.latency.observe(r.elapsed)
+		if r.dup {
+			a.usage.hedged(true)
+		}
+		fc = r.val
+	}
+	defer fc.stream.Close()
+
+	var text strings.Builder
+	res := ChatResult{}
+	for {
+		chunk, err := fc.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return empty, usage, err
+		}
+		if chunk.Model != "" {
+			res.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			usage = *chunk.Usage
+		}
+		stop := false
+		for _, c := range chunk.Choices {
+			if c.Index != 0 {
+				continue
+			}
+			if c.FinishReason != "" {
+				res.FinishReason = string(c.FinishReason)
+			}
+			if c.Delta.Content != "" {
+				text.WriteString(c.Delta.Content)
+				if handler != nil && !handler(c.Delta.Content) {
+					stop = true
+				}
+			}
+		}
+		if stop {
+			break
+		}
+	}
+	if usage.TotalTokens == 0 {
+		// Streams only report usage when the backend supports stream_options.
+		a.usage.addEstimated(estimateTokens(req))
+	} else {
+		a.usage.add(usage)
+	}
+	res.Text = text.String()
+	res.Tokens = usage.TotalTokens
+	return res, usage, nil
+}
@@ -0,0 +1,219 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies this package as the producer of spans and metrics.
+const instrumentationName = "go-azure-openai/internal/service/agent"
+
+// deploymentKey records the Azure deployment a request was routed to.
+const deploymentKey = attribute.Key("azure.openai.deployment")
+
+// Operations the GenAI semantic conventions have no name for yet.
+var (
+	opTranscription = semconv.GenAIOperationNameKey.String("transcription")
+	opTranslation   = semconv.GenAIOperationNameKey.String("translation")
+	opSpeech        = semconv.GenAIOperationNameKey.String("speech")
+	opImage         = semconv.GenAIOperationNameKey.String("image_generation")
+)
+
+// instruments holds the tracer and metric instruments used by an Agent.
+type instruments struct {
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+	tokens   metric.Int64Histogram
+}
+
+// telemetry returns the agent's instruments, creating them from
+// Config.TracerProvider/MeterProvider or the otel globals on first use.
+func (a *Agent) telemetry() *instruments {
+	a.telOnce.Do(func() {
+		tp, mp := a.cfg.TracerProvider, a.cfg.MeterProvider
+		if tp == nil {
+			tp = otel.GetTracerProvider()
+		}
+		if mp == nil {
+			mp = otel.GetMeterProvider()
+		}
+		meter := mp.Meter(instrumentationName)
+		in := &instruments{tracer: tp.Tracer(instrumentationName)}
+		// Instrument creation only fails for invalid names; the no-op fallback keeps calls safe.
+		var err error
+		in.duration, err = meter.Float64Histogram("gen_ai.client.operation.duration",
+			metric.WithUnit("s"),
+			metric.WithDescription("GenAI operation duration."),
+			metric.WithExplicitBucketBoundaries(0.01, 0.02, 0.04, 0.08, 0.16, 0.32, 0.64, 1.28, 2.56, 5.12, 10.24, 20.48, 40.96, 81.92))
+		if err != nil {
+			otel.Handle(err)
+		}
+		in.tokens, err = meter.Int64Histogram("gen_ai.client.token.usage",
+			metric.WithUnit("{token}"),
+			metric.WithDescription("Measures number of input and output tokens used."),
+			metric.WithExplicitBucketBoundaries(1, 4, 16, 64, 256, 1024, 4096, 16384, 65536, 262144, 1048576))
+		if err != nil {
+			otel.Handle(err)
+		}
+		a.tel = in
+	})
+	return a.tel
+}
+
+// providerAttr maps the configured provider to gen_ai.provider.name.
+func (a *Agent) providerAttr() attribute.KeyValue {
+	if a.cfg.Provider == "" || a.cfg.Provider.isAzure() {
+		return semconv.GenAIProviderNameAzureAIOpenAI
+	}
+	return semconv.GenAIProviderNameOpenAI
+}
+
+// op is one traced GenAI operation (chat, embeddings, ...).
+type op struct {
+	a     *Agent
+	span  trace.Span
+	start time.Time
+	attrs []attribute.KeyValue // shared by the span and the metrics
+
+	operation, deployment string // reported to Config.Observer
+}
+
+// startOp starts a client span named "{operation} {model}" following the
+// GenAI semantic conventions and returns the context carrying it.
+func (a *Agent) startOp(ctx context.Context, operation attribute.KeyValue, model, deployment string, extra ...attribute.KeyValue) (context.Context, *op) {
+	in := a.telemetry()
+	attrs := []attribute.KeyValue{operation, a.providerAttr(), semconv.GenAIRequestModel(model)}
+	if deployment != "" && (a.cfg.Provider == "" || a.cfg.Provider.isAzure()) {
+		attrs = append(attrs, deploymentKey.String(deployment))
+	}
+	if u, err := url.Parse(a.cfg.Endpoint); err == nil && u.Hostname() != "" {
+		attrs = append(attrs, semconv.ServerAddress(u.Hostname()))
+	}
+	ctx, span := in.tracer.Start(ctx, fmt.Sprintf("%s %s", operation.Value.AsString(), model),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(extra...))
+	o := &op{a: a, span: span, start: time.Now(), attrs: attrs, operation: operation.Value.AsString(), deployment: deployment}
+	if o.deployment == "" {
+		o.deployment = model
+	}
+	if a.cfg.Observer != nil {
+		a.cfg.Observer.CallStarted(o.operation, o.deployment)
+	}
+	return ctx, o
+}
+
+// end records the outcome, duration and token usage and ends the span.
+func (o *op) end(ctx context.Context, err error, responseModel string, usage openai.Usage, finishReasons ...string) {
+	in := o.a.telemetry()
+	if obs := o.a.cfg.Observer; obs != nil {
+		obs.CallFinished(CallStats{
+			Operation:        o.operation,
+			Deployment:       o.deployment,
+			Duration:         time.Since(o.start),
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			Err:              err,
+		})
+	}
+	attrs := o.attrs
+	if responseModel != "" {
+		attrs = append(attrs, semconv.GenAIResponseModel(responseModel))
+	}
+	if err != nil {
+		et := errorType(err)
+		attrs = append(attrs, semconv.ErrorTypeKey.String(et))
+		o.span.RecordError(err)
+		o.span.SetStatus(codes.Error, err.Error())
+		o.span.SetAttributes(semconv.ErrorTypeKey.String(et))
+	} else {
+		o.span.SetAttributes(
+			semconv.GenAIUsageInputTokens(usage.PromptTokens),
+			semconv.GenAIUsageOutputTokens(usage.CompletionTokens),
+		)
+		if responseModel != "" {
+			o.span.SetAttributes(semconv.GenAIResponseModel(responseModel))
+		}
+		if len(finishReasons) > 0 && finishReasons[0] != "" {
+			o.span.SetAttributes(semconv.GenAIResponseFinishReasons(finishReasons...))
+		}
+	}
+	if in.duration != nil {
+		in.duration.Record(ctx, time.Since(o.start).Seconds(), metric.WithAttributes(attrs...))
+	}
+	if err == nil && in.tokens != nil {
+		if usage.PromptTokens > 0 {
+			in.tokens.Record(ctx, int64(usage.PromptTokens), metric.WithAttributes(append(attrs, semconv.GenAITokenTypeInput)...))
+		}
+		if usage.CompletionTokens > 0 {
+			in.tokens.Record(ctx, int64(usage.CompletionTokens), metric.WithAttributes(append(attrs, semconv.GenAITokenTypeOutput)...))
+		}
+	}
+	o.span.End()
+}
+
+// errorType is a low-cardinality error.type value for err.
+func errorType(err error) string {
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.As(err, &apiErr) && apiErr.HTTPStatusCode != 0:
+		return fmt.Sprint(apiErr.HTTPStatusCode)
+	case errors.As(err, &reqErr) && reqErr.HTTPStatusCode != 0:
+		return fmt.Sprint(reqErr.HTTPStatusCode)
+	}
+	return "_OTHER"
+}
+
+// requestAttrs returns span attributes describing a chat request.
+func requestAttrs(req openai.ChatCompletionRequest) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{semconv.GenAIRequestTemperature(float64(req.Temperature))}
+	if req.MaxTokens > 0 {
+		attrs = append(attrs, semconv.GenAIRequestMaxTokens(req.MaxTokens))
+	}
+	if req.Stream {
+		attrs = append(attrs, attribute.Bool("gen_ai.request.stream", true))
+	}
+	return attrs
+}
+
+// ToolFunc executes one tool call and returns its result for the model.
+type ToolFunc func(ctx context.Context, call openai.ToolCall) (string, error)
+
+// ExecuteTool runs fn for a tool call returned in ChatResult.ToolCalls inside an
+// "execute_tool {name}" span, so tool time shows up in the same trace as the chat.
+func (a *Agent) ExecuteTool(ctx context.Context, call openai.ToolCall, fn ToolFunc) (string, error) {
+	ctx, span := a.telemetry().tracer.Start(ctx, "execute_tool "+call.Function.Name,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			semconv.GenAIOperationNameExecuteTool,
+			semconv.GenAIToolName(call.Function.Name),
+			semconv.GenAIToolCallID(call.ID),
+			semconv.GenAIToolType("function"),
+		))
+	defer span.End()
+	out, err := fn(ctx, call)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(semconv.ErrorTypeKey.String("_OTHER"))
+	}
+	return out, err
+}
@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestChatStructured_Telemetry(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	resp := textResponse("hello")
+	resp.Usage = openai.Usage{PromptTokens: 7, CompletionTokens: 3, TotalTokens: 10}
+	a := &Agent{
+		cfg: Config{
+			Model: "gpt-test", Deployment: "gpt-dep", Endpoint: "https://x.openai.azure.com",
+			TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)),
+			MeterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+		},
+		client: &fakeClient{resp: resp},
+	}
+	if _, err := a.ChatStructured(context.Background(), "hi"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	spans := rec.Ended()
+	if len(spans) != 1 || spans[0].Name() != "chat gpt-test" {
+		t.Fatalf("unexpected spans: %v", spans)
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range spans[0].Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	if attrs["gen_ai.usage.input_tokens"].AsInt64() != 7 || attrs["gen_ai.usage.output_tokens"].AsInt64() != 3 {
+		t.Fatalf("token attributes missing: %v", attrs)
+	}
+	if attrs["azure.openai.deployment"].AsString() != "gpt-dep" || attrs["gen_ai.provider.name"].AsString() != "azure.ai.openai" {
+		t.Fatalf("deployment/provider attributes missing: %v", attrs)
+	}
+	if fr := attrs["gen_ai.response.finish_reasons"].AsStringSlice(); len(fr) != 1 || fr[0] != "stop" {
+		t.Fatalf("finish reason missing: %v", fr)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]bool{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			found[m.Name] = true
+		}
+	}
+	if !found["gen_ai.client.operation.duration"] || !found["gen_ai.client.token.usage"] {
+		t.Fatalf("expected duration and token metrics, got %v", found)
+	}
+}
+
+func TestExecuteTool_Span(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	a := &Agent{cfg: Config{TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))}}
+	call := openai.ToolCall{ID: "call_1", Function: openai.FunctionCall{Name: "lookup_rubric"}}
+	out, err := a.ExecuteTool(context.Background(), call, func(ctx context.Context, c openai.ToolCall) (string, error) {
+		return "ok", nil
+	})
+	if err != nil || out != "ok" {
+		t.Fatalf("unexpected tool result: %q %v", out, err)
+	}
+	if spans := rec.Ended(); len(spans) != 1 || spans[0].Name() != "execute_tool lookup_rubric" {
+		t.Fatalf("unexpected spans: %v", spans)
+	}
+}
+
+type recordingObserver struct {
+	started  []string
+	finished []CallStats
+}
+
+func (r *recordingObserver) CallStarted(operation, deployment string) {
+	r.started = append(r.started, operation+" "+deployment)
+}
+func (r *recordingObserver) CallFinished(s CallStats) { r.finished = append(r.finished, s) }
+func (r *recordingObserver) CacheLookup(string, bool) {}
+
+func TestChatStructured_Observer(t *testing.T) {
+	obs := &recordingObserver{}
+	resp := textResponse("hello")
+	resp.Usage = openai.Usage{PromptTokens: 7, CompletionTokens: 3, TotalTokens: 10}
+	a := &Agent{cfg: Config{Model: "gpt-test", Deployment: "gpt-dep", Observer: obs}, client: &fakeClient{resp: resp}}
+	if _, err := a.ChatStructured(context.Background(), "hi"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(obs.started) != 1 || obs.started[0] != "chat gpt-dep" {
+		t.Fatalf("unexpected starts: %v", obs.started)
+	}
+	if len(obs.finished) != 1 || obs.finished[0].PromptTokens != 7 || obs.finished[0].CompletionTokens != 3 || obs.finished[0].Err != nil {
+		t.Fatalf("unexpected stats: %+v", obs.finished)
+	}
+}
+
+func TestObserver_AudioSpeechAndImages(t *testing.T) {
+	obs := &recordingObserver{}
+	ctx := context.Background()
+	cfg := Config{Model: "gpt-test", WhisperDeployment: "whisper", SpeechDeployment: "tts", ImageDeployment: "dalle", Observer: obs}
+	if _, err := (&Agent{cfg: cfg, client: &fakeAudioClient{}}).Transcribe(ctx, writeWAV(t, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&Agent{cfg: cfg, client: &fakeSpeechClient{}}).Speak(ctx, "hello", io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	_, err := (&Agent{cfg: cfg, client: &fakeImageClient{err: errors.New("boom")}}).GenerateImage(ctx, "a cat")
+	if err == nil {
+		t.Fatal("expected an image error")
+	}
+	want := []string{"transcription whisper", "speech tts", "image_generation dalle"}
+	if strings.Join(obs.started, ",") != strings.Join(want, ",") || len(obs.finished) != 3 || obs.finished[2].Err == nil {
+		t.Fatalf("unexpected observed calls: %v %+v", obs.started, obs.finished)
+	}
+}
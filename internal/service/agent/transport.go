@@ -0,0 +1,170 @@
+package agent
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// TransportConfig configures the HTTP client used for API calls. Zero fields
+// use the defaults noted; after New, Config.Transport (see GetConfig) holds the
+// settings in effect.
+type TransportConfig struct {
+	// ProxyURL routes requests through a proxy, e.g. http://egress.corp:3128.
+	// Empty uses HTTPS_PROXY/NO_PROXY from the environment; "direct" disables
+	// proxying.
+	ProxyURL string `json:"proxy_url,omitempty"`
+	// CAFile is a PEM bundle that replaces the system roots for server
+	// verification, pinning the accepted CAs.
+	CAFile string `json:"ca_file,omitempty"`
+	// ClientCertFile and ClientKeyFile enable mutual TLS.
+	ClientCertFile string `json:"client_cert_file,omitempty"`
+	ClientKeyFile  string `json:"client_key_file,omitempty"`
+	MinTLSVersion  uint16 `json:"min_tls_version,omitempty"` // default TLS 1.2
+
+	MaxIdleConns        int           `json:"max_idle_conns"`          // default 100
+	MaxIdleConnsPerHost int           `json:"max_idle_conns_per_host"` // default 16
+	MaxConnsPerHost     int           `json:"max_conns_per_host"`      // 0 means no limit
+	IdleConnTimeout     time.Duration `json:"idle_conn_timeout"`       // default 90s
+	DialTimeout         time.Duration `json:"dial_timeout"`            // default 10s
+	TLSHandshakeTimeout time.Duration `json:"tls_handshake_timeout"`   // default 10s
+	// AttemptTimeout bounds each HTTP attempt, including reading the body;
+	// Config.Timeout bounds the whole call. 0 means no per-attempt limit,
+	// which streaming responses need.
+	AttemptTimeout time.Duration `json:"attempt_timeout,omitempty"`
+	// ResponseHeaderTimeout bounds the wait for response headers and so also
+	// suits streaming. 0 means no limit.
+	ResponseHeaderTimeout time.Duration `json:"response_header_timeout,omitempty"`
+
+	DisableHTTP2 bool `json:"disable_http2,omitempty"`
+	// HTTP2PingInterval sends a health-check ping on an HTTP/2 connection
+	// idle this long, and HTTP2PingTimeout closes it if no reply comes in time
+	// (default 15s). This drops connections silently cut by proxies.
+	HTTP2PingInterval time.Duration `json:"http2_ping_interval,omitempty"`
+	HTTP2PingTimeout  time.Duration `json:"http2_ping_timeout,omitempty"`
+}
+
+// WithTransport sets the HTTP transport configuration.
+func WithTransport(t TransportConfig) Option { return func(c *Config) { c.Transport = &t } }
+
+func (t TransportConfig) withDefaults() TransportConfig {
+	if t.MinTLSVersion == 0 {
+		t.MinTLSVersion = tls.VersionTLS12
+	}
+	if t.MaxIdleConns == 0 {
+		t.MaxIdleConns = 100
+	}
+	if t.MaxIdleConnsPerHost == 0 {
+		t.MaxIdleConnsPerHost = 16
+	}
+	if t.IdleConnTimeout == 0 {
+		t.IdleConnTimeout = 90 * time.Second
+	}
+	if t.DialTimeout == 0 {
+		t.DialTimeout = 10 * time.Second
+	}
+	if t.TLSHandshakeTimeout == 0 {
+		t.TLSHandshakeTimeout = 10 * time.Second
+	}
+	if t.HTTP2PingInterval > 0 && t.HTTP2PingTimeout == 0 {
+		t.HTTP2PingTimeout = 15 * time.Second
+	}
+	return t
+}
+
+// loadTransportEnv fills t from AZURE_OPENAI_PROXY, AZURE_OPENAI_CA_FILE,
+// AZURE_OPENAI_CLIENT_CERT and AZURE_OPENAI_CLIENT_KEY, and returns nil when
+// t is nil and none are set.
+func loadTransportEnv(t *TransportConfig) *TransportConfig {
+	env := TransportConfig{
+		ProxyURL:       os.Getenv("AZURE_OPENAI_PROXY"),
+		CAFile:         os.Getenv("AZURE_OPENAI_CA_FILE"),
+		ClientCertFile: os.Getenv("AZURE_OPENAI_CLIENT_CERT"),
+		ClientKeyFile:  os.Getenv("AZURE_OPENAI_CLIENT_KEY"),
+	}
+	if t == nil {
+		if env == (TransportConfig{}) {
+			return nil
+		}
+		t = &TransportConfig{}
+	}
+	if t.ProxyURL == "" {
+		t.ProxyURL = env.ProxyURL
+	}
+	if t.CAFile == "" {
+		t.CAFile = env.CAFile
+	}
+	if t.ClientCertFile == "" {
+		t.ClientCertFile = env.ClientCertFile
+	}
+	if t.ClientKeyFile == "" {
+		t.ClientKeyFile = env.ClientKeyFile
+	}
+	return t
+}
+
+// httpClient builds an HTTP client for t, which must have defaults applied.
+func (t TransportConfig) httpClient() (*http.Client, error) {
+	tlsCfg := &tls.Config{MinVersion: t.MinTLSVersion}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in CA file %s", t.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if (t.ClientCertFile == "") != (t.ClientKeyFile == "") {
+		return nil, errors.New("mTLS needs both a client certificate and a key")
+	}
+	if t.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.ClientCertFile, t.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	proxy := http.ProxyFromEnvironment
+	switch t.ProxyURL {
+	case "":
+	case "direct":
+		proxy = nil
+	default:
+		u, err := url.Parse(t.ProxyURL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", t.ProxyURL)
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	tr := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           (&net.Dialer{Timeout: t.DialTimeout, KeepAlive: 30 * time.Second}).DialContext,
+		TLSClientConfig:       tlsCfg,
+		TLSHandshakeTimeout:   t.TLSHandshakeTimeout,
+		MaxIdleConns:          t.MaxIdleConns,
+		MaxIdleConnsPerHost:   t.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       t.MaxConnsPerHost,
+		IdleConnTimeout:       t.IdleConnTimeout,
+		ResponseHeaderTimeout: t.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		ForceAttemptHTTP2:     !t.DisableHTTP2,
+	}
+	if t.DisableHTTP2 {
+		// A non-nil empty map turns off HTTP/2 negotiation.
+		tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	} else if t.HTTP2PingInterval > 0 {
+		tr.HTTP2 = &http.HTTP2Config{SendPingTimeout: t.HTTP2PingInterval, PingTimeout: t.HTTP2PingTimeout}
+	}
+	return &http.Client{Transport: tr, Timeout: t.AttemptTimeout}, nil
+}
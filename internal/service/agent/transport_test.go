@@ -0,0 +1,160 @@
+package agent
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const chatJSON = `{"id":"1","object":"chat.completion","model":"gpt-test","choices":[{"index":0,"message":{"role":"assistant","content":"via proxy"},"finish_reason":"stop"}]}`
+
+func TestNew_ProxyAndReportedTransport(t *testing.T) {
+	var target string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target = r.URL.String() // a proxy sees the absolute URL
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(chatJSON))
+	}))
+	defer proxy.Close()
+
+	a, err := New(Config{
+		Provider:  ProviderCompatible,
+		Endpoint:  "http://llm.internal.example/v1",
+		Model:     "gpt-test",
+		Transport: &TransportConfig{ProxyURL: proxy.URL, MaxConnsPerHost: 4},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	res, err := a.ChatStructured(context.Background(), "hi")
+	if err != nil || res.Text != "via proxy" {
+		t.Fatalf("unexpected result %+v %v", res, err)
+	}
+	if target != "http://llm.internal.example/v1/chat/completions" {
+		t.Fatalf("expected request through proxy, got %q", target)
+	}
+
+	tc := a.GetConfig().Transport
+	if tc == nil || tc.ProxyURL != proxy.URL || tc.MaxConnsPerHost != 4 || tc.MaxIdleConnsPerHost != 16 || tc.IdleConnTimeout != 90*time.Second || tc.MinTLSVersion != tls.VersionTLS12 {
+		t.Fatalf("unexpected reported transport: %+v", tc)
+	}
+	tc.MaxConnsPerHost = 99
+	if a.GetConfig().Transport.MaxConnsPerHost != 4 {
+		t.Fatalf("GetConfig must return a copy of the transport")
+	}
+}
+
+// writePEM writes der as a PEM block of the given type.
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTransport_PinnedCAAndMTLS(t *testing.T) {
+	dir := t.TempDir()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "exam-service"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	writePEM(t, filepath.Join(dir, "client.pem"), "CERTIFICATE", der)
+	writePEM(t, filepath.Join(dir, "client.key"), "EC PRIVATE KEY", keyDER)
+	clientCert, _ := x509.ParseCertificate(der)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", srv.Certificate().Raw)
+
+	tc := TransportConfig{
+		ProxyURL:       "direct",
+		CAFile:         filepath.Join(dir, "ca.pem"),
+		ClientCertFile: filepath.Join(dir, "client.pem"),
+		ClientKeyFile:  filepath.Join(dir, "client.key"),
+	}.withDefaults()
+	hc, err := tc.httpClient()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	resp, err := hc.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected mTLS request to succeed, got %v", err)
+	}
+	defer resp.Body.Close()
+	buf := make([]byte, 64)
+	n, _ := resp.Body.Read(buf)
+	if string(buf[:n]) != "exam-service" {
+		t.Fatalf("expected client certificate to be presented, got %q", buf[:n])
+	}
+
+	// without the client certificate the server rejects the handshake
+	tc.ClientCertFile, tc.ClientKeyFile = "", ""
+	hc, _ = tc.httpClient()
+	if _, err := hc.Get(srv.URL); err == nil {
+		t.Fatalf("expected handshake failure without client certificate")
+	}
+	// with the system roots the test server is not trusted
+	hc, _ = TransportConfig{ProxyURL: "direct"}.withDefaults().httpClient()
+	if _, err := hc.Get(srv.URL); err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Fatalf("expected certificate error, got %v", err)
+	}
+}
+
+func TestTransport_Errors(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "empty.pem"), []byte("not pem"), 0o600)
+	for _, tc := range []TransportConfig{
+		{CAFile: filepath.Join(dir, "missing.pem")},
+		{CAFile: filepath.Join(dir, "empty.pem")},
+		{ClientCertFile: "cert.pem"},
+		{ProxyURL: "://bad"},
+	} {
+		if _, err := tc.withDefaults().httpClient(); err == nil {
+			t.Fatalf("expected error for %+v", tc)
+		}
+	}
+
+	hc, err := TransportConfig{AttemptTimeout: 5 * time.Second, DisableHTTP2: true}.withDefaults().httpClient()
+	if err != nil || hc.Timeout != 5*time.Second {
+		t.Fatalf("unexpected client %+v %v", hc, err)
+	}
+	if tr := hc.Transport.(*http.Transport); tr.ForceAttemptHTTP2 || tr.TLSNextProto == nil {
+		t.Fatalf("expected HTTP/2 to be disabled")
+	}
+	hc, _ = TransportConfig{HTTP2PingInterval: 30 * time.Second}.withDefaults().httpClient()
+	if tr := hc.Transport.(*http.Transport); tr.HTTP2 == nil || tr.HTTP2.PingTimeout != 15*time.Second {
+		t.Fatalf("expected HTTP/2 health checks, got %+v", tr.HTTP2)
+	}
+}
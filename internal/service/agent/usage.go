@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"sync"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Usage is the cumulative token accounting of an Agent's chat calls.
+// Duplicates sent by hedging are included, since they are billed too.
+type Usage struct {
+	Requests         int64 `json:"requests"`
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+	// HedgedRequests counts duplicate requests sent by the hedging policy and
+	// HedgeWins how many of them answered first.
+	HedgedRequests int64 `json:"hedged_requests"`
+	HedgeWins      int64 `json:"hedge_wins"`
+	// EstimatedTokens are prompt tokens of requests cancelled before the
+	// backend reported usage, estimated from the request size.
+	EstimatedTokens int64 `json:"estimated_tokens"`
+}
+
+// usageCounter accumulates Usage; the zero value is ready to use.
+type usageCounter struct {
+	mu sync.Mutex
+	u  Usage
+}
+
+func (c *usageCounter) add(u openai.Usage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.u.Requests++
+	c.u.PromptTokens += int64(u.PromptTokens)
+	c.u.CompletionTokens += int64(u.CompletionTokens)
+	c.u.TotalTokens += int64(u.TotalTokens)
+}
+
+func (c *usageCounter) addEstimated(tokens int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.u.Requests++
+	c.u.EstimatedTokens += int64(tokens)
+}
+
+func (c *usageCounter) hedged(won bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if won {
+		c.u.HedgeWins++
+		return
+	}
+	c.u.HedgedRequests++
+}
+
+func (c *usageCounter) snapshot() Usage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.u
+}
+
+// Usage returns cumulative token usage of the agent's chat calls.
+func (a *Agent) Usage() Usage { return a.usage.snapshot() }
+
+// estimateTokens is a rough token count for a request (about four characters
+// per token, plus a small per-message overhead). It is only used where the
+// API does not report usage.
+func estimateTokens(req openai.ChatCompletionRequest) int {
+	n := 0
+	for _, m := range req.Messages {
+		chars := len(m.Content)
+		for _, part := range m.MultiContent {
+			chars += len(part.Text)
+		}
+		n += chars/4 + 4
+	}
+	return n
+}
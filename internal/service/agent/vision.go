@@ -0,0 +1,141 @@
+package agent
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ImageDetail controls how closely a vision model looks at an image.
+// Low is cheaper; High is better for small handwriting.
+type ImageDetail string
+
+const (
+	ImageDetailAuto ImageDetail = "auto"
+	ImageDetailLow  ImageDetail = "low"
+	ImageDetailHigh ImageDetail = "high"
+)
+
+// imageInput is one image attached to the user message. Exactly one of
+// url, data or path is set; files are read when the request is built.
+type imageInput struct {
+	url    string
+	data   []byte
+	path   string
+	mime   string
+	detail ImageDetail
+}
+
+// WithImageURL attaches an image by URL (https or data: URL) to the user message.
+func WithImageURL(url string, detail ImageDetail) ChatOption {
+	return func(p *chatParams) { p.images = append(p.images, imageInput{url: url, detail: detail}) }
+}
+
+// WithImageBytes attaches raw image bytes, sent inline as base64.
+// If mimeType is empty it is detected from the content.
+func WithImageBytes(data []byte, mimeType string, detail ImageDetail) ChatOption {
+	return func(p *chatParams) {
+		p.images = append(p.images, imageInput{data: data, mime: mimeType, detail: detail})
+	}
+}
+
+// WithImageFile attaches an image read from disk, sent inline as base64.
+func WithImageFile(path string, detail ImageDetail) ChatOption {
+	return func(p *chatParams) { p.images = append(p.images, imageInput{path: path, detail: detail}) }
+}
+
+// buildImageParts returns the multi-part content for a user message: the text
+// prompt first (if any), followed by the images in the order they were added.
+func buildImageParts(text string, images []imageInput) ([]openai.ChatMessagePart, error) {
+	parts := make([]openai.ChatMessagePart, 0, len(images)+1)
+	if text != "" {
+		parts = append(parts, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: text})
+	}
+	for _, img := range images {
+		url, err := img.resolveURL()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, openai.ChatMessagePart{
+			Type:     openai.ChatMessagePartTypeImageURL,
+			ImageURL: &openai.ChatMessageImageURL{URL: url, Detail: openai.ImageURLDetail(img.detail)},
+		})
+	}
+	return parts, nil
+}
+
+// resolveURL returns the URL to send for the image, encoding inline data as a data: URL.
+func (img imageInput) resolveURL() (string, error) {
+	if img.url != "" {
+		return img.url, nil
+	}
+	data, mime := img.data, img.mime
+	if img.path != "" {
+		b, err := os.ReadFile(img.path)
+		if err != nil {
+			return "", fmt.Errorf("read image: %w", err)
+		}
+		data = b
+		if mime == "" {
+			mime = mimeFromExt(img.path)
+		}
+	}
+	if len(data) == 0 {
+		return "", errors.New("empty image")
+	}
+	if mime == "" {
+		mime = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(mime, "image/") {
+		return "", fmt.Errorf("unsupported image type %q", mime)
+	}
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+func mimeFromExt(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		return "image/png"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".gif":
+		return "image/gif"
+	case ".webp":
+		return "image/webp"
+	}
+	return ""
+}
+
+// transcribeSystemPrompt asks for a faithful transcription; errors are kept
+// because the transcript is graded afterwards.
+const transcribeSystemPrompt = `You transcribe handwritten exam answers.
+Return only the text written by the student, exactly as written, keeping the original line and paragraph breaks.
+Do NOT correct spelling, grammar or punctuation, and do NOT add comments.
+Write [illegible] for words you cannot read. Ignore printed question text, page numbers and examiner marks.`
+
+// TranscribeAnswerSheet turns photographed or scanned handwritten answer pages into
+// plain text so they can go through the same evaluation prompts as typed answers.
+// Attach the pages with WithImageURL, WithImageBytes or WithImageFile; further
+// options override the defaults (for example WithSystem).
+func (a *Agent) TranscribeAnswerSheet(ctx context.Context, opts ...ChatOption) (string, error) {
+	var p chatParams
+	for _, o := range opts {
+		o(&p)
+	}
+	if len(p.images) == 0 {
+		return "", errors.New("no answer sheet image attached")
+	}
+	base := []ChatOption{WithSystem(transcribeSystemPrompt), WithTemperature(0)}
+	r, err := a.ChatStructured(ctx, "Transcribe the answer sheet in the attached image(s).", append(base, opts...)...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(r.Text), nil
+}
@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// pngHeader is enough for content sniffing to report image/png.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n0000")
+
+func TestChatStructured_Images(t *testing.T) {
+	f := &fakeClient{resp: textResponse("ok")}
+	a := &Agent{cfg: Config{Model: "gpt-test"}, client: f}
+	path := filepath.Join(t.TempDir(), "page.jpg")
+	if err := os.WriteFile(path, []byte("jpeg"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := a.ChatStructured(context.Background(), "describe",
+		WithImageURL("https://example.com/a.png", ImageDetailLow),
+		WithImageBytes(pngHeader, "", ImageDetailHigh),
+		WithImageFile(path, ImageDetailAuto),
+	)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	user := f.reqs[0].Messages[0]
+	if user.Content != "" || len(user.MultiContent) != 4 {
+		t.Fatalf("expected text + 3 image parts, got %+v", user)
+	}
+	if user.MultiContent[0].Text != "describe" {
+		t.Fatalf("text part missing: %+v", user.MultiContent[0])
+	}
+	if u := user.MultiContent[1].ImageURL; u.URL != "https://example.com/a.png" || u.Detail != openai.ImageURLDetailLow {
+		t.Fatalf("unexpected url part: %+v", u)
+	}
+	if u := user.MultiContent[2].ImageURL.URL; !strings.HasPrefix(u, "data:image/png;base64,") {
+		t.Fatalf("unexpected bytes part: %s", u)
+	}
+	if u := user.MultiContent[3].ImageURL.URL; !strings.HasPrefix(u, "data:image/jpeg;base64,") {
+		t.Fatalf("unexpected file part: %s", u)
+	}
+}
+
+func TestTranscribeAnswerSheet(t *testing.T) {
+	f := &fakeClient{resp: textResponse("  Dear Hiring Manager,\nI am exited to apply.  ")}
+	a := &Agent{cfg: Config{Model: "gpt-test"}, client: f}
+	if _, err := a.TranscribeAnswerSheet(context.Background()); err == nil {
+		t.Fatalf("expected error without image")
+	}
+	text, err := a.TranscribeAnswerSheet(context.Background(), WithImageBytes(pngHeader, "image/png", ImageDetailHigh))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if text != "Dear Hiring Manager,\nI am exited to apply." {
+		t.Fatalf("unexpected transcript: %q", text)
+	}
+	if f.reqs[0].Messages[0].Role != openai.ChatMessageRoleSystem {
+		t.Fatalf("expected transcription system prompt")
+	}
+}
+
+func textResponse(text string) openai.ChatCompletionResponse {
+	return openai.ChatCompletionResponse{
+		Model: "gpt-test",
+		Choices: []openai.ChatCompletionChoice{{
+			Message:      openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: text},
+			FinishReason: openai.FinishReason("stop"),
+		}},
+	}
+}
@@ -0,0 +1,162 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var sessionsBucket = []byte("sessions")
+
+// BoltStore keeps sessions in a single bbolt file. Writes are serialized by
+// bbolt's transaction lock, so sessions survive restarts of one replica. The
+// file is locked by the opening process; share SQLiteStore between replicas
+// instead.
+type BoltStore struct {
+	db   *bolt.DB
+	opts Options
+	now  func() time.Time
+}
+
+// NewBoltStore opens (or creates) the bbolt file at path.
+func NewBoltStore(path string, opts Options) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(sessionsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db, opts: opts, now: time.Now}, nil
+}
+
+func getRecord(b *bolt.Bucket, id string) (record, error) {
+	var r record
+	v := b.Get([]byte(id))
+	if v == nil {
+		return r, nil
+	}
+	return r, json.Unmarshal(v, &r)
+}
+
+func putRecord(b *bolt.Bucket, id string, r record) error {
+	v, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(id), v)
+}
+
+func (s *BoltStore) Load(_ context.Context, id string) (Session, error) {
+	var r record
+	err := s.db.View(func(tx *bolt.Tx) (err error) {
+		r, err = getRecord(tx.Bucket(sessionsBucket), id)
+		return err
+	})
+	if err != nil {
+		return Session{}, boltErr(err)
+	}
+	return r.live(s.now()).session(id), nil
+}
+
+func (s *BoltStore) Append(_ context.Context, id string, expect int64, msgs ...Message) (int64, error) {
+	return s.update(id, func(r record, now time.Time) (record, error) {
+		return appendTo(r, expect, now, s.opts.TTL, msgs)
+	})
+}
+
+func (s *BoltStore) Truncate(_ context.Context, id string, expect int64, keep int) (int64, error) {
+	return s.update(id, func(r record, now time.Time) (record, error) {
+		return truncate(r, expect, now, keep)
+	})
+}
+
+func (s *BoltStore) update(id string, fn func(record, time.Time) (record, error)) (int64, error) {
+	var version int64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(sessionsBucket)
+		r, err := getRecord(b, id)
+		if err != nil {
+			return err
+		}
+		now := s.now()
+		if r, err = fn(r.live(now), now); err != nil {
+			return err
+		}
+		version = r.Version
+		return putRecord(b, id, r)
+	})
+	return version, boltErr(err)
+}
+
+func (s *BoltStore) Expire(_ context.Context, id string, ttl time.Duration) error {
+	return boltErr(s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(sessionsBucket)
+		if b.Get([]byte(id)) == nil {
+			return nil
+		}
+		r, err := getRecord(b, id)
+		if err != nil {
+			return err
+		}
+		now := s.now()
+		if r.expired(now) {
+			return nil
+		}
+		r.ExpiresAt = expiry(now, ttl)
+		return putRecord(b, id, r)
+	}))
+}
+
+func (s *BoltStore) Delete(_ context.Context, id string) error {
+	return boltErr(s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Delete([]byte(id))
+	}))
+}
+
+func (s *BoltStore) DeleteExpired(context.Context) (int, error) {
+	n := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(sessionsBucket)
+		now := s.now()
+		var expired [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var r record
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			if r.expired(now) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		n = len(expired)
+		return nil
+	})
+	return n, boltErr(err)
+}
+
+func (s *BoltStore) Close() error { return s.db.Close() }
+
+func boltErr(err error) error {
+	if errors.Is(err, bolt.ErrDatabaseNotOpen) {
+		return ErrClosed
+	}
+	return err
+}
@@ -0,0 +1,33 @@
+package conversation
+
+import (
+	"context"
+
+	"go-azure-openai/internal/service/agent"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Send runs one turn of session id: it loads the history, sends userPrompt
+// with it, and appends the user prompt and the reply in a single write. If
+// another request updated the session meanwhile, ErrConflict is returned
+// together with the reply, which was not stored; callers may reload and retry.
+func Send(ctx context.Context, s Store, a *agent.Agent, id, userPrompt string, opts ...agent.ChatOption) (agent.ChatResult, error) {
+	sess, err := s.Load(ctx, id)
+	if err != nil {
+		return agent.ChatResult{}, err
+	}
+	history := make([]openai.ChatCompletionMessage, 0, len(sess.Messages))
+	for _, m := range sess.Messages {
+		history = append(history, openai.ChatCompletionMessage{Role: m.Role, Content: m.Content})
+	}
+	res, err := a.ChatStructured(ctx, userPrompt, append([]agent.ChatOption{agent.WithHistory(history...)}, opts...)...)
+	if err != nil {
+		return res, err
+	}
+	_, err = s.Append(ctx, id, sess.Version,
+		Message{Role: openai.ChatMessageRoleUser, Content: userPrompt},
+		Message{Role: openai.ChatMessageRoleAssistant, Content: res.Text},
+	)
+	return res, err
+}
@@ -0,0 +1,133 @@
+// Package conversation persists multi-turn chat sessions so they survive
+// restarts and can be shared across replicas. Stores are keyed by session ID
+// and use optimistic concurrency: every write names the version it was based
+// on, and a write based on a stale version fails with ErrConflict instead of
+// interleaving two requests' turns.
+package conversation
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrConflict is returned when a write's expected version is not the
+	// session's current version. Reload the session and retry.
+	ErrConflict = errors.New("conversation: version conflict")
+	// ErrClosed is returned by MemoryStore and BoltStore after Close.
+	ErrClosed = errors.New("conversation: store closed")
+)
+
+// Message is one turn of a conversation.
+type Message struct {
+	Role      string    `json:"role"` // "system", "user", "assistant"
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Session is a conversation as loaded from a store. A session that does not
+// exist or has expired loads with no messages; its Version is still the one
+// to pass to the next write.
+type Session struct {
+	ID        string    `json:"id"`
+	Messages  []Message `json:"messages"`
+	Version   int64     `json:"version"` // 0 for a session never written
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"` // zero means no expiry
+}
+
+// Store persists sessions. Implementations are safe for concurrent use.
+type Store interface {
+	// Load returns the session with the given ID.
+	Load(ctx context.Context, id string) (Session, error)
+	// Append adds messages if the session is at version expect and returns the
+	// new version. It also extends the expiry by the store's TTL, if any.
+	Append(ctx context.Context, id string, expect int64, msgs ...Message) (int64, error)
+	// Truncate keeps only the last keep messages (0 clears the history) if the
+	// session is at version expect and returns the new version.
+	Truncate(ctx context.Context, id string, expect int64, keep int) (int64, error)
+	// Expire sets the session to expire ttl from now; ttl <= 0 removes the expiry.
+	// An expired session is treated as absent and is not brought back.
+	Expire(ctx context.Context, id string, ttl time.Duration) error
+	// Delete removes the session.
+	Delete(ctx context.Context, id string) error
+	// DeleteExpired removes expired sessions and returns how many were removed.
+	DeleteExpired(ctx context.Context) (int, error)
+	Close() error
+}
+
+// Options configures the bundled stores.
+type Options struct {
+	// TTL is the sliding lifetime of a session: each Append moves its expiry
+	// to TTL from then. Zero keeps sessions until deleted.
+	TTL time.Duration
+}
+
+// record is a session as stored, shared by the store implementations.
+type record struct {
+	Messages  []Message `json:"messages"`
+	Version   int64     `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+func (r record) expired(now time.Time) bool {
+	return !r.ExpiresAt.IsZero() && !now.Before(r.ExpiresAt)
+}
+
+// live returns r with its history dropped if it has expired. The version is
+// kept so that a writer holding a pre-expiry version still conflicts.
+func (r record) live(now time.Time) record {
+	if r.expired(now) {
+		r.Messages, r.ExpiresAt = nil, time.Time{}
+	}
+	return r
+}
+
+func (r record) session(id string) Session {
+	return Session{ID: id, Messages: r.Messages, Version: r.Version, UpdatedAt: r.UpdatedAt, ExpiresAt: r.ExpiresAt}
+}
+
+// appendTo applies an Append to r (already made live) and reports ErrConflict
+// on a version mismatch.
+func appendTo(r record, expect int64, now time.Time, ttl time.Duration, msgs []Message) (record, error) {
+	if r.Version != expect {
+		return r, ErrConflict
+	}
+	for _, m := range msgs {
+		if m.CreatedAt.IsZero() {
+			m.CreatedAt = now
+		}
+		r.Messages = append(r.Messages, m)
+	}
+	r.Version++
+	r.UpdatedAt = now
+	if ttl > 0 {
+		r.ExpiresAt = now.Add(ttl)
+	}
+	return r, nil
+}
+
+// truncate applies a Truncate to r (already made live).
+func truncate(r record, expect int64, now time.Time, keep int) (record, error) {
+	if r.Version != expect {
+		return r, ErrConflict
+	}
+	if keep < 0 {
+		keep = 0
+	}
+	if len(r.Messages) > keep {
+		r.Messages = append([]Message(nil), r.Messages[len(r.Messages)-keep:]...)
+	}
+	r.Version++
+	r.UpdatedAt = now
+	return r, nil
+}
+
+func expiry(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
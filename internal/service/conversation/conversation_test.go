@@ -0,0 +1,211 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go-azure-openai/internal/service/agent"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// stores opens each implementation with a controllable clock.
+func stores(t *testing.T, opts Options) map[string]Store {
+	t.Helper()
+	dir := t.TempDir()
+	clock := func() time.Time { return testNow }
+
+	mem := NewMemoryStore(opts)
+	mem.now = clock
+	bs, err := NewBoltStore(filepath.Join(dir, "sessions.bolt"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs.now = clock
+	ss, err := NewSQLiteStore(filepath.Join(dir, "sessions.db"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss.now = clock
+	all := map[string]Store{"memory": mem, "bolt": bs, "sqlite": ss}
+	t.Cleanup(func() {
+		for _, s := range all {
+			s.Close()
+		}
+	})
+	return all
+}
+
+var testNow = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestStore_AppendLoadTruncate(t *testing.T) {
+	ctx := context.Background()
+	for name, s := range stores(t, Options{}) {
+		t.Run(name, func(t *testing.T) {
+			sess, err := s.Load(ctx, "s1")
+			if err != nil || sess.Version != 0 || len(sess.Messages) != 0 {
+				t.Fatalf("expected empty session, got %+v %v", sess, err)
+			}
+			v, err := s.Append(ctx, "s1", 0, Message{Role: "user", Content: "hi"}, Message{Role: "assistant", Content: "hello"})
+			if err != nil || v != 1 {
+				t.Fatalf("append: v=%d err=%v", v, err)
+			}
+			if _, err := s.Append(ctx, "s1", 0, Message{Role: "user", Content: "stale"}); !errors.Is(err, ErrConflict) {
+				t.Fatalf("expected ErrConflict, got %v", err)
+			}
+			v, err = s.Append(ctx, "s1", 1, Message{Role: "user", Content: "again"})
+			if err != nil || v != 2 {
+				t.Fatalf("append: v=%d err=%v", v, err)
+			}
+			sess, _ = s.Load(ctx, "s1")
+			if len(sess.Messages) != 3 || sess.Messages[2].Content != "again" || !sess.Messages[0].CreatedAt.Equal(testNow) {
+				t.Fatalf("unexpected session: %+v", sess)
+			}
+
+			v, err = s.Truncate(ctx, "s1", 2, 1)
+			if err != nil || v != 3 {
+				t.Fatalf("truncate: v=%d err=%v", v, err)
+			}
+			sess, _ = s.Load(ctx, "s1")
+			if len(sess.Messages) != 1 || sess.Messages[0].Content != "again" {
+				t.Fatalf("unexpected history after truncate: %+v", sess.Messages)
+			}
+
+			if err := s.Delete(ctx, "s1"); err != nil {
+				t.Fatal(err)
+			}
+			if sess, _ = s.Load(ctx, "s1"); sess.Version != 0 {
+				t.Fatalf("expected deleted session, got %+v", sess)
+			}
+		})
+	}
+}
+
+func TestStore_TTL(t *testing.T) {
+	ctx := context.Background()
+	for name, s := range stores(t, Options{TTL: time.Hour}) {
+		t.Run(name, func(t *testing.T) {
+			defer func(n time.Time) { testNow = n }(testNow)
+			if _, err := s.Append(ctx, "a", 0, Message{Role: "user", Content: "x"}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Append(ctx, "b", 0, Message{Role: "user", Content: "y"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Expire(ctx, "b", 0); err != nil { // b never expires
+				t.Fatal(err)
+			}
+			sess, _ := s.Load(ctx, "a")
+			if !sess.ExpiresAt.Equal(testNow.Add(time.Hour)) {
+				t.Fatalf("expected sliding expiry, got %v", sess.ExpiresAt)
+			}
+
+			testNow = testNow.Add(2 * time.Hour)
+			sess, _ = s.Load(ctx, "a")
+			if len(sess.Messages) != 0 || sess.Version != 1 {
+				t.Fatalf("expected expired session to load empty at version 1, got %+v", sess)
+			}
+			if n, err := s.DeleteExpired(ctx); err != nil || n != 1 {
+				t.Fatalf("expected 1 expired session removed, got %d %v", n, err)
+			}
+			if sess, _ = s.Load(ctx, "b"); len(sess.Messages) != 1 {
+				t.Fatalf("session without expiry was removed: %+v", sess)
+			}
+		})
+	}
+}
+
+func TestStore_ExpireDoesNotReviveExpiredSession(t *testing.T) {
+	ctx := context.Background()
+	for name, s := range stores(t, Options{TTL: time.Hour}) {
+		t.Run(name, func(t *testing.T) {
+			defer func(n time.Time) { testNow = n }(testNow)
+			if _, err := s.Append(ctx, "a", 0, Message{Role: "user", Content: "x"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Expire(ctx, "a", time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			testNow = testNow.Add(2 * time.Minute)
+			if err := s.Expire(ctx, "a", time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			if sess, _ := s.Load(ctx, "a"); len(sess.Messages) != 0 || !sess.ExpiresAt.IsZero() {
+				t.Fatalf("expired session was revived: %+v", sess)
+			}
+		})
+	}
+}
+
+func TestStore_ConcurrentAppendsDoNotInterleave(t *testing.T) {
+	ctx := context.Background()
+	for name, s := range stores(t, Options{}) {
+		t.Run(name, func(t *testing.T) {
+			const writers = 8
+			var wg sync.WaitGroup
+			wins := make(chan int64, writers)
+			for i := 0; i < writers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					v, err := s.Append(ctx, "race", 0, Message{Role: "user", Content: "q"}, Message{Role: "assistant", Content: "a"})
+					if err == nil {
+						wins <- v
+					} else if !errors.Is(err, ErrConflict) {
+						t.Errorf("unexpected error: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+			close(wins)
+			if len(wins) != 1 {
+				t.Fatalf("expected exactly one writer to win, got %d", len(wins))
+			}
+			if sess, _ := s.Load(ctx, "race"); len(sess.Messages) != 2 {
+				t.Fatalf("history corrupted: %+v", sess.Messages)
+			}
+		})
+	}
+}
+
+type echoClient struct {
+	reqs []openai.ChatCompletionRequest
+}
+
+func (c *echoClient) CreateChatCompletion(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	c.reqs = append(c.reqs, req)
+	return openai.ChatCompletionResponse{Choices: []openai.ChatCompletionChoice{{
+		Message: openai.ChatCompletionMessage{Role: "assistant", Content: "reply " + req.Messages[len(req.Messages)-1].Content},
+	}}}, nil
+}
+
+func (c *echoClient) CreateChatCompletionStream(context.Context, openai.ChatCompletionRequest) (*openai.ChatCompletionStream, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestSend_CarriesHistory(t *testing.T) {
+	fc := &echoClient{}
+	a, err := agent.NewWithClient(agent.Config{Key: "k", Endpoint: "https://x.openai.azure.com", Model: "gpt-test"}, fc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewMemoryStore(Options{})
+	ctx := context.Background()
+	if _, err := Send(ctx, s, a, "s1", "first"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Send(ctx, s, a, "s1", "second")
+	if err != nil || res.Text != "reply second" {
+		t.Fatalf("unexpected result %q %v", res.Text, err)
+	}
+	if got := fc.reqs[1].Messages; len(got) != 3 || got[0].Content != "first" || got[1].Content != "reply first" {
+		t.Fatalf("history not sent: %+v", got)
+	}
+	if sess, _ := s.Load(ctx, "s1"); sess.Version != 2 || len(sess.Messages) != 4 {
+		t.Fatalf("unexpected stored session: %+v", sess)
+	}
+}
@@ -0,0 +1,111 @@
+package conversation
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps sessions in process memory. It suits tests and single
+// replicas where losing sessions on restart is acceptable.
+type MemoryStore struct {
+	opts Options
+	now  func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]record
+	closed   bool
+}
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore(opts Options) *MemoryStore {
+	return &MemoryStore{opts: opts, now: time.Now, sessions: map[string]record{}}
+}
+
+func (s *MemoryStore) Load(_ context.Context, id string) (Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return Session{}, ErrClosed
+	}
+	r := s.sessions[id].live(s.now())
+	r.Messages = slices.Clone(r.Messages)
+	return r.session(id), nil
+}
+
+func (s *MemoryStore) Append(_ context.Context, id string, expect int64, msgs ...Message) (int64, error) {
+	return s.update(id, func(r record, now time.Time) (record, error) {
+		return appendTo(r, expect, now, s.opts.TTL, msgs)
+	})
+}
+
+func (s *MemoryStore) Truncate(_ context.Context, id string, expect int64, keep int) (int64, error) {
+	return s.update(id, func(r record, now time.Time) (record, error) {
+		return truncate(r, expect, now, keep)
+	})
+}
+
+func (s *MemoryStore) update(id string, fn func(record, time.Time) (record, error)) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, ErrClosed
+	}
+	now := s.now()
+	r, err := fn(s.sessions[id].live(now), now)
+	if err != nil {
+		return 0, err
+	}
+	// Copy so callers' slices never alias stored history.
+	r.Messages = slices.Clone(r.Messages)
+	s.sessions[id] = r
+	return r.Version, nil
+}
+
+func (s *MemoryStore) Expire(_ context.Context, id string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	now := s.now()
+	if r, ok := s.sessions[id]; ok && !r.expired(now) {
+		r.ExpiresAt = expiry(now, ttl)
+		s.sessions[id] = r
+	}
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	delete(s.sessions, id)
+	return nil
+}
+
+func (s *MemoryStore) DeleteExpired(context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, ErrClosed
+	}
+	now, n := s.now(), 0
+	for id, r := range s.sessions {
+		if r.expired(now) {
+			delete(s.sessions, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *MemoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
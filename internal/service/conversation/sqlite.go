@@ -0,0 +1,201 @@
+package conversation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"time"
+
+	_ "modernc.org/sqlite" // pure-Go driver, registers "sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS conversation_sessions (
+	id         TEXT PRIMARY KEY,
+	version    INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL,
+	expires_at INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS conversation_messages (
+	session_id TEXT NOT NULL,
+	seq        INTEGER NOT NULL,
+	role       TEXT NOT NULL,
+	content    TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	PRIMARY KEY (session_id, seq)
+);
+CREATE INDEX IF NOT EXISTS conversation_sessions_expires ON conversation_sessions (expires_at) WHERE expires_at > 0;`
+
+// SQLiteStore keeps sessions in a SQLite database, so several processes can
+// share one file (with WAL and a busy timeout) and history is queryable with
+// plain SQL. Times are stored as Unix nanoseconds; expires_at 0 means never.
+type SQLiteStore struct {
+	db   *sql.DB
+	opts Options
+	now  func() time.Time
+}
+
+// NewSQLiteStore opens (or creates) the database at path.
+func NewSQLiteStore(path string, opts Options) (*SQLiteStore, error) {
+	q := url.Values{}
+	q.Add("_pragma", "busy_timeout(5000)")
+	q.Add("_pragma", "journal_mode(WAL)")
+	q.Set("_txlock", "immediate") // take the write lock at BEGIN so version checks cannot race
+	db, err := sql.Open("sqlite", "file:"+path+"?"+q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStore{db: db, opts: opts, now: time.Now}, nil
+}
+
+func nanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromNanos(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// querier is satisfied by *sql.DB and *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// loadRecord reads a session with its messages; a missing session is a zero record.
+func loadRecord(ctx context.Context, q querier, id string) (record, error) {
+	var r record
+	var updated, expires int64
+	err := q.QueryRowContext(ctx, `SELECT version, updated_at, expires_at FROM conversation_sessions WHERE id = ?`, id).
+		Scan(&r.Version, &updated, &expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return r, nil
+	}
+	if err != nil {
+		return r, err
+	}
+	r.UpdatedAt, r.ExpiresAt = fromNanos(updated), fromNanos(expires)
+	rows, err := q.QueryContext(ctx, `SELECT role, content, created_at FROM conversation_messages WHERE session_id = ? ORDER BY seq`, id)
+	if err != nil {
+		return r, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m Message
+		var created int64
+		if err := rows.Scan(&m.Role, &m.Content, &created); err != nil {
+			return r, err
+		}
+		m.CreatedAt = fromNanos(created)
+		r.Messages = append(r.Messages, m)
+	}
+	return r, rows.Err()
+}
+
+func (s *SQLiteStore) Load(ctx context.Context, id string) (Session, error) {
+	r, err := loadRecord(ctx, s.db, id)
+	if err != nil {
+		return Session{}, err
+	}
+	return r.live(s.now()).session(id), nil
+}
+
+func (s *SQLiteStore) Append(ctx context.Context, id string, expect int64, msgs ...Message) (int64, error) {
+	return s.update(ctx, id, func(r record, now time.Time) (record, error) {
+		return appendTo(r, expect, now, s.opts.TTL, msgs)
+	})
+}
+
+func (s *SQLiteStore) Truncate(ctx context.Context, id string, expect int64, keep int) (int64, error) {
+	return s.update(ctx, id, func(r record, now time.Time) (record, error) {
+		return truncate(r, expect, now, keep)
+	})
+}
+
+// update rewrites a session inside one immediate transaction. Sessions are
+// short, so rewriting the message rows keeps the logic shared with the other
+// stores.
+func (s *SQLiteStore) update(ctx context.Context, id string, fn func(record, time.Time) (record, error)) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	r, err := loadRecord(ctx, tx, id)
+	if err != nil {
+		return 0, err
+	}
+	now := s.now()
+	if r, err = fn(r.live(now), now); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO conversation_sessions (id, version, updated_at, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET version = excluded.version, updated_at = excluded.updated_at, expires_at = excluded.expires_at`,
+		id, r.Version, nanos(r.UpdatedAt), nanos(r.ExpiresAt)); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM conversation_messages WHERE session_id = ?`, id); err != nil {
+		return 0, err
+	}
+	for i, m := range r.Messages {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO conversation_messages (session_id, seq, role, content, created_at) VALUES (?, ?, ?, ?, ?)`,
+			id, i, m.Role, m.Content, nanos(m.CreatedAt)); err != nil {
+			return 0, err
+		}
+	}
+	return r.Version, tx.Commit()
+}
+
+func (s *SQLiteStore) Expire(ctx context.Context, id string, ttl time.Duration) error {
+	now := s.now()
+	_, err := s.db.ExecContext(ctx, `UPDATE conversation_sessions SET expires_at = ? WHERE id = ? AND (expires_at = 0 OR expires_at > ?)`,
+		nanos(expiry(now, ttl)), id, nanos(now))
+	return err
+}
+
+func (s *SQLiteStore) Delete(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM conversation_messages WHERE session_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM conversation_sessions WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) DeleteExpired(ctx context.Context) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	now := s.now().UnixNano()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM conversation_messages WHERE session_id IN
+		(SELECT id FROM conversation_sessions WHERE expires_at > 0 AND expires_at <= ?)`, now); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM conversation_sessions WHERE expires_at > 0 AND expires_at <= ?`, now)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), tx.Commit()
+}
+
+func (s *SQLiteStore) Close() error { return s.db.Close() }
@@ -1 +1,2 @@
+// Package evaluation grades student answers against a rubric.
 package evaluation
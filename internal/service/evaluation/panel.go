@@ -0,0 +1,267 @@
+package evaluation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-azure-openai/internal/service/agent"
+	"go-azure-openai/internal/service/prompt"
+)
+
+// Role is one participant of a Panel. Each role has its own agent, so it can
+// use its own deployment, plus its own system prompt and temperature.
+type Role struct {
+	Agent *agent.Agent
+	// System replaces the role's default system prompt; the JSON reply format
+	// is always appended.
+	System string
+	// Temperature for the role's calls; 0 asks for the most deterministic output.
+	Temperature float32
+	MaxTokens   int
+}
+
+// Panel grades an answer with a grader that proposes scores, a critic that
+// checks them against the rubric, and an arbiter that settles disagreement
+// once MaxRounds grader/critic rounds have not reached agreement.
+type Panel struct {
+	Grader, Critic, Arbiter Role
+	MaxRounds               int // grader/critic rounds before the arbiter decides (default 2)
+}
+
+// Task is one answer to grade.
+type Task struct {
+	Instructions string
+	Criteria     []prompt.Criterion
+	Question     string
+	Answer       string
+}
+
+// CriterionScore is the score for one criterion.
+type CriterionScore struct {
+	Criterion string  `json:"criterion"`
+	Score     float64 `json:"score"`
+	Rationale string  `json:"rationale"`
+}
+
+// Proposal is a full set of scores.
+type Proposal struct {
+	Scores    []CriterionScore `json:"scores"`
+	Total     float64          `json:"total"`
+	Rationale string           `json:"rationale,omitempty"`
+}
+
+// Critique is the critic's review of a proposal.
+type Critique struct {
+	Agree  bool     `json:"agree"`
+	Issues []string `json:"issues,omitempty"`
+}
+
+// Turn is one call in the transcript. System, Prompt and Answer are what the
+// call was sent, so it can be replayed.
+type Turn struct {
+	Round    int       `json:"round"`
+	Role     string    `json:"role"` // "grader", "critic" or "arbiter"
+	Model    string    `json:"model,omitempty"`
+	System   string    `json:"system"`
+	Prompt   string    `json:"prompt"`
+	Answer   string    `json:"answer"` // the student answer, sent as untrusted content
+	Response string    `json:"response"`
+	Tokens   int       `json:"tokens,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// Resolution says how the final scores were reached.
+type Resolution string
+
+const (
+	ResolvedByConsensus Resolution = "consensus" // the critic agreed
+	ResolvedByArbiter   Resolution = "arbiter"   // rounds ran out and the arbiter ruled
+)
+
+// Outcome is the final result with the full transcript for auditing.
+type Outcome struct {
+	Final      Proposal   `json:"final"`
+	Resolution Resolution `json:"resolution"`
+	Rounds     int        `json:"rounds"`
+	// InjectionDetected is set when the answer looked like a prompt-injection attempt.
+	InjectionDetected bool   `json:"injection_detected"`
+	Transcript        []Turn `json:"transcript"`
+}
+
+const (
+	graderSystem = `You are an examiner grading a student's answer against a rubric.
+Score every criterion within its maximum and justify each score briefly.
+If a reviewer raised issues with a previous proposal, address them.`
+	criticSystem = `You are a senior examiner reviewing another examiner's proposed scores.
+Check each score against the rubric and the answer. Agree only if every score is
+within its range and justified; otherwise list concrete issues.`
+	arbiterSystem = `You are the chief examiner. Two examiners disagree about a student's scores.
+Read the answer, the proposals and the critiques, and decide the final scores.`
+
+	proposalFormat = `Respond with JSON only:
+{"scores": [{"criterion": "<criterion title>", "score": <number>, "rationale": "<why>"}], "rationale": "<overall comment>"}`
+	critiqueFormat = `Respond with JSON only:
+{"agree": true|false, "issues": ["<issue>", ...]}`
+)
+
+// Grade runs the panel on task.
+func (p *Panel) Grade(ctx context.Context, task Task) (Outcome, error) {
+	var out Outcome
+	if p.Grader.Agent == nil || p.Critic.Agent == nil || p.Arbiter.Agent == nil {
+		return out, errors.New("panel needs grader, critic and arbiter agents")
+	}
+	if len(task.Criteria) == 0 {
+		return out, errors.New("task has no criteria")
+	}
+	maxRounds := p.MaxRounds
+	if maxRounds <= 0 {
+		maxRounds = 2
+	}
+	rubric := renderRubric(task)
+
+	var critiques []Critique
+	var proposals []Proposal
+	for round := 1; round <= maxRounds; round++ {
+		req := rubric
+		if len(proposals) > 0 {
+			req += "\n\nYOUR PREVIOUS PROPOSAL:\n" + mustJSON(proposals[len(proposals)-1]) +
+				"\n\nREVIEWER ISSUES:\n- " + strings.Join(critiques[len(critiques)-1].Issues, "\n- ")
+		}
+		var prop Proposal
+		if err := p.call(ctx, &out, round, "grader", p.Grader, graderSystem, proposalFormat, req, task.Answer, &prop); err != nil {
+			return out, err
+		}
+		// scores that break the rubric go to the critic as issues, so the
+		// grader is asked again instead of the whole panel failing
+		problems := normalize(&prop, task.Criteria)
+		proposals = append(proposals, prop)
+
+		var crit Critique
+		req = rubric + "\n\nPROPOSED SCORES:\n" + mustJSON(prop)
+		if len(problems) > 0 {
+			req += "\n\nRUBRIC VIOLATIONS:\n- " + strings.Join(problems, "\n- ")
+		}
+		if err := p.call(ctx, &out, round, "critic", p.Critic, criticSystem, critiqueFormat, req, task.Answer, &crit); err != nil {
+			return out, err
+		}
+		if len(problems) > 0 {
+			crit.Agree = false
+			crit.Issues = append(problems, crit.Issues...)
+		}
+		critiques = append(critiques, crit)
+		out.Rounds = round
+		if crit.Agree {
+			out.Final, out.Resolution = prop, ResolvedByConsensus
+			return out, nil
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(rubric)
+	for i := range proposals {
+		fmt.Fprintf(&b, "\n\nROUND %d PROPOSAL:\n%s\nROUND %d CRITIQUE:\n%s", i+1, mustJSON(proposals[i]), i+1, mustJSON(critiques[i]))
+	}
+	var final Proposal
+	if err := p.call(ctx, &out, out.Rounds, "arbiter", p.Arbiter, arbiterSystem, proposalFormat, b.String(), task.Answer, &final); err != nil {
+		return out, err
+	}
+	if problems := normalize(&final, task.Criteria); len(problems) > 0 {
+		return out, fmt.Errorf("arbiter: %s", strings.Join(problems, "; "))
+	}
+	out.Final, out.Resolution = final, ResolvedByArbiter
+	return out, nil
+}
+
+// call sends one role's request, records the turn and decodes the JSON reply into v.
+// The student answer is passed as untrusted content so it is isolated from the
+// instructions and checked for injection attempts.
+func (p *Panel) call(ctx context.Context, out *Outcome, round int, name string, r Role, defaultSystem, format, req, answer string, v any) error {
+	system := r.System
+	if system == "" {
+		system = defaultSystem
+	}
+	system += "\n\n" + format
+	opts := []agent.ChatOption{
+		agent.WithSystem(system),
+		agent.WithTemperature(r.Temperature),
+		agent.WithUntrustedContent(answer),
+	}
+	if r.MaxTokens > 0 {
+		opts = append(opts, agent.WithMaxTokens(r.MaxTokens))
+	}
+	res, err := r.Agent.ChatStructured(ctx, req, opts...)
+	if err != nil {
+		return fmt.Errorf("%s round %d: %w", name, round, err)
+	}
+	out.Transcript = append(out.Transcript, Turn{
+		Round: round, Role: name, Model: res.Model, System: system, Prompt: req, Answer: answer,
+		Response: res.Text, Tokens: res.Tokens, At: time.Now(),
+	})
+	if res.Injection != nil && res.Injection.Detected {
+		out.InjectionDetected = true
+	}
+	if err := json.Unmarshal([]byte(prompt.StripFences(res.Text)), v); err != nil {
+		return fmt.Errorf("%s round %d: parse reply: %w", name, round, err)
+	}
+	return nil
+}
+
+// renderRubric formats the task without the answer, which is sent separately.
+func renderRubric(t Task) string {
+	var b strings.Builder
+	if t.Instructions != "" {
+		b.WriteString("INSTRUCTIONS:\n" + t.Instructions + "\n\n")
+	}
+	b.WriteString("RUBRIC:\n")
+	for i, c := range t.Criteria {
+		idx := c.Index
+		if idx == 0 {
+			idx = i + 1
+		}
+		fmt.Fprintf(&b, "CRITERIA %d: %s (0-%g marks)\n", idx, c.Title, c.MaxScore)
+		for _, it := range c.Items {
+			fmt.Fprintf(&b, "- %s (0-%g marks)\n", it.Description, it.MaxScore)
+		}
+	}
+	if t.Question != "" {
+		b.WriteString("\nQUESTION:\n" + t.Question)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// normalize orders p's scores by criterion and recomputes the total, so a
+// model cannot report a total its scores do not add up to. It returns the
+// criteria that are missing or scored out of range.
+func normalize(p *Proposal, criteria []prompt.Criterion) []string {
+	byTitle := make(map[string]CriterionScore, len(p.Scores))
+	for _, s := range p.Scores {
+		byTitle[strings.ToLower(strings.TrimSpace(s.Criterion))] = s
+	}
+	var problems []string
+	scores := make([]CriterionScore, 0, len(criteria))
+	p.Total = 0
+	for _, c := range criteria {
+		s, ok := byTitle[strings.ToLower(c.Title)]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("missing score for criterion %q", c.Title))
+			continue
+		}
+		if s.Score < 0 || s.Score > c.MaxScore {
+			problems = append(problems, fmt.Sprintf("score %g for %q outside 0-%g", s.Score, c.Title, c.MaxScore))
+		}
+		s.Criterion = c.Title
+		scores = append(scores, s)
+		p.Total += s.Score
+	}
+	p.Scores = scores
+	return problems
+}
+
+func mustJSON(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,160 @@
+// Package guardrail checks model output beyond its JSON shape: score ranges,
+// answer language, profanity, quoting the student's text and so on. Each
+// Validator passes the output, fixes it, or fails it; the agent re-asks the
+// model or falls back when a check fails and reports every violation.
+package guardrail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Status is the outcome of one validator.
+type Status string
+
+const (
+	Pass Status = "pass"
+	Fix  Status = "fix"  // the output was corrected; Result.Fixed holds it
+	Fail Status = "fail" // the output is unusable
+)
+
+// Result is what a Validator decides about an output.
+type Result struct {
+	Status  Status
+	Fixed   string // the corrected output when Status is Fix
+	Message string // why the output was fixed or failed, shown to the model on re-ask
+}
+
+// Passed returns a passing result.
+func Passed() Result { return Result{Status: Pass} }
+
+// Fixed returns a result replacing the output with text.
+func Fixed(text, format string, args ...any) Result {
+	return Result{Status: Fix, Fixed: text, Message: fmt.Sprintf(format, args...)}
+}
+
+// Failed returns a failing result.
+func Failed(format string, args ...any) Result {
+	return Result{Status: Fail, Message: fmt.Sprintf(format, args...)}
+}
+
+// Validator checks one property of a model output.
+type Validator interface {
+	Name() string
+	Validate(ctx context.Context, output string) Result
+}
+
+type funcValidator struct {
+	name string
+	fn   func(ctx context.Context, output string) Result
+}
+
+func (f funcValidator) Name() string { return f.name }
+
+func (f funcValidator) Validate(ctx context.Context, output string) Result { return f.fn(ctx, output) }
+
+// Func makes a Validator from a function.
+func Func(name string, fn func(ctx context.Context, output string) Result) Validator {
+	return funcValidator{name: name, fn: fn}
+}
+
+// Violation is a fix or failure recorded in a Report.
+type Violation struct {
+	Validator string `json:"validator"`
+	Status    Status `json:"status"`
+	Message   string `json:"message"`
+	Attempt   int    `json:"attempt"` // 1 for the first answer, 2 for the first re-ask, ...
+}
+
+// Report describes the guardrail checks of a chat call.
+type Report struct {
+	Violations []Violation `json:"violations,omitempty"`
+	Attempts   int         `json:"attempts"`
+	Passed     bool        `json:"passed"`             // the returned output passed every validator
+	Fallback   bool        `json:"fallback,omitempty"` // the returned output came from Policy.Fallback
+}
+
+// Failures returns the failing violations of one attempt.
+func (r Report) Failures(attempt int) []Violation {
+	var out []Violation
+	for _, v := range r.Violations {
+		if v.Attempt == attempt && v.Status == Fail {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// FallbackFunc produces the output to use when every attempt failed, e.g. a
+// safe default or the result of another model.
+type FallbackFunc func(ctx context.Context, report Report) (string, error)
+
+// Policy is the set of validators for a call and what to do on failure.
+type Policy struct {
+	Validators []Validator
+	// MaxReasks is how many times the model is asked again, with the
+	// failures explained, before giving up (default 1; negative disables).
+	MaxReasks int
+	// Fallback, when set, supplies the output after the last failed attempt
+	// instead of returning an *Error.
+	Fallback FallbackFunc
+}
+
+// Reasks returns MaxReasks with its default applied.
+func (p Policy) Reasks() int {
+	switch {
+	case p.MaxReasks < 0:
+		return 0
+	case p.MaxReasks == 0:
+		return 1
+	}
+	return p.MaxReasks
+}
+
+// Check runs the validators in order on output, feeding fixed output to the
+// next validator. It returns the final output, its violations tagged with
+// attempt, and whether any validator failed.
+func (p Policy) Check(ctx context.Context, output string, attempt int) (string, []Violation, bool) {
+	var violations []Violation
+	failed := false
+	for _, v := range p.Validators {
+		r := v.Validate(ctx, output)
+		switch r.Status {
+		case Fix:
+			output = r.Fixed
+		case Fail:
+			failed = true
+		default:
+			continue
+		}
+		violations = append(violations, Violation{Validator: v.Name(), Status: r.Status, Message: r.Message, Attempt: attempt})
+	}
+	return output, violations, failed
+}
+
+// ReaskPrompt explains failures to the model and asks for a corrected answer.
+func ReaskPrompt(failures []Violation) string {
+	var b strings.Builder
+	b.WriteString("Your previous answer failed these checks:\n")
+	for _, v := range failures {
+		fmt.Fprintf(&b, "- %s: %s\n", v.Validator, v.Message)
+	}
+	b.WriteString("Answer again in the same format, following all earlier instructions and fixing these problems.")
+	return b.String()
+}
+
+// Error is returned when the output still failed after every re-ask and no
+// fallback is configured. The last output is still returned with it.
+type Error struct {
+	Report Report
+}
+
+func (e *Error) Error() string {
+	fails := e.Report.Failures(e.Report.Attempts)
+	names := make([]string, len(fails))
+	for i, v := range fails {
+		names[i] = v.Validator
+	}
+	return fmt.Sprintf("guardrails failed after %d attempts: %s", e.Report.Attempts, strings.Join(names, ", "))
+}
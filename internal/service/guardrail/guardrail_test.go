@@ -0,0 +1,101 @@
+package guardrail
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go-azure-openai/internal/service/prompt"
+)
+
+func check(t *testing.T, v Validator, output string) Result {
+	t.Helper()
+	return v.Validate(context.Background(), output)
+}
+
+func TestCriterionScores(t *testing.T) {
+	criteria := []prompt.Criterion{{Title: "Content", MaxScore: 5}, {Title: "Language", MaxScore: 3}}
+	v := CriterionScores("scores[]", "criterion", "score", criteria)
+	if r := check(t, v, "```json\n{\"scores\":[{\"criterion\":\"content\",\"score\":4.5},{\"criterion\":\"Language\",\"score\":3}]}\n```"); r.Status != Pass {
+		t.Fatalf("expected pass, got %+v", r)
+	}
+	r := check(t, v, `{"scores":[{"criterion":"Content","score":6},{"criterion":"Style","score":1}]}`)
+	if r.Status != Fail || !strings.Contains(r.Message, "Content score 6 is outside 0-5") ||
+		!strings.Contains(r.Message, `unknown criterion "Style"`) || !strings.Contains(r.Message, `missing criterion "Language"`) {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	if r := check(t, v, "not json"); r.Status != Fail {
+		t.Fatalf("expected failure for invalid JSON")
+	}
+}
+
+func TestNumberRange(t *testing.T) {
+	v := NumberRange("scores[].score", 0, 5)
+	if r := check(t, v, `{"scores":[{"score":1},{"score":5}]}`); r.Status != Pass {
+		t.Fatalf("expected pass, got %+v", r)
+	}
+	for _, out := range []string{`{"scores":[{"score":1},{"score":5.5}]}`, `{"scores":[{"score":"4"}]}`, `{"total":3}`} {
+		if r := check(t, v, out); r.Status != Fail {
+			t.Fatalf("expected failure for %s", out)
+		}
+	}
+}
+
+func TestEnglish(t *testing.T) {
+	v := English("feedback", 0)
+	if r := check(t, v, `{"feedback":"Good use of linking words, e.g. \"however\"."}`); r.Status != Pass {
+		t.Fatalf("expected pass, got %+v", r)
+	}
+	if r := check(t, v, `{"feedback":"ใช้คำเชื่อมได้ดี but grammar needs work"}`); r.Status != Fail {
+		t.Fatalf("expected failure for Thai feedback, got %+v", r)
+	}
+	if r := check(t, v, `{"score":1}`); r.Status != Fail {
+		t.Fatalf("expected failure for missing feedback")
+	}
+}
+
+func TestNoProfanity(t *testing.T) {
+	v := NoProfanity()
+	r := check(t, v, `{"feedback":"This is SHIT, not scrappy"}`)
+	if r.Status != Fix || r.Fixed != `{"feedback":"This is S***, not scrappy"}` {
+		t.Fatalf("unexpected fix %+v", r)
+	}
+	if r := check(t, v, "Scrappy but clear."); r.Status != Pass {
+		t.Fatalf("expected pass for partial word, got %+v", r)
+	}
+}
+
+func TestQuotesSource(t *testing.T) {
+	source := "In my opinion,   public transport\nshould be free for students."
+	v := QuotesSource("rationale", source, 3)
+	if r := check(t, v, `{"rationale":"The writer states \"Public transport should be free\" clearly."}`); r.Status != Pass {
+		t.Fatalf("expected pass, got %+v", r)
+	}
+	if r := check(t, v, `{"rationale":"The writer says “transport is cheap” here."}`); r.Status != Fail {
+		t.Fatalf("expected failure for invented quote, got %+v", r)
+	}
+	if r := check(t, v, `{"rationale":"Clear opinion \"in my\"."}`); r.Status != Fail {
+		t.Fatalf("expected failure for a too short quote, got %+v", r)
+	}
+}
+
+func TestPolicyCheckAndJSON(t *testing.T) {
+	p := Policy{Validators: []Validator{JSON(), NoProfanity("crap"), NumberRange("score", 0, 10)}}
+	out, violations, failed := p.Check(context.Background(), "```json\n{\"score\": 11, \"note\": \"crap\"}\n```", 1)
+	if !failed || out != `{"score": 11, "note": "c***"}` || len(violations) != 3 {
+		t.Fatalf("unexpected check: %q %+v %v", out, violations, failed)
+	}
+	rep := Report{Violations: violations, Attempts: 1}
+	if fails := rep.Failures(1); len(fails) != 1 || fails[0].Validator != "number_range:score" {
+		t.Fatalf("unexpected failures %+v", fails)
+	}
+	if msg := ReaskPrompt(rep.Failures(1)); !strings.Contains(msg, "number_range:score: score is 11") {
+		t.Fatalf("unexpected re-ask prompt %q", msg)
+	}
+	if err := (&Error{Report: rep}); !strings.Contains(err.Error(), "after 1 attempts: number_range:score") {
+		t.Fatalf("unexpected error %q", err.Error())
+	}
+	if (Policy{}).Reasks() != 1 || (Policy{MaxReasks: -1}).Reasks() != 0 {
+		t.Fatalf("unexpected re-ask defaults")
+	}
+}
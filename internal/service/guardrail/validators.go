@@ -0,0 +1,242 @@
+package guardrail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"go-azure-openai/internal/service/prompt"
+)
+
+// parseJSON decodes output, ignoring code fences.
+func parseJSON(output string) (any, error) {
+	var doc any
+	if err := json.Unmarshal([]byte(prompt.StripFences(output)), &doc); err != nil {
+		return nil, fmt.Errorf("output is not valid JSON: %v", err)
+	}
+	return doc, nil
+}
+
+// selectPath returns the values at path in doc. Paths used by validators
+// look like "feedback" or "scores[].score", where [] selects every element
+// of an array; "" selects the whole output.
+func selectPath(doc any, path string) []any {
+	cur := []any{doc}
+	if path == "" {
+		return cur
+	}
+	for _, part := range strings.Split(path, ".") {
+		each := strings.HasSuffix(part, "[]")
+		key := strings.TrimSuffix(part, "[]")
+		var next []any
+		for _, v := range cur {
+			m, ok := v.(map[string]any)
+			if !ok {
+				continue
+			}
+			val, ok := m[key]
+			if !ok {
+				continue
+			}
+			if each {
+				arr, _ := val.([]any)
+				next = append(next, arr...)
+			} else {
+				next = append(next, val)
+			}
+		}
+		cur = next
+	}
+	return cur
+}
+
+// texts returns the strings at path, or the whole output when path is empty.
+func texts(output, path string) ([]string, error) {
+	if path == "" {
+		return []string{output}, nil
+	}
+	doc, err := parseJSON(output)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, v := range selectPath(doc, path) {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no text at %q", path)
+	}
+	return out, nil
+}
+
+// NumberRange fails when a number at path is outside [min, max] or missing.
+func NumberRange(path string, min, max float64) Validator {
+	return Func("number_range:"+path, func(_ context.Context, output string) Result {
+		doc, err := parseJSON(output)
+		if err != nil {
+			return Failed("%v", err)
+		}
+		vals := selectPath(doc, path)
+		if len(vals) == 0 {
+			return Failed("%s is missing", path)
+		}
+		for _, v := range vals {
+			n, ok := v.(float64)
+			if !ok {
+				return Failed("%s must be a number, got %v", path, v)
+			}
+			if n < min || n > max {
+				return Failed("%s is %g, must be between %g and %g", path, n, min, max)
+			}
+		}
+		return Passed()
+	})
+}
+
+// CriterionScores checks the objects at path (e.g. "scores[]") against the
+// rubric: titleKey names the criterion and scoreKey holds its score, which
+// must be between 0 and the criterion's MaxScore. Unknown and missing
+// criteria fail too.
+func CriterionScores(path, titleKey, scoreKey string, criteria []prompt.Criterion) Validator {
+	return Func("criterion_scores", func(_ context.Context, output string) Result {
+		doc, err := parseJSON(output)
+		if err != nil {
+			return Failed("%v", err)
+		}
+		max := make(map[string]float64, len(criteria))
+		for _, c := range criteria {
+			max[strings.ToLower(strings.TrimSpace(c.Title))] = c.MaxScore
+		}
+		seen := map[string]bool{}
+		var problems []string
+		for _, v := range selectPath(doc, path) {
+			obj, _ := v.(map[string]any)
+			title, _ := obj[titleKey].(string)
+			key := strings.ToLower(strings.TrimSpace(title))
+			limit, known := max[key]
+			score, isNum := obj[scoreKey].(float64)
+			switch {
+			case !known:
+				problems = append(problems, fmt.Sprintf("unknown criterion %q", title))
+			case !isNum:
+				problems = append(problems, fmt.Sprintf("%s has no numeric %s", title, scoreKey))
+			case score < 0 || score > limit:
+				problems = append(problems, fmt.Sprintf("%s score %g is outside 0-%g", title, score, limit))
+			}
+			seen[key] = true
+		}
+		for _, c := range criteria {
+			if !seen[strings.ToLower(strings.TrimSpace(c.Title))] {
+				problems = append(problems, fmt.Sprintf("missing criterion %q", c.Title))
+			}
+		}
+		if len(problems) > 0 {
+			return Failed("%s", strings.Join(problems, "; "))
+		}
+		return Passed()
+	})
+}
+
+// English fails when less than minRatio of the letters in the text at path
+// are Latin (default 0.9), e.g. feedback written in Thai.
+func English(path string, minRatio float64) Validator {
+	if minRatio <= 0 {
+		minRatio = 0.9
+	}
+	return Func("english:"+path, func(_ context.Context, output string) Result {
+		ts, err := texts(output, path)
+		if err != nil {
+			return Failed("%v", err)
+		}
+		var latin, letters int
+		for _, t := range ts {
+			for _, r := range t {
+				if unicode.IsLetter(r) {
+					letters++
+					if unicode.Is(unicode.Latin, r) {
+						latin++
+					}
+				}
+			}
+		}
+		if letters > 0 && float64(latin)/float64(letters) < minRatio {
+			return Failed("text must be in English (%.0f%% Latin letters)", 100*float64(latin)/float64(letters))
+		}
+		return Passed()
+	})
+}
+
+// DefaultProfanity is the word list used by NoProfanity when none is given.
+var DefaultProfanity = []string{"fuck", "fucking", "shit", "bitch", "bastard", "asshole", "damn", "crap", "idiot", "stupid"}
+
+// NoProfanity masks listed words (whole words, any case) in the output with
+// asterisks and reports a fix. JSON stays valid since only letters change.
+func NoProfanity(words ...string) Validator {
+	if len(words) == 0 {
+		words = DefaultProfanity
+	}
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = regexp.QuoteMeta(w)
+	}
+	re := regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)
+	return Func("no_profanity", func(_ context.Context, output string) Result {
+		found := re.FindAllString(output, -1)
+		if len(found) == 0 {
+			return Passed()
+		}
+		fixed := re.ReplaceAllStringFunc(output, func(w string) string {
+			r := []rune(w)
+			return string(r[0]) + strings.Repeat("*", len(r)-1)
+		})
+		return Fixed(fixed, "masked %d profane word(s)", len(found))
+	})
+}
+
+var quoteRe = regexp.MustCompile(`"([^"]+)"|“([^”]+)”|'([^']{2,})'`)
+
+// QuotesSource fails unless the text at path quotes the source (the
+// student's answer) verbatim at least once, in "double", “curly” or 'single'
+// quotes, with at least minWords words (default 2). Case and whitespace are
+// ignored when matching.
+func QuotesSource(path, source string, minWords int) Validator {
+	if minWords <= 0 {
+		minWords = 2
+	}
+	norm := func(s string) string { return strings.ToLower(strings.Join(strings.Fields(s), " ")) }
+	src := norm(source)
+	return Func("quotes_source:"+path, func(_ context.Context, output string) Result {
+		ts, err := texts(output, path)
+		if err != nil {
+			return Failed("%v", err)
+		}
+		for _, t := range ts {
+			for _, m := range quoteRe.FindAllStringSubmatch(t, -1) {
+				q := norm(m[1] + m[2] + m[3])
+				if len(strings.Fields(q)) >= minWords && strings.Contains(src, q) {
+					return Passed()
+				}
+			}
+		}
+		return Failed("the rationale must quote at least %d consecutive words from the student's text in double quotes", minWords)
+	})
+}
+
+// JSON fails when the output is not valid JSON and fixes output wrapped in
+// Markdown code fences.
+func JSON() Validator {
+	return Func("json", func(_ context.Context, output string) Result {
+		if _, err := parseJSON(output); err != nil {
+			return Failed("%v", err)
+		}
+		if json.Valid([]byte(output)) {
+			return Passed()
+		}
+		return Fixed(prompt.StripFences(output), "removed code fences")
+	})
+}
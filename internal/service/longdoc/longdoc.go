@@ -0,0 +1,276 @@
+// Package longdoc processes documents larger than one model call can take,
+// such as portfolios and long reports: the text is split into chunks, a map
+// prompt runs on each chunk with bounded concurrency, and the results are
+// reduced hierarchically with a reduce prompt or merged as JSON. Every result
+// records which chunks it came from.
+package longdoc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"go-azure-openai/internal/service/agent"
+	"go-azure-openai/internal/service/prompt"
+)
+
+// Partial is a map or reduce result with the chunks it covers.
+type Partial struct {
+	Text    string `json:"text"`
+	Sources []int  `json:"sources"` // chunk indexes, ascending
+}
+
+// MergeFunc combines partial results locally instead of with a reduce prompt.
+type MergeFunc func(parts []Partial) (Partial, error)
+
+// Processor runs map-reduce over a document.
+//
+// MapPrompt is a text/template over MapInput; ReducePrompt over ReduceInput.
+type Processor struct {
+	Agent        *agent.Agent
+	Split        SplitOptions
+	System       string // system prompt for map and reduce calls
+	MapPrompt    string
+	ReducePrompt string
+	// Merge, when set, replaces ReducePrompt; see MergeJSON.
+	Merge       MergeFunc
+	Concurrency int // max calls in flight (default 4)
+	FanIn       int // partials per reduce call (default 4)
+	ChatOptions []agent.ChatOption
+}
+
+// MapInput is the data for MapPrompt.
+type MapInput struct {
+	Chunk Chunk
+	Total int
+}
+
+// ReduceInput is the data for ReducePrompt.
+type ReduceInput struct {
+	Results []string
+	Level   int // 1 for the first reduce level
+}
+
+// Result is the final output with every intermediate result.
+type Result struct {
+	Partial
+	Chunks []Chunk     `json:"chunks"`
+	Mapped []Partial   `json:"mapped"` // one per chunk
+	Levels [][]Partial `json:"levels"` // reduce levels, the last holding the final result
+}
+
+// Run splits text, maps every chunk and reduces the results to one.
+func (p *Processor) Run(ctx context.Context, text string) (Result, error) {
+	var res Result
+	if p.Agent == nil {
+		return res, errors.New("longdoc processor needs an agent")
+	}
+	if p.MapPrompt == "" || (p.ReducePrompt == "" && p.Merge == nil) {
+		return res, errors.New("longdoc processor needs a map prompt and a reduce prompt or merge")
+	}
+	mapT, err := template.New("map").Option("missingkey=error").Parse(p.MapPrompt)
+	if err != nil {
+		return res, err
+	}
+	res.Chunks = Split(text, p.Split)
+	if len(res.Chunks) == 0 {
+		return res, errors.New("empty document")
+	}
+
+	res.Mapped, err = p.parallel(ctx, len(res.Chunks), func(ctx context.Context, i int) (Partial, error) {
+		var b strings.Builder
+		if err := mapT.Execute(&b, MapInput{Chunk: res.Chunks[i], Total: len(res.Chunks)}); err != nil {
+			return Partial{}, err
+		}
+		text, err := p.chat(ctx, b.String())
+		if err != nil {
+			return Partial{}, fmt.Errorf("map chunk %d: %w", i, err)
+		}
+		return Partial{Text: text, Sources: []int{i}}, nil
+	})
+	if err != nil {
+		return res, err
+	}
+
+	if p.Merge != nil {
+		final, err := p.Merge(res.Mapped)
+		if err != nil {
+			return res, fmt.Errorf("merge: %w", err)
+		}
+		final.Sources = unionSources(res.Mapped)
+		res.Partial = final
+		res.Levels = [][]Partial{{final}}
+		return res, nil
+	}
+	if len(res.Mapped) == 1 {
+		res.Partial = res.Mapped[0]
+		return res, nil
+	}
+
+	reduceT, err := template.New("reduce").Option("missingkey=error").Parse(p.ReducePrompt)
+	if err != nil {
+		return res, err
+	}
+	fanIn := p.FanIn
+	if fanIn < 2 {
+		fanIn = 4
+	}
+	level := res.Mapped
+	for depth := 1; len(level) > 1; depth++ {
+		groups := (len(level) + fanIn - 1) / fanIn
+		next, err := p.parallel(ctx, groups, func(ctx context.Context, g int) (Partial, error) {
+			group := level[g*fanIn : min((g+1)*fanIn, len(level))]
+			if len(group) == 1 {
+				return group[0], nil
+			}
+			in := ReduceInput{Level: depth}
+			for _, part := range group {
+				in.Results = append(in.Results, part.Text)
+			}
+			var b strings.Builder
+			if err := reduceT.Execute(&b, in); err != nil {
+				return Partial{}, err
+			}
+			text, err := p.chat(ctx, b.String())
+			if err != nil {
+				return Partial{}, fmt.Errorf("reduce level %d group %d: %w", depth, g, err)
+			}
+			return Partial{Text: text, Sources: unionSources(group)}, nil
+		})
+		if err != nil {
+			return res, err
+		}
+		res.Levels = append(res.Levels, next)
+		level = next
+	}
+	res.Partial = level[0]
+	return res, nil
+}
+
+func (p *Processor) chat(ctx context.Context, prompt string) (string, error) {
+	opts := p.ChatOptions
+	if p.System != "" {
+		opts = append([]agent.ChatOption{agent.WithSystem(p.System)}, opts...)
+	}
+	r, err := p.Agent.ChatStructured(ctx, prompt, opts...)
+	return r.Text, err
+}
+
+// parallel runs fn for 0..n-1 with at most Concurrency calls at once and
+// returns the results in order. The first error cancels the rest.
+func (p *Processor) parallel(ctx context.Context, n int, fn func(context.Context, int) (Partial, error)) ([]Partial, error) {
+	limit := p.Concurrency
+	if limit <= 0 {
+		limit = 4
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	out := make([]Partial, n)
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			r, err := fn(ctx, i)
+			if err != nil {
+				cancel(err)
+				return
+			}
+			out[i] = r
+		}()
+	}
+	wg.Wait()
+	return out, context.Cause(ctx)
+}
+
+func unionSources(parts []Partial) []int {
+	seen := map[int]bool{}
+	var out []int
+	for _, p := range parts {
+		for _, s := range p.Sources {
+			if !seen[s] {
+				seen[s] = true
+				out = append(out, s)
+			}
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// MergeJSON merges map results that are JSON objects: arrays are
+// concatenated, numbers summed, nested objects merged recursively and, for
+// other values, the first non-null one wins. Markdown code fences around a
+// result are ignored. Each array element that is an object gets a
+// "_source_chunk" field naming the chunk it came from.
+func MergeJSON(parts []Partial) (Partial, error) {
+	merged := map[string]any{}
+	for _, part := range parts {
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(prompt.StripFences(part.Text)), &obj); err != nil {
+			return Partial{}, fmt.Errorf("chunk %v: %w", part.Sources, err)
+		}
+		if len(part.Sources) == 1 {
+			tagSources(obj, part.Sources[0])
+		}
+		mergeObject(merged, obj)
+	}
+	b, err := json.Marshal(merged)
+	if err != nil {
+		return Partial{}, err
+	}
+	return Partial{Text: string(b), Sources: unionSources(parts)}, nil
+}
+
+func mergeObject(dst, src map[string]any) {
+	for k, v := range src {
+		cur, ok := dst[k]
+		if !ok || cur == nil {
+			dst[k] = v
+			continue
+		}
+		switch c := cur.(type) {
+		case []any:
+			if arr, ok := v.([]any); ok {
+				dst[k] = append(c, arr...)
+			}
+		case float64:
+			if n, ok := v.(float64); ok {
+				dst[k] = c + n
+			}
+		case map[string]any:
+			if m, ok := v.(map[string]any); ok {
+				mergeObject(c, m)
+			}
+		}
+	}
+}
+
+func tagSources(obj map[string]any, chunk int) {
+	for _, v := range obj {
+		switch x := v.(type) {
+		case []any:
+			for _, el := range x {
+				if m, ok := el.(map[string]any); ok {
+					m["_source_chunk"] = chunk
+				}
+			}
+		case map[string]any:
+			tagSources(x, chunk)
+		}
+	}
+}
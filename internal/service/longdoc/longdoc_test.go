@@ -0,0 +1,148 @@
+package longdoc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"go-azure-openai/internal/service/agent"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestSplit_Paragraphs(t *testing.T) {
+	var paras []string
+	for i := 0; i < 10; i++ {
+		paras = append(paras, fmt.Sprintf("Paragraph %d. %s", i, strings.Repeat("word ", 30)))
+	}
+	text := strings.Join(paras, "\n\n")
+	chunks := Split(text, SplitOptions{MaxTokens: 100, Overlap: 10})
+	if len(chunks) < 3 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+	for i, c := range chunks {
+		if c.Index != i || EstimateTokens(text[c.Start:c.End]) > 100 {
+			t.Fatalf("chunk %d too large or misnumbered: %+v", i, c)
+		}
+		if i > 0 && c.Start != chunks[i-1].End && strings.TrimSpace(text[chunks[i-1].End:c.Start]) != "" {
+			t.Fatalf("text lost between chunks %d and %d", i-1, i)
+		}
+	}
+	if !strings.Contains(chunks[1].Text, "word word") || !strings.HasPrefix(text[chunks[1].Start:], "Paragraph") {
+		t.Fatalf("expected paragraph-aligned chunk with overlap: %q", chunks[1].Text[:40])
+	}
+}
+
+func TestSplit_HeadingsAndTokens(t *testing.T) {
+	text := "Intro line.\n# Part A\nAlpha text.\n\n# Part B\nBeta text."
+	chunks := Split(text, SplitOptions{Mode: SplitHeadings, MaxTokens: 1000, Overlap: -1})
+	if len(chunks) != 3 || chunks[1].Heading != "Part A" || chunks[2].Heading != "Part B" || chunks[2].Text != "# Part B\nBeta text." {
+		t.Fatalf("unexpected heading chunks: %+v", chunks)
+	}
+
+	thai := strings.Repeat("ภาษาไทย", 100) // no spaces
+	for _, c := range Split(thai, SplitOptions{Mode: SplitTokens, MaxTokens: 50, Overlap: -1}) {
+		if !utf8.ValidString(c.Text) {
+			t.Fatalf("chunk cut inside a rune: %q", c.Text)
+		}
+	}
+}
+
+// recordingClient answers map prompts with their chunk text and reduce prompts
+// with a count of their inputs.
+type recordingClient struct {
+	mu      sync.Mutex
+	reduces int
+}
+
+func (c *recordingClient) CreateChatCompletion(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	p := req.Messages[len(req.Messages)-1].Content
+	reply := p
+	if strings.HasPrefix(p, "MAP ") {
+		reply = fmt.Sprintf(`{"issues":[{"text":%q}],"words":1}`, strings.TrimPrefix(p, "MAP "))
+	} else {
+		c.mu.Lock()
+		c.reduces++
+		c.mu.Unlock()
+		reply = fmt.Sprintf("merged(%d)", strings.Count(p, "|")+1)
+	}
+	return openai.ChatCompletionResponse{Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: reply}}}}, nil
+}
+
+func (c *recordingClient) CreateChatCompletionStream(context.Context, openai.ChatCompletionRequest) (*openai.ChatCompletionStream, error) {
+	return nil, errors.New("not implemented")
+}
+
+func newTestAgent(t *testing.T, c *recordingClient) *agent.Agent {
+	t.Helper()
+	a, err := agent.NewWithClient(agent.Config{Key: "k", Endpoint: "https://x.openai.azure.com", Model: "gpt-test"}, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func document(n int) string {
+	var paras []string
+	for i := 0; i < n; i++ {
+		paras = append(paras, fmt.Sprintf("p%d %s", i, strings.Repeat("x", 30)))
+	}
+	return strings.Join(paras, "\n\n")
+}
+
+func TestProcessor_HierarchicalReduce(t *testing.T) {
+	c := &recordingClient{}
+	p := Processor{
+		Agent:        newTestAgent(t, c),
+		Split:        SplitOptions{MaxTokens: 10, Overlap: -1},
+		MapPrompt:    "MAP {{.Chunk.Index}}",
+		ReducePrompt: `{{range $i, $r := .Results}}{{if $i}}|{{end}}{{$r}}{{end}}`,
+		FanIn:        3,
+	}
+	res, err := p.Run(context.Background(), document(7))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(res.Chunks) != 7 || len(res.Mapped) != 7 || len(res.Levels) != 2 {
+		t.Fatalf("expected 7 chunks reduced in 2 levels, got %d chunks, %d levels", len(res.Chunks), len(res.Levels))
+	}
+	if len(res.Sources) != 7 || res.Sources[6] != 6 || res.Levels[0][1].Sources[0] != 3 {
+		t.Fatalf("unexpected provenance: final=%v level0=%+v", res.Sources, res.Levels[0])
+	}
+	// Level 1 reduces groups of 3,3 and passes the last single result through.
+	if c.reduces != 3 || res.Text != "merged(3)" {
+		t.Fatalf("unexpected reduce calls %d / text %q", c.reduces, res.Text)
+	}
+}
+
+func TestProcessor_MergeJSON(t *testing.T) {
+	c := &recordingClient{}
+	p := Processor{
+		Agent:     newTestAgent(t, c),
+		Split:     SplitOptions{MaxTokens: 10, Overlap: -1},
+		MapPrompt: "MAP {{.Chunk.Index}}",
+		Merge:     MergeJSON,
+	}
+	res, err := p.Run(context.Background(), document(3))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var out struct {
+		Issues []struct {
+			Text   string `json:"text"`
+			Source int    `json:"_source_chunk"`
+		} `json:"issues"`
+		Words float64 `json:"words"`
+	}
+	if err := json.Unmarshal([]byte(res.Text), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Issues) != 3 || out.Words != 3 || out.Issues[2].Source != 2 || c.reduces != 0 {
+		t.Fatalf("unexpected merge: %+v (reduces=%d)", out, c.reduces)
+	}
+}
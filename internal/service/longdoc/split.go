@@ -0,0 +1,201 @@
+package longdoc
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// SplitMode selects where Split cuts the text.
+type SplitMode string
+
+const (
+	// SplitTokens cuts at whitespace near the token limit.
+	SplitTokens SplitMode = "tokens"
+	// SplitParagraphs packs whole paragraphs (blank-line separated) into chunks.
+	SplitParagraphs SplitMode = "paragraphs"
+	// SplitHeadings starts a new chunk at each heading (Markdown "#" lines or
+	// ===SECTION=== markers) and packs paragraphs within a section.
+	SplitHeadings SplitMode = "headings"
+)
+
+// SplitOptions configures Split. Zero values use the defaults noted.
+type SplitOptions struct {
+	Mode      SplitMode // default SplitParagraphs
+	MaxTokens int       // estimated tokens per chunk (default 2000)
+	Overlap   int       // estimated tokens repeated from the previous chunk (default 10% of MaxTokens)
+}
+
+func (o SplitOptions) withDefaults() SplitOptions {
+	if o.Mode == "" {
+		o.Mode = SplitParagraphs
+	}
+	if o.MaxTokens <= 0 {
+		o.MaxTokens = 2000
+	}
+	if o.Overlap < 0 || o.Overlap >= o.MaxTokens {
+		o.Overlap = 0
+	} else if o.Overlap == 0 {
+		o.Overlap = o.MaxTokens / 10
+	}
+	return o
+}
+
+// Chunk is a piece of the document. Start and End are byte offsets of the new
+// (non-overlapping) text in the original; Text also carries the overlap.
+type Chunk struct {
+	Index   int    `json:"index"`
+	Text    string `json:"text"`
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	Heading string `json:"heading,omitempty"`
+}
+
+// EstimateTokens is a rough token count (about four bytes per token), the
+// same estimate the agent uses where the API does not report usage.
+func EstimateTokens(s string) int { return (len(s) + 3) / 4 }
+
+// span is a piece of the original text.
+type span struct {
+	start, end int
+	heading    string
+}
+
+// Split cuts text into chunks of at most about o.MaxTokens tokens.
+func Split(text string, o SplitOptions) []Chunk {
+	o = o.withDefaults()
+	var units []span
+	switch o.Mode {
+	case SplitTokens:
+		units = wordSpans(text, 0, len(text), o.MaxTokens)
+	case SplitHeadings:
+		for _, sec := range sections(text) {
+			for _, p := range paragraphs(text, sec.start, sec.end, o.MaxTokens) {
+				p.heading = sec.heading
+				units = append(units, p)
+			}
+		}
+	default:
+		units = paragraphs(text, 0, len(text), o.MaxTokens)
+	}
+	return pack(text, units, o)
+}
+
+// pack groups units into chunks, never mixing sections in heading mode, and
+// prefixes each chunk with the tail of the previous one as overlap.
+func pack(text string, units []span, o SplitOptions) []Chunk {
+	var chunks []Chunk
+	budget := o.MaxTokens * 4
+	var cur *span
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		c := Chunk{Index: len(chunks), Start: cur.start, End: cur.end, Heading: cur.heading}
+		body := text[cur.start:cur.end]
+		if len(chunks) > 0 && o.Overlap > 0 {
+			prev := chunks[len(chunks)-1]
+			body = overlapTail(text[prev.Start:prev.End], o.Overlap*4) + body
+		}
+		c.Text = strings.TrimSpace(body)
+		chunks = append(chunks, c)
+		cur = nil
+	}
+	for _, u := range units {
+		if cur != nil && (u.heading != cur.heading || u.end-cur.start > budget) {
+			flush()
+		}
+		if cur == nil {
+			u := u
+			cur = &u
+			continue
+		}
+		cur.end = u.end
+	}
+	flush()
+	return chunks
+}
+
+// overlapTail returns about n bytes from the end of s, starting at a word.
+func overlapTail(s string, n int) string {
+	if len(s) <= n {
+		return s + "\n\n"
+	}
+	cut := len(s) - n
+	for cut < len(s) && !unicode.IsSpace(rune(s[cut])) {
+		cut++
+	}
+	return strings.TrimSpace(s[cut:]) + "\n\n"
+}
+
+var blankLines = regexp.MustCompile(`\n[ \t]*\n`)
+
+// paragraphs returns the paragraphs of text[start:end]; paragraphs over the
+// token limit are cut further at whitespace.
+func paragraphs(text string, start, end, maxTokens int) []span {
+	var out []span
+	add := func(s, e int) {
+		for s < e && unicode.IsSpace(rune(text[s])) {
+			s++
+		}
+		if s >= e {
+			return
+		}
+		if e-s > maxTokens*4 {
+			out = append(out, wordSpans(text, s, e, maxTokens)...)
+			return
+		}
+		out = append(out, span{start: s, end: e})
+	}
+	pos := start
+	for _, loc := range blankLines.FindAllStringIndex(text[start:end], -1) {
+		add(pos, start+loc[0])
+		pos = start + loc[1]
+	}
+	add(pos, end)
+	return out
+}
+
+// wordSpans cuts text[start:end] into pieces of at most maxTokens, preferring
+// to cut at whitespace.
+func wordSpans(text string, start, end, maxTokens int) []span {
+	budget := maxTokens * 4
+	var out []span
+	for start < end {
+		stop := min(start+budget, end)
+		if stop < end {
+			if i := strings.LastIndexFunc(text[start:stop], unicode.IsSpace); i > 0 {
+				stop = start + i + 1
+			} else {
+				// No whitespace (e.g. Thai script): cut at a rune boundary.
+				for stop > start && !isRuneStart(text[stop]) {
+					stop--
+				}
+			}
+		}
+		out = append(out, span{start: start, end: stop})
+		start = stop
+	}
+	return out
+}
+
+func isRuneStart(b byte) bool { return b&0xC0 != 0x80 }
+
+var headingLine = regexp.MustCompile(`(?m)^(#{1,6}[ \t]+.+|={3}[A-Z ]+={3})[ \t]*$`)
+
+// sections splits text at heading lines; text before the first heading is a
+// section with no heading.
+func sections(text string) []span {
+	var out []span
+	locs := headingLine.FindAllStringIndex(text, -1)
+	prev, heading := 0, ""
+	for _, loc := range locs {
+		if loc[0] > prev {
+			out = append(out, span{start: prev, end: loc[0], heading: heading})
+		}
+		heading = strings.TrimSpace(strings.Trim(strings.TrimLeft(text[loc[0]:loc[1]], "# \t"), "="))
+		prev = loc[0]
+	}
+	out = append(out, span{start: prev, end: len(text), heading: heading})
+	return out
+}
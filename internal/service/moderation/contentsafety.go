@@ -0,0 +1,112 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// contentSafetyMaxChars is the longest text one analyze request accepts.
+const contentSafetyMaxChars = 10000
+
+// ContentSafety classifies text with Azure AI Content Safety
+// (text:analyze). Texts longer than the service limit are sent in parts and
+// the highest severity per category is kept.
+type ContentSafety struct {
+	Endpoint   string // e.g. https://<resource>.cognitiveservices.azure.com
+	Key        string
+	APIVersion string       // empty uses 2024-09-01
+	HTTPClient *http.Client // nil uses http.DefaultClient
+	// Categories limits the analysis; nil analyses all built-in categories.
+	Categories []Category
+}
+
+var contentSafetyNames = map[Category]string{Hate: "Hate", SelfHarm: "SelfHarm", Sexual: "Sexual", Violence: "Violence"}
+
+func (c ContentSafety) Moderate(ctx context.Context, text string) (Result, error) {
+	res := Result{Classifier: "content_safety", Severity: map[Category]int{}}
+	runes := []rune(text)
+	for start := 0; start < len(runes); start += contentSafetyMaxChars {
+		part := string(runes[start:min(start+contentSafetyMaxChars, len(runes))])
+		sev, err := c.analyze(ctx, part)
+		if err != nil {
+			return res, err
+		}
+		for cat, s := range sev {
+			res.Severity[cat] = max(res.Severity[cat], s)
+		}
+	}
+	return res, nil
+}
+
+func (c ContentSafety) analyze(ctx context.Context, text string) (map[Category]int, error) {
+	version := c.APIVersion
+	if version == "" {
+		version = "2024-09-01"
+	}
+	cats := c.Categories
+	if cats == nil {
+		cats = Categories
+	}
+	body := struct {
+		Text       string   `json:"text"`
+		Categories []string `json:"categories"`
+		OutputType string   `json:"outputType"`
+	}{Text: text, OutputType: "EightSeverityLevels"}
+	for _, cat := range cats {
+		name, ok := contentSafetyNames[cat]
+		if !ok {
+			return nil, fmt.Errorf("content safety has no category %q", cat)
+		}
+		body.Categories = append(body.Categories, name)
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	url := strings.TrimRight(c.Endpoint, "/") + "/contentsafety/text:analyze?api-version=" + version
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Ocp-Apim-Subscription-Key", c.Key)
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("content safety: %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	var out struct {
+		CategoriesAnalysis []struct {
+			Category string `json:"category"`
+			Severity int    `json:"severity"`
+		} `json:"categoriesAnalysis"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("content safety: %w", err)
+	}
+	sev := map[Category]int{}
+	for _, a := range out.CategoriesAnalysis {
+		for cat, name := range contentSafetyNames {
+			if name == a.Category {
+				sev[cat] = a.Severity
+			}
+		}
+	}
+	return sev, nil
+}
@@ -0,0 +1,264 @@
+// Package moderation screens student input and model output for harmful
+// content such as self-harm or abuse. A Classifier rates each category by
+// severity and a Policy decides per category whether to allow the text, flag
+// it (for example to a school counsellor) or block it.
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go-azure-openai/internal/service/prompt"
+)
+
+// Category is a kind of harmful content.
+type Category string
+
+// The categories of Azure AI Content Safety. Custom classifiers may report
+// others; Policy.Default applies to them.
+const (
+	Hate     Category = "hate"
+	SelfHarm Category = "self_harm"
+	Sexual   Category = "sexual"
+	Violence Category = "violence"
+)
+
+// Categories lists the built-in categories.
+var Categories = []Category{Hate, SelfHarm, Sexual, Violence}
+
+// MaxSeverity is the top of the severity scale; 0 means safe.
+const MaxSeverity = 7
+
+// Result is a classifier's rating of one text.
+type Result struct {
+	Classifier string           `json:"classifier"`
+	Severity   map[Category]int `json:"severity"` // 0 (safe) to MaxSeverity
+	Reason     string           `json:"reason,omitempty"`
+}
+
+// Classifier rates text by category.
+type Classifier interface {
+	Moderate(ctx context.Context, text string) (Result, error)
+}
+
+// Action is what a Policy does with a text.
+type Action string
+
+const (
+	Allow Action = "allow"
+	Flag  Action = "flag"  // let the text through and report it, e.g. to a counsellor
+	Block Action = "block" // stop the call
+)
+
+func (a Action) rank() int {
+	switch a {
+	case Flag:
+		return 1
+	case Block:
+		return 2
+	}
+	return 0
+}
+
+// worse returns the stricter of a and b.
+func worse(a, b Action) Action {
+	if b.rank() > a.rank() {
+		return b
+	}
+	return a
+}
+
+// Rule is the severity from which a category is flagged or blocked; 0
+// disables that action.
+type Rule struct {
+	Flag  int `json:"flag,omitempty"`
+	Block int `json:"block,omitempty"`
+}
+
+func (r Rule) action(severity int) Action {
+	switch {
+	case r.Block > 0 && severity >= r.Block:
+		return Block
+	case r.Flag > 0 && severity >= r.Flag:
+		return Flag
+	}
+	return Allow
+}
+
+// DefaultRules flag every category from low severity and block abuse at
+// medium severity. Self-harm is only flagged: a student writing about it
+// needs a counsellor, not a refused answer.
+var DefaultRules = map[Category]Rule{
+	Hate:     {Flag: 2, Block: 4},
+	SelfHarm: {Flag: 2},
+	Sexual:   {Flag: 2, Block: 4},
+	Violence: {Flag: 2, Block: 6},
+}
+
+// Stage is where in a call a text was moderated.
+type Stage string
+
+const (
+	Input  Stage = "input"
+	Output Stage = "output"
+)
+
+// Check is the moderation of one text.
+type Check struct {
+	Stage      Stage            `json:"stage"`
+	Classifier string           `json:"classifier,omitempty"`
+	Severity   map[Category]int `json:"severity,omitempty"`
+	Reason     string           `json:"reason,omitempty"`
+	Action     Action           `json:"action"`
+	Flagged    []Category       `json:"flagged,omitempty"`
+	Blocked    []Category       `json:"blocked,omitempty"`
+	// Error is set when the classifier failed and Policy.FailOpen let the
+	// text through.
+	Error string `json:"error,omitempty"`
+}
+
+// Report is the moderation of a chat call.
+type Report struct {
+	Input  *Check `json:"input,omitempty"`
+	Output *Check `json:"output,omitempty"`
+	Action Action `json:"action"` // the strictest action of both checks
+}
+
+// Add records c in the report.
+func (r *Report) Add(c Check) {
+	if c.Stage == Output {
+		r.Output = &c
+	} else {
+		r.Input = &c
+	}
+	r.Action = worse(worse(Allow, r.Action), c.Action)
+}
+
+// Policy is how a call is moderated.
+type Policy struct {
+	Classifier Classifier
+	// Rules per category; nil uses DefaultRules. Default applies to
+	// categories without a rule.
+	Rules   map[Category]Rule
+	Default Rule
+	// SkipInput and SkipOutput turn off one of the two checks.
+	SkipInput  bool
+	SkipOutput bool
+	// FailOpen lets text through when the classifier fails, recording the
+	// error in the Check; otherwise the call fails.
+	FailOpen bool
+	// OnFlag, when set, is called with the report so far after each check
+	// that flags or blocks something, e.g. to notify a counsellor, even if
+	// the call fails afterwards. ctx is the call's context.
+	OnFlag func(ctx context.Context, r Report)
+}
+
+// Evaluate applies the rules to a classifier result.
+func (p Policy) Evaluate(stage Stage, res Result) Check {
+	rules := p.Rules
+	if rules == nil {
+		rules = DefaultRules
+	}
+	c := Check{Stage: stage, Classifier: res.Classifier, Severity: res.Severity, Reason: res.Reason, Action: Allow}
+	cats := make([]Category, 0, len(res.Severity))
+	for cat := range res.Severity {
+		cats = append(cats, cat)
+	}
+	slices.Sort(cats)
+	for _, cat := range cats {
+		rule, ok := rules[cat]
+		if !ok {
+			rule = p.Default
+		}
+		switch a := rule.action(res.Severity[cat]); a {
+		case Flag:
+			c.Flagged = append(c.Flagged, cat)
+			c.Action = worse(c.Action, a)
+		case Block:
+			c.Blocked = append(c.Blocked, cat)
+			c.Action = worse(c.Action, a)
+		}
+	}
+	return c
+}
+
+// Moderate classifies text and evaluates the result. Empty text is allowed
+// without calling the classifier.
+func (p Policy) Moderate(ctx context.Context, stage Stage, text string) (Check, error) {
+	if strings.TrimSpace(text) == "" || p.Classifier == nil {
+		return Check{Stage: stage, Action: Allow}, nil
+	}
+	res, err := p.Classifier.Moderate(ctx, text)
+	if err != nil {
+		if p.FailOpen {
+			return Check{Stage: stage, Action: Allow, Error: err.Error()}, nil
+		}
+		return Check{}, fmt.Errorf("moderation classifier: %w", err)
+	}
+	return p.Evaluate(stage, res), nil
+}
+
+// BlockedError is returned when a policy blocks the input or output of a call.
+type BlockedError struct {
+	Report Report
+}
+
+func (e *BlockedError) Error() string {
+	c := e.Report.Input
+	if c == nil || c.Action != Block {
+		c = e.Report.Output
+	}
+	var names []string
+	if c != nil {
+		for _, cat := range c.Blocked {
+			names = append(names, string(cat))
+		}
+		return fmt.Sprintf("moderation blocked %s: %s", c.Stage, strings.Join(names, ", "))
+	}
+	return "moderation blocked the call"
+}
+
+const llmSystemPrompt = `You are a content moderator for a school writing platform.
+Rate the text in the user message, which may be in any language, for each category
+on a severity scale from 0 (none) to 7 (extreme):
+- hate: attacks or slurs against people or groups, bullying, harassment
+- self_harm: the writer or others hurting or killing themselves, suicidal thoughts, eating disorders
+- sexual: sexual content
+- violence: threats, glorified or graphic violence
+Mentioning a topic neutrally (a story about a storm, a history essay about a war) is 0-1.
+Respond with JSON only: {"hate": 0, "self_harm": 0, "sexual": 0, "violence": 0, "reason": "short reason"}`
+
+type llm struct{ complete prompt.CompleteFunc }
+
+// LLM returns a classifier that asks a model to rate the text. It reads any
+// language the model does and needs no extra service, at the cost of a model
+// call.
+func LLM(complete prompt.CompleteFunc) Classifier { return llm{complete: complete} }
+
+func (l llm) Moderate(ctx context.Context, text string) (Result, error) {
+	res := Result{Classifier: "llm"}
+	out, err := l.complete(ctx, llmSystemPrompt, text)
+	if err != nil {
+		return res, err
+	}
+	out = prompt.StripFences(out)
+	var raw map[string]any
+	if err := json.Unmarshal([]byte(out), &raw); err != nil {
+		return res, fmt.Errorf("parse moderator reply: %w", err)
+	}
+	res.Severity = map[Category]int{}
+	for k, v := range raw {
+		switch v := v.(type) {
+		case float64:
+			res.Severity[Category(k)] = min(max(int(v), 0), MaxSeverity)
+		case string:
+			if k == "reason" {
+				res.Reason = v
+			}
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,120 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fixed Result
+
+func (f fixed) Moderate(context.Context, string) (Result, error) { return Result(f), nil }
+
+func TestPolicy_Evaluate(t *testing.T) {
+	p := Policy{}
+	c := p.Evaluate(Input, Result{Classifier: "test", Severity: map[Category]int{SelfHarm: 6, Hate: 1, Violence: 2, "spam": 7}})
+	if c.Action != Flag || len(c.Flagged) != 2 || c.Flagged[0] != SelfHarm || c.Flagged[1] != Violence || len(c.Blocked) != 0 {
+		t.Fatalf("unexpected check %+v", c)
+	}
+	p.Default = Rule{Block: 5}
+	if c := p.Evaluate(Input, Result{Severity: map[Category]int{"spam": 7, Hate: 4}}); c.Action != Block || len(c.Blocked) != 2 {
+		t.Fatalf("expected default rule and hate to block, got %+v", c)
+	}
+
+	var rep Report
+	rep.Add(Check{Stage: Input, Action: Flag})
+	rep.Add(Check{Stage: Output, Action: Allow})
+	if rep.Action != Flag || rep.Input == nil || rep.Output == nil {
+		t.Fatalf("unexpected report %+v", rep)
+	}
+}
+
+func TestPolicy_Moderate(t *testing.T) {
+	p := Policy{Classifier: fixed{Classifier: "test", Severity: map[Category]int{Hate: 5}}}
+	c, err := p.Moderate(context.Background(), Output, "you are all idiots")
+	if err != nil || c.Action != Block || c.Stage != Output {
+		t.Fatalf("unexpected check %+v %v", c, err)
+	}
+	if c, _ := p.Moderate(context.Background(), Input, "  "); c.Action != Allow || c.Classifier != "" {
+		t.Fatalf("expected empty text to skip the classifier, got %+v", c)
+	}
+	if msg := (&BlockedError{Report: Report{Output: &c, Action: Block}}).Error(); msg != "moderation blocked output: hate" {
+		t.Fatalf("unexpected message %q", msg)
+	}
+
+	failing := Policy{Classifier: LLM(func(context.Context, string, string) (string, error) { return "", errors.New("boom") })}
+	if _, err := failing.Moderate(context.Background(), Input, "x"); err == nil {
+		t.Fatalf("expected classifier error")
+	}
+	failing.FailOpen = true
+	if c, err := failing.Moderate(context.Background(), Input, "x"); err != nil || c.Action != Allow || c.Error != "boom" {
+		t.Fatalf("expected fail-open check, got %+v %v", c, err)
+	}
+}
+
+func TestLLM(t *testing.T) {
+	var system string
+	l := LLM(func(_ context.Context, sys, user string) (string, error) {
+		system = sys
+		return "```json\n{\"hate\": 0, \"self_harm\": 9, \"sexual\": 0, \"violence\": 1, \"reason\": \"writer mentions wanting to disappear\"}\n```", nil
+	})
+	res, err := l.Moderate(context.Background(), "ฉันไม่อยากอยู่แล้ว")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Classifier != "llm" || res.Severity[SelfHarm] != MaxSeverity || res.Severity[Violence] != 1 || res.Reason == "" {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if !strings.Contains(system, "self_harm") {
+		t.Fatalf("unexpected system prompt %q", system)
+	}
+}
+
+func TestContentSafety(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/contentsafety/text:analyze" || r.URL.Query().Get("api-version") != "2024-09-01" || r.Header.Get("Ocp-Apim-Subscription-Key") != "cs-key" {
+			t.Errorf("unexpected request %s %v", r.URL, r.Header)
+		}
+		var body struct {
+			Text       string   `json:"text"`
+			Categories []string `json:"categories"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		sev := 0
+		if strings.Contains(body.Text, "hurt myself") {
+			sev = 4
+		}
+		json.NewEncoder(w).Encode(map[string]any{"categoriesAnalysis": []map[string]any{
+			{"category": "SelfHarm", "severity": sev},
+			{"category": "Hate", "severity": 0},
+		}})
+	}))
+	defer srv.Close()
+
+	cs := ContentSafety{Endpoint: srv.URL + "/", Key: "cs-key"}
+	text := strings.Repeat("a", contentSafetyMaxChars) + " I want to hurt myself"
+	res, err := cs.Moderate(context.Background(), text)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if calls != 2 || res.Severity[SelfHarm] != 4 || res.Classifier != "content_safety" {
+		t.Fatalf("expected the max of two parts, got %+v after %d calls", res, calls)
+	}
+
+	if _, err := (ContentSafety{Endpoint: srv.URL, Categories: []Category{"spam"}}).Moderate(context.Background(), "x"); err == nil {
+		t.Fatalf("expected unknown category error")
+	}
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"code":"InvalidRequestBody"}}`, http.StatusBadRequest)
+	}))
+	defer bad.Close()
+	if _, err := (ContentSafety{Endpoint: bad.URL}).Moderate(context.Background(), "x"); err == nil || !strings.Contains(err.Error(), "InvalidRequestBody") {
+		t.Fatalf("expected service error, got %v", err)
+	}
+}
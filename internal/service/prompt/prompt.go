@@ -1,6 +1,7 @@
 package prompt
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"sort"
@@ -239,3 +240,41 @@ func atoiSafe(s string) int { var v int; fmt.Sscanf(s, "%d", &v); return v }
 
 // Helper convert string to float with fallback 0.
 func atofSafe(s string) float64 { var f float64; fmt.Sscanf(s, "%f", &f); return f }
+
+// sectionMarkerPattern matches runs of '=' that could form a section marker.
+var sectionMarkerPattern = regexp.MustCompile(`={3,}`)
+
+// EscapeSectionMarkers breaks up "===" runs in untrusted text (such as a student
+// answer) so it cannot open a fake ===INSTRUCTIONS=== or ===CRITERIA=== section.
+func EscapeSectionMarkers(s string) string {
+	return sectionMarkerPattern.ReplaceAllStringFunc(s, func(m string) string {
+		return strings.TrimSpace(strings.Repeat("= ", len(m)))
+	})
+}
+
+// SetAnswer replaces the ===ANSWER=== section of a raw template with answer,
+// escaping section markers in it. The section is appended if missing.
+func SetAnswer(raw, answer string) string {
+	answer = EscapeSectionMarkers(strings.TrimSpace(answer))
+	lines := strings.Split(raw, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(strings.TrimRight(line, "\r")) == "===ANSWER===" {
+			return strings.Join(lines[:i+1], "\n") + "\n" + answer
+		}
+	}
+	return strings.TrimRight(raw, "\n") + "\n===ANSWER===\n" + answer
+}
+
+// CompleteFunc sends a system and user prompt to a model and returns its reply.
+// Classifiers that use a model as the judge take one; an agent adapts to it
+// with a closure around ChatStructured.
+type CompleteFunc func(ctx context.Context, system, user string) (string, error)
+
+// StripFences removes a Markdown code fence (``` or ```json) around a model
+// reply, so the JSON inside can be decoded.
+func StripFences(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "```json")
+	s = strings.TrimPrefix(s, "```")
+	return strings.TrimSpace(strings.TrimSuffix(s, "```"))
+}
@@ -0,0 +1,184 @@
+package realtime
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// Event is a server event delivered on Client.Events.
+type Event interface{ EventType() string }
+
+// SessionEvent reports session.created and session.updated.
+type SessionEvent struct {
+	Type    string          `json:"type"`
+	Session json.RawMessage `json:"session"`
+}
+
+// AudioDelta carries a piece of the model's spoken reply as PCM16.
+type AudioDelta struct {
+	ResponseID string
+	ItemID     string
+	Audio      []byte
+}
+
+// TranscriptDelta carries a piece of the transcript of the model's spoken reply.
+type TranscriptDelta struct {
+	ResponseID string `json:"response_id"`
+	ItemID     string `json:"item_id"`
+	Delta      string `json:"delta"`
+}
+
+// TextDelta carries a piece of a text reply.
+type TextDelta struct {
+	ResponseID string `json:"response_id"`
+	ItemID     string `json:"item_id"`
+	Delta      string `json:"delta"`
+}
+
+// InputTranscript is the transcript of the learner's committed audio.
+type InputTranscript struct {
+	ItemID     string `json:"item_id"`
+	Transcript string `json:"transcript"`
+}
+
+// SpeechStarted and SpeechStopped report server voice activity detection.
+type SpeechStarted struct {
+	ItemID       string `json:"item_id"`
+	AudioStartMS int    `json:"audio_start_ms"`
+}
+
+type SpeechStopped struct {
+	ItemID     string `json:"item_id"`
+	AudioEndMS int    `json:"audio_end_ms"`
+}
+
+// FunctionCall asks the client to run a tool; answer with SendFunctionResult.
+type FunctionCall struct {
+	ResponseID string `json:"response_id"`
+	ItemID     string `json:"item_id"`
+	CallID     string `json:"call_id"`
+	Name       string `json:"name"`
+	Arguments  string `json:"arguments"`
+}
+
+// ResponseDone ends a response.
+type ResponseDone struct {
+	Response struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+		Usage  *struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+			TotalTokens  int `json:"total_tokens"`
+		} `json:"usage,omitempty"`
+	} `json:"response"`
+}
+
+// ErrorEvent is an error reported by the server; the session stays open.
+type ErrorEvent struct {
+	Error struct {
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		EventID string `json:"event_id"`
+	} `json:"error"`
+}
+
+// Reconnected is emitted after the client re-established a dropped
+// connection and replayed the last session update. Audio appended but not
+// committed before the drop is lost.
+type Reconnected struct {
+	Attempt int
+}
+
+// Disconnected is the last event before Events is closed when the connection
+// could not be re-established.
+type Disconnected struct {
+	Err error
+}
+
+// Unknown is any server event without a typed form.
+type Unknown struct {
+	Type string
+	Raw  json.RawMessage
+}
+
+func (e SessionEvent) EventType() string  { return e.Type }
+func (AudioDelta) EventType() string      { return "response.audio.delta" }
+func (TranscriptDelta) EventType() string { return "response.audio_transcript.delta" }
+func (TextDelta) EventType() string       { return "response.text.delta" }
+func (InputTranscript) EventType() string {
+	return "conversation.item.input_audio_transcription.completed"
+}
+func (SpeechStarted) EventType() string { return "input_audio_buffer.speech_started" }
+func (SpeechStopped) EventType() string { return "input_audio_buffer.speech_stopped" }
+func (FunctionCall) EventType() string  { return "response.function_call_arguments.done" }
+func (ResponseDone) EventType() string  { return "response.done" }
+func (ErrorEvent) EventType() string    { return "error" }
+func (Reconnected) EventType() string   { return "client.reconnected" }
+func (Disconnected) EventType() string  { return "client.disconnected" }
+func (e Unknown) EventType() string     { return e.Type }
+
+// decodeEvent turns a server message into a typed event.
+func decodeEvent(data []byte) (Event, error) {
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, err
+	}
+	var ev Event
+	var err error
+	switch head.Type {
+	case "session.created", "session.updated":
+		var e SessionEvent
+		err = json.Unmarshal(data, &e)
+		ev = e
+	case "response.audio.delta":
+		var raw struct {
+			ResponseID string `json:"response_id"`
+			ItemID     string `json:"item_id"`
+			Delta      string `json:"delta"`
+		}
+		if err = json.Unmarshal(data, &raw); err == nil {
+			var pcm []byte
+			pcm, err = base64.StdEncoding.DecodeString(raw.Delta)
+			ev = AudioDelta{ResponseID: raw.ResponseID, ItemID: raw.ItemID, Audio: pcm}
+		}
+	case "response.audio_transcript.delta":
+		var e TranscriptDelta
+		err = json.Unmarshal(data, &e)
+		ev = e
+	case "response.text.delta":
+		var e TextDelta
+		err = json.Unmarshal(data, &e)
+		ev = e
+	case "conversation.item.input_audio_transcription.completed":
+		var e InputTranscript
+		err = json.Unmarshal(data, &e)
+		ev = e
+	case "input_audio_buffer.speech_started":
+		var e SpeechStarted
+		err = json.Unmarshal(data, &e)
+		ev = e
+	case "input_audio_buffer.speech_stopped":
+		var e SpeechStopped
+		err = json.Unmarshal(data, &e)
+		ev = e
+	case "response.function_call_arguments.done":
+		var e FunctionCall
+		err = json.Unmarshal(data, &e)
+		ev = e
+	case "response.done":
+		var e ResponseDone
+		err = json.Unmarshal(data, &e)
+		ev = e
+	case "error":
+		var e ErrorEvent
+		err = json.Unmarshal(data, &e)
+		ev = e
+	default:
+		ev = Unknown{Type: head.Type, Raw: append(json.RawMessage(nil), data...)}
+	}
+	return ev, err
+}
@@ -0,0 +1,333 @@
+// Package realtime is a client for the Azure OpenAI (and OpenAI) Realtime API,
+// used for live speaking drills: it streams PCM16 microphone audio over a
+// WebSocket and delivers the model's audio, transcripts and function calls as
+// typed events. Dropped connections are re-established and the last session
+// configuration is replayed.
+package realtime
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultAPIVersion is the Azure api-version used when Config.APIVersion is empty.
+const DefaultAPIVersion = "2024-10-01-preview"
+
+// ErrClosed is returned by writes after Close.
+var ErrClosed = errors.New("realtime: client closed")
+
+// Config describes where and how to connect.
+type Config struct {
+	// Endpoint is the Azure resource URL (https://x.openai.azure.com). For
+	// OpenAI set Provider to "openai" and leave Endpoint empty.
+	Endpoint   string
+	Key        string
+	Deployment string // realtime deployment (Azure) or model name (OpenAI)
+	APIVersion string // azure only; default DefaultAPIVersion
+	Provider   string // "azure" (default) or "openai"
+	// URL overrides the computed WebSocket URL, e.g. for a local stand-in.
+	URL string
+
+	MaxReconnects    int           // attempts after a drop (default 3; negative disables)
+	ReconnectBackoff time.Duration // delay before the first attempt, doubled each time (default 500ms)
+	Dialer           *websocket.Dialer
+}
+
+func (c Config) withDefaults() Config {
+	if c.APIVersion == "" {
+		c.APIVersion = DefaultAPIVersion
+	}
+	if c.MaxReconnects == 0 {
+		c.MaxReconnects = 3
+	}
+	if c.ReconnectBackoff <= 0 {
+		c.ReconnectBackoff = 500 * time.Millisecond
+	}
+	if c.Dialer == nil {
+		c.Dialer = websocket.DefaultDialer
+	}
+	return c
+}
+
+// target returns the WebSocket URL and auth headers for c.
+func (c Config) target() (string, http.Header, error) {
+	h := http.Header{}
+	if c.Provider == "openai" {
+		h.Set("Authorization", "Bearer "+c.Key)
+		h.Set("OpenAI-Beta", "realtime=v1")
+		if c.URL != "" {
+			return c.URL, h, nil
+		}
+		return "wss://api.openai.com/v1/realtime?model=" + url.QueryEscape(c.Deployment), h, nil
+	}
+	h.Set("api-key", c.Key)
+	if c.URL != "" {
+		return c.URL, h, nil
+	}
+	u, err := url.Parse(strings.TrimRight(c.Endpoint, "/"))
+	if err != nil || u.Host == "" {
+		return "", nil, fmt.Errorf("invalid realtime endpoint %q", c.Endpoint)
+	}
+	u.Scheme = "wss"
+	u.Path += "/openai/realtime"
+	u.RawQuery = url.Values{"api-version": {c.APIVersion}, "deployment": {c.Deployment}}.Encode()
+	return u.String(), h, nil
+}
+
+// Session is the session configuration sent with UpdateSession. Empty fields
+// are left unchanged on the server.
+type Session struct {
+	Modalities              []string       `json:"modalities,omitempty"` // "text", "audio"
+	Instructions            string         `json:"instructions,omitempty"`
+	Voice                   string         `json:"voice,omitempty"`
+	InputAudioFormat        string         `json:"input_audio_format,omitempty"`  // "pcm16"
+	OutputAudioFormat       string         `json:"output_audio_format,omitempty"` // "pcm16"
+	InputAudioTranscription *Transcription `json:"input_audio_transcription,omitempty"`
+	TurnDetection           *TurnDetection `json:"turn_detection,omitempty"`
+	Tools                   []Tool         `json:"tools,omitempty"`
+	ToolChoice              string         `json:"tool_choice,omitempty"`
+	Temperature             float64        `json:"temperature,omitempty"`
+}
+
+// Transcription enables transcripts of the learner's audio.
+type Transcription struct {
+	Model string `json:"model"` // e.g. "whisper-1"
+}
+
+// TurnDetection configures server voice activity detection.
+type TurnDetection struct {
+	Type              string  `json:"type"` // "server_vad"
+	Threshold         float64 `json:"threshold,omitempty"`
+	PrefixPaddingMS   int     `json:"prefix_padding_ms,omitempty"`
+	SilenceDurationMS int     `json:"silence_duration_ms,omitempty"`
+}
+
+// Tool is a function the model may call.
+type Tool struct {
+	Type        string          `json:"type"` // "function"
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// Client is one Realtime session. Writes are safe for concurrent use.
+type Client struct {
+	cfg    Config
+	events chan Event
+
+	mu      sync.Mutex // guards conn, session and closed; serializes writes
+	conn    *websocket.Conn
+	session *Session // last UpdateSession, replayed after a reconnect
+	closed  bool
+	done    chan struct{}
+}
+
+// Dial opens a session. Events must be drained until the channel is closed.
+func Dial(ctx context.Context, cfg Config) (*Client, error) {
+	cfg = cfg.withDefaults()
+	conn, err := dial(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{cfg: cfg, conn: conn, events: make(chan Event, 64), done: make(chan struct{})}
+	go c.readLoop(conn)
+	return c, nil
+}
+
+func dial(ctx context.Context, cfg Config) (*websocket.Conn, error) {
+	u, h, err := cfg.target()
+	if err != nil {
+		return nil, err
+	}
+	conn, resp, err := cfg.Dialer.DialContext(ctx, u, h)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("realtime dial: %w (HTTP %d)", err, resp.StatusCode)
+		}
+		return nil, fmt.Errorf("realtime dial: %w", err)
+	}
+	return conn, nil
+}
+
+// Events returns the server event stream. It is closed after Close or after
+// a Disconnected event.
+func (c *Client) Events() <-chan Event { return c.events }
+
+// UpdateSession sends session.update and remembers s for reconnects.
+func (c *Client) UpdateSession(s Session) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.session = &s
+	return c.writeLocked(map[string]any{"type": "session.update", "session": s})
+}
+
+// AppendAudio sends a frame of 16-bit little-endian mono PCM (24 kHz).
+func (c *Client) AppendAudio(pcm []byte) error {
+	return c.send(map[string]any{"type": "input_audio_buffer.append", "audio": base64.StdEncoding.EncodeToString(pcm)})
+}
+
+// CommitAudio ends the learner's turn when server VAD is off.
+func (c *Client) CommitAudio() error {
+	return c.send(map[string]any{"type": "input_audio_buffer.commit"})
+}
+
+// ClearAudio drops uncommitted audio.
+func (c *Client) ClearAudio() error {
+	return c.send(map[string]any{"type": "input_audio_buffer.clear"})
+}
+
+// CreateResponse asks the model to respond.
+func (c *Client) CreateResponse() error {
+	return c.send(map[string]any{"type": "response.create"})
+}
+
+// CancelResponse stops the response in progress, e.g. when the learner interrupts.
+func (c *Client) CancelResponse() error {
+	return c.send(map[string]any{"type": "response.cancel"})
+}
+
+// SendFunctionResult returns the output of a FunctionCall and asks the model to continue.
+func (c *Client) SendFunctionResult(callID, output string) error {
+	err := c.send(map[string]any{
+		"type": "conversation.item.create",
+		"item": map[string]any{"type": "function_call_output", "call_id": callID, "output": output},
+	})
+	if err != nil {
+		return err
+	}
+	return c.CreateResponse()
+}
+
+func (c *Client) send(v any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.writeLocked(v)
+}
+
+func (c *Client) writeLocked(v any) error {
+	if c.closed {
+		return ErrClosed
+	}
+	return c.conn.WriteJSON(v)
+}
+
+// Close ends the session and closes Events.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	close(c.done)
+	conn := c.conn
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	c.mu.Unlock()
+	return conn.Close()
+}
+
+func (c *Client) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// emit delivers ev unless the client is closing.
+func (c *Client) emit(ev Event) bool {
+	select {
+	case c.events <- ev:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// readLoop reads events until the connection drops, then reconnects.
+func (c *Client) readLoop(conn *websocket.Conn) {
+	defer close(c.events)
+	for {
+		_, data, err := conn.ReadMessage()
+		if err == nil {
+			ev, derr := decodeEvent(data)
+			if derr != nil {
+				ev = Unknown{Type: "client.decode_error", Raw: append(json.RawMessage(nil), data...)}
+			}
+			if !c.emit(ev) {
+				return
+			}
+			continue
+		}
+		if c.isClosed() {
+			return
+		}
+		next, rerr := c.reconnect(err)
+		if rerr != nil {
+			c.emit(Disconnected{Err: rerr})
+			// give up like Close does, so the last socket is not leaked
+			c.mu.Lock()
+			if !c.closed {
+				c.closed = true
+				close(c.done)
+			}
+			last := c.conn
+			c.mu.Unlock()
+			last.Close()
+			return
+		}
+		conn = next
+	}
+}
+
+// reconnect redials with exponential backoff and replays the last session update.
+func (c *Client) reconnect(cause error) (*websocket.Conn, error) {
+	if c.cfg.MaxReconnects < 0 {
+		return nil, cause
+	}
+	backoff := c.cfg.ReconnectBackoff
+	for attempt := 1; attempt <= c.cfg.MaxReconnects; attempt++ {
+		select {
+		case <-time.After(backoff):
+		case <-c.done:
+			return nil, ErrClosed
+		}
+		backoff *= 2
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		conn, err := dial(ctx, c.cfg)
+		cancel()
+		if err != nil {
+			cause = err
+			continue
+		}
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			conn.Close()
+			return nil, ErrClosed
+		}
+		c.conn = conn
+		if c.session != nil {
+			err = conn.WriteJSON(map[string]any{"type": "session.update", "session": c.session})
+		}
+		c.mu.Unlock()
+		if err != nil {
+			conn.Close()
+			cause = err
+			continue
+		}
+		if !c.emit(Reconnected{Attempt: attempt}) {
+			return nil, ErrClosed
+		}
+		return conn, nil
+	}
+	return nil, fmt.Errorf("realtime: reconnect failed after %d attempts: %w", c.cfg.MaxReconnects, cause)
+}
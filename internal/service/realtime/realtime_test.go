@@ -0,0 +1,243 @@
+package realtime
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// standIn is a local Realtime server. handle runs once per connection with
+// the connection number (starting at 1).
+func standIn(t *testing.T, handle func(n int, conn *websocket.Conn)) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var conns atomic.Int32
+	up := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("api-key") != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		conn, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handle(int(conns.Add(1)), conn)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &conns
+}
+
+func wsURL(srv *httptest.Server) string { return "ws" + strings.TrimPrefix(srv.URL, "http") }
+
+func readType(t *testing.T, conn *websocket.Conn) map[string]any {
+	t.Helper()
+	var msg map[string]any
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Errorf("read client event: %v", err)
+	}
+	return msg
+}
+
+func next(t *testing.T, c *Client) Event {
+	t.Helper()
+	select {
+	case ev, ok := <-c.Events():
+		if !ok {
+			t.Fatalf("events closed")
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for event")
+	}
+	return nil
+}
+
+func TestConfigTarget(t *testing.T) {
+	u, h, err := Config{Endpoint: "https://x.openai.azure.com/", Key: "k", Deployment: "gpt-4o-realtime"}.withDefaults().target()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := "wss://x.openai.azure.com/openai/realtime?api-version=" + DefaultAPIVersion + "&deployment=gpt-4o-realtime"
+	if u != want || h.Get("api-key") != "k" {
+		t.Fatalf("unexpected target %q %v", u, h)
+	}
+	u, h, _ = Config{Provider: "openai", Key: "k", Deployment: "gpt-4o-realtime-preview"}.target()
+	if !strings.HasPrefix(u, "wss://api.openai.com/v1/realtime?model=") || h.Get("Authorization") != "Bearer k" {
+		t.Fatalf("unexpected openai target %q %v", u, h)
+	}
+	if _, _, err := (Config{Endpoint: "not a url"}).target(); err == nil {
+		t.Fatalf("expected error for bad endpoint")
+	}
+}
+
+func TestDial_Unauthorized(t *testing.T) {
+	srv, _ := standIn(t, func(int, *websocket.Conn) {})
+	if _, err := Dial(context.Background(), Config{URL: wsURL(srv), Key: "wrong"}); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected 401 error, got %v", err)
+	}
+}
+
+func TestClient_SessionAudioAndEvents(t *testing.T) {
+	pcm := []byte{1, 0, 2, 0, 3, 0}
+	srv, _ := standIn(t, func(_ int, conn *websocket.Conn) {
+		if msg := readType(t, conn); msg["type"] != "session.update" || msg["session"].(map[string]any)["voice"] != "alloy" {
+			t.Errorf("unexpected session update %v", msg)
+		}
+		conn.WriteJSON(map[string]any{"type": "session.updated", "session": map[string]any{"voice": "alloy"}})
+		msg := readType(t, conn)
+		if got, _ := base64.StdEncoding.DecodeString(msg["audio"].(string)); msg["type"] != "input_audio_buffer.append" || string(got) != string(pcm) {
+			t.Errorf("unexpected audio append %v", msg)
+		}
+		if msg := readType(t, conn); msg["type"] != "input_audio_buffer.commit" {
+			t.Errorf("expected commit, got %v", msg)
+		}
+		for _, ev := range []map[string]any{
+			{"type": "conversation.item.input_audio_transcription.completed", "item_id": "i1", "transcript": "I goes to school"},
+			{"type": "response.audio_transcript.delta", "response_id": "r1", "delta": "You should say"},
+			{"type": "response.audio.delta", "response_id": "r1", "delta": base64.StdEncoding.EncodeToString(pcm)},
+			{"type": "response.function_call_arguments.done", "call_id": "c1", "name": "record_error", "arguments": `{"word":"goes"}`},
+			{"type": "rate_limits.updated"},
+		} {
+			conn.WriteJSON(ev)
+		}
+		if msg := readType(t, conn); msg["type"] != "conversation.item.create" || msg["item"].(map[string]any)["call_id"] != "c1" {
+			t.Errorf("unexpected function output %v", msg)
+		}
+		if msg := readType(t, conn); msg["type"] != "response.create" {
+			t.Errorf("expected response.create, got %v", msg)
+		}
+		conn.WriteJSON(map[string]any{"type": "response.done", "response": map[string]any{"id": "r1", "status": "completed", "usage": map[string]any{"total_tokens": 42}}})
+		conn.ReadMessage() // wait for close
+	})
+
+	c, err := Dial(context.Background(), Config{URL: wsURL(srv), Key: "secret"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer c.Close()
+	if err := c.UpdateSession(Session{Voice: "alloy", InputAudioFormat: "pcm16", TurnDetection: &TurnDetection{Type: "server_vad"}}); err != nil {
+		t.Fatalf("update session: %v", err)
+	}
+	if ev, ok := next(t, c).(SessionEvent); !ok || ev.EventType() != "session.updated" {
+		t.Fatalf("expected session.updated, got %#v", ev)
+	}
+	if err := c.AppendAudio(pcm); err != nil {
+		t.Fatalf("append audio: %v", err)
+	}
+	if err := c.CommitAudio(); err != nil {
+		t.Fatalf("commit audio: %v", err)
+	}
+	if ev, ok := next(t, c).(InputTranscript); !ok || ev.Transcript != "I goes to school" {
+		t.Fatalf("unexpected input transcript %#v", ev)
+	}
+	if ev, ok := next(t, c).(TranscriptDelta); !ok || ev.Delta != "You should say" {
+		t.Fatalf("unexpected transcript delta %#v", ev)
+	}
+	if ev, ok := next(t, c).(AudioDelta); !ok || string(ev.Audio) != string(pcm) || ev.ResponseID != "r1" {
+		t.Fatalf("unexpected audio delta %#v", ev)
+	}
+	call, ok := next(t, c).(FunctionCall)
+	if !ok || call.Name != "record_error" || call.Arguments != `{"word":"goes"}` {
+		t.Fatalf("unexpected function call %#v", call)
+	}
+	if ev, ok := next(t, c).(Unknown); !ok || ev.Type != "rate_limits.updated" || !json.Valid(ev.Raw) {
+		t.Fatalf("unexpected unknown event %#v", ev)
+	}
+	if err := c.SendFunctionResult(call.CallID, `{"ok":true}`); err != nil {
+		t.Fatalf("send function result: %v", err)
+	}
+	done, ok := next(t, c).(ResponseDone)
+	if !ok || done.Response.Status != "completed" || done.Response.Usage == nil || done.Response.Usage.TotalTokens != 42 {
+		t.Fatalf("unexpected response.done %#v", done)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := c.CreateResponse(); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	for range c.Events() {
+	}
+}
+
+func TestClient_ReconnectReplaysSession(t *testing.T) {
+	srv, conns := standIn(t, func(n int, conn *websocket.Conn) {
+		msg := readType(t, conn)
+		if msg["type"] != "session.update" || msg["session"].(map[string]any)["instructions"] != "Be a tutor" {
+			t.Errorf("connection %d: unexpected first message %v", n, msg)
+		}
+		if n == 1 {
+			return // drop the connection without a close frame
+		}
+		conn.WriteJSON(map[string]any{"type": "response.text.delta", "delta": "welcome back"})
+		conn.ReadMessage()
+	})
+
+	c, err := Dial(context.Background(), Config{URL: wsURL(srv), Key: "secret", ReconnectBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer c.Close()
+	if err := c.UpdateSession(Session{Instructions: "Be a tutor"}); err != nil {
+		t.Fatalf("update session: %v", err)
+	}
+	if ev, ok := next(t, c).(Reconnected); !ok || ev.Attempt != 1 {
+		t.Fatalf("expected reconnected, got %#v", ev)
+	}
+	if ev, ok := next(t, c).(TextDelta); !ok || ev.Delta != "welcome back" {
+		t.Fatalf("unexpected event after reconnect %#v", ev)
+	}
+	if conns.Load() != 2 {
+		t.Fatalf("expected 2 connections, got %d", conns.Load())
+	}
+}
+
+func TestClient_DisconnectedAfterRetries(t *testing.T) {
+	var dials atomic.Int32
+	up := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if dials.Add(1) > 1 {
+			http.NotFound(w, r) // every redial fails
+			return
+		}
+		if conn, err := up.Upgrade(w, r, nil); err == nil {
+			conn.Close()
+		}
+	}))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{URL: wsURL(srv), Key: "secret", MaxReconnects: 2, ReconnectBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer c.Close()
+	ev, ok := next(t, c).(Disconnected)
+	if !ok || ev.Err == nil || !strings.Contains(ev.Err.Error(), "after 2 attempts") {
+		t.Fatalf("expected disconnected, got %#v", ev)
+	}
+	if _, open := <-c.Events(); open {
+		t.Fatalf("expected events to be closed")
+	}
+	if err := c.AppendAudio([]byte{0, 0}); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	// the client shut down on its own: the socket is closed and Close is a no-op
+	if err := c.conn.WriteMessage(websocket.TextMessage, nil); err == nil {
+		t.Fatalf("expected the last socket to be closed")
+	}
+	select {
+	case <-c.done:
+	default:
+		t.Fatalf("expected done to be closed")
+	}
+}
@@ -0,0 +1,293 @@
+// Package redact finds personal data (emails, phone numbers, Thai national ID
+// numbers, known names) in text and replaces it with stable placeholders such
+// as [EMAIL_1], so prompts can leave our network without it. A Session keeps
+// the placeholder mapping to restore the original values in model output and
+// to produce a report for compliance that never contains the values themselves.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Kind is the category of a detected value; it names the placeholder.
+type Kind string
+
+const (
+	KindEmail  Kind = "EMAIL"
+	KindPhone  Kind = "PHONE"
+	KindThaiID Kind = "THAI_ID"
+	KindName   Kind = "NAME"
+)
+
+// Match is a detected value at text[Start:End].
+type Match struct {
+	Start, End int
+	Kind       Kind
+}
+
+// Detector finds values to redact.
+type Detector interface {
+	Detect(text string) []Match
+}
+
+// DetectorFunc adapts a function to Detector.
+type DetectorFunc func(text string) []Match
+
+// Detect implements Detector.
+func (f DetectorFunc) Detect(text string) []Match { return f(text) }
+
+// Regex returns a detector reporting every match of re as kind.
+func Regex(kind Kind, re *regexp.Regexp) Detector {
+	return DetectorFunc(func(text string) []Match {
+		var out []Match
+		for _, loc := range re.FindAllStringIndex(text, -1) {
+			out = append(out, Match{Start: loc[0], End: loc[1], Kind: kind})
+		}
+		return out
+	})
+}
+
+var (
+	emailRe = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// Thai mobile (06/08/09) and landline numbers, local or +66, with optional separators.
+	phoneRe  = regexp.MustCompile(`(?:\+66[ \-]?|\b0)(?:[689]\d|[2-7])[ \-]?\d{3}[ \-]?\d{3,4}\b`)
+	thaiIDRe = regexp.MustCompile(`\b\d[ \-]?\d{4}[ \-]?\d{5}[ \-]?\d{2}[ \-]?\d\b`)
+)
+
+// Email detects email addresses.
+func Email() Detector { return Regex(KindEmail, emailRe) }
+
+// Phone detects Thai phone numbers.
+func Phone() Detector { return Regex(KindPhone, phoneRe) }
+
+// ThaiID detects 13-digit Thai national ID numbers, with or without the usual
+// separators (1-2345-67890-12-1), that pass the check digit.
+func ThaiID() Detector {
+	return DetectorFunc(func(text string) []Match {
+		var out []Match
+		for _, loc := range thaiIDRe.FindAllStringIndex(text, -1) {
+			if ValidThaiID(text[loc[0]:loc[1]]) {
+				out = append(out, Match{Start: loc[0], End: loc[1], Kind: KindThaiID})
+			}
+		}
+		return out
+	})
+}
+
+// ValidThaiID reports whether id has 13 digits (separators ignored) whose last
+// digit is the check digit of the first twelve.
+func ValidThaiID(id string) bool {
+	var d []int
+	for _, r := range id {
+		switch {
+		case r >= '0' && r <= '9':
+			d = append(d, int(r-'0'))
+		case r == '-' || r == ' ':
+		default:
+			return false
+		}
+	}
+	if len(d) != 13 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 12; i++ {
+		sum += d[i] * (13 - i)
+	}
+	return (11-sum%11)%10 == d[12]
+}
+
+// Names detects the given names case-insensitively, e.g. the class roster.
+// Latin names only match on word boundaries; names in other scripts (Thai has
+// no spaces between words) match anywhere.
+func Names(names ...string) Detector {
+	sorted := make([]string, 0, len(names))
+	for _, n := range names {
+		if n = strings.TrimSpace(n); n != "" {
+			sorted = append(sorted, n)
+		}
+	}
+	if len(sorted) == 0 {
+		return DetectorFunc(func(string) []Match { return nil })
+	}
+	// Longest first so "John Doe" wins over "John".
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	alts := make([]string, len(sorted))
+	for i, n := range sorted {
+		q := regexp.QuoteMeta(n)
+		if r, _ := utf8.DecodeRuneInString(n); r < utf8.RuneSelf && isWord(r) {
+			q = `\b` + q
+		}
+		if r, _ := utf8.DecodeLastRuneInString(n); r < utf8.RuneSelf && isWord(r) {
+			q += `\b`
+		}
+		alts[i] = q
+	}
+	return Regex(KindName, regexp.MustCompile(`(?i)(?:`+strings.Join(alts, "|")+`)`))
+}
+
+func isWord(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+// Default returns the built-in detectors: emails, Thai phone numbers and Thai
+// national IDs. Add Names for rosters.
+func Default() []Detector { return []Detector{Email(), Phone(), ThaiID()} }
+
+// Redactor holds the detectors to run. It is safe for concurrent use.
+type Redactor struct {
+	detectors []Detector
+}
+
+// New returns a Redactor running detectors; with none it uses Default().
+func New(detectors ...Detector) *Redactor {
+	if len(detectors) == 0 {
+		detectors = Default()
+	}
+	return &Redactor{detectors: detectors}
+}
+
+// NewSession starts a placeholder mapping. Use one session per request (or per
+// conversation) so the same value always gets the same placeholder.
+func (r *Redactor) NewSession() *Session {
+	return &Session{r: r, byValue: map[string]string{}, byPlaceholder: map[string]string{}, counts: map[Kind]int{}}
+}
+
+// Finding is one distinct redacted value in a report.
+type Finding struct {
+	Kind        Kind   `json:"kind"`
+	Placeholder string `json:"placeholder"`
+	Occurrences int    `json:"occurrences"`
+}
+
+// Report summarizes what a session redacted, without the original values.
+type Report struct {
+	Findings []Finding    `json:"findings,omitempty"`
+	Counts   map[Kind]int `json:"counts,omitempty"` // occurrences per kind
+	Total    int          `json:"total"`
+	Restored int          `json:"restored"` // placeholders put back by Restore
+}
+
+// Session redacts text and restores it using a shared mapping. It is safe for
+// concurrent use.
+type Session struct {
+	r *Redactor
+
+	mu            sync.Mutex
+	byValue       map[string]string // normalized value -> placeholder
+	byPlaceholder map[string]string // placeholder -> first original value
+	counts        map[Kind]int      // placeholders issued per kind
+	findings      []Finding
+	restored      int
+}
+
+// Redact replaces every detected value in text with its placeholder.
+func (s *Session) Redact(text string) string {
+	matches := s.detect(text)
+	if len(matches) == 0 {
+		return text
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(text[last:m.Start])
+		b.WriteString(s.placeholder(m.Kind, text[m.Start:m.End]))
+		last = m.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// detect runs all detectors and keeps non-overlapping matches, preferring the
+// earliest and then the longest.
+func (s *Session) detect(text string) []Match {
+	var all []Match
+	for _, d := range s.r.detectors {
+		all = append(all, d.Detect(text)...)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Start != all[j].Start {
+			return all[i].Start < all[j].Start
+		}
+		return all[i].End > all[j].End
+	})
+	out := all[:0]
+	end := 0
+	for _, m := range all {
+		if m.Start >= end && m.End > m.Start {
+			out = append(out, m)
+			end = m.End
+		}
+	}
+	return out
+}
+
+// placeholder returns the placeholder for value, issuing one on first sight.
+// Values are compared case-insensitively with separators removed, so
+// "081-234-5678" and "0812345678" share a placeholder.
+func (s *Session) placeholder(kind Kind, value string) string {
+	key := string(kind) + ":" + normalize(value)
+	if p, ok := s.byValue[key]; ok {
+		for i := range s.findings {
+			if s.findings[i].Placeholder == p {
+				s.findings[i].Occurrences++
+			}
+		}
+		return p
+	}
+	s.counts[kind]++
+	p := fmt.Sprintf("[%s_%d]", kind, s.counts[kind])
+	s.byValue[key] = p
+	s.byPlaceholder[p] = value
+	s.findings = append(s.findings, Finding{Kind: kind, Placeholder: p, Occurrences: 1})
+	return p
+}
+
+func normalize(v string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, v)
+}
+
+var placeholderRe = regexp.MustCompile(`\[[A-Z_]+_\d+\]`)
+
+// Restore puts the original values back in place of placeholders issued by
+// this session. Unknown placeholders are left as they are.
+func (s *Session) Restore(text string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.byPlaceholder) == 0 {
+		return text
+	}
+	return placeholderRe.ReplaceAllStringFunc(text, func(p string) string {
+		if v, ok := s.byPlaceholder[p]; ok {
+			s.restored++
+			return v
+		}
+		return p
+	})
+}
+
+// Report returns what the session has redacted and restored so far.
+func (s *Session) Report() Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rep := Report{Findings: append([]Finding(nil), s.findings...), Restored: s.restored}
+	for _, f := range s.findings {
+		if rep.Counts == nil {
+			rep.Counts = map[Kind]int{}
+		}
+		rep.Counts[f.Kind] += f.Occurrences
+		rep.Total += f.Occurrences
+	}
+	return rep
+}
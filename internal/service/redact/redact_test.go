@@ -0,0 +1,57 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidThaiID(t *testing.T) {
+	if !ValidThaiID("1-1037-02345-67-9") || !ValidThaiID("1103702345679") {
+		t.Fatalf("expected valid id")
+	}
+	if ValidThaiID("1-1037-02345-67-8") || ValidThaiID("110370234567") {
+		t.Fatalf("expected invalid id")
+	}
+}
+
+func TestSession_RedactAndRestore(t *testing.T) {
+	r := New(append(Default(), Names("John Doe", "สมชาย"))...)
+	s := r.NewSession()
+	in := "Sincerely,\nJohn Doe (john.doe@example.com, 081-234-5678, ID 1-1037-02345-67-9). john doe again, สมชายเขียน, call 0812345678."
+	out := s.Redact(in)
+
+	for _, leaked := range []string{"John", "john.doe@example.com", "081-234-5678", "1-1037-02345-67-9", "สมชาย"} {
+		if strings.Contains(out, leaked) {
+			t.Fatalf("%q leaked in %q", leaked, out)
+		}
+	}
+	for _, want := range []string{"[NAME_1] ([EMAIL_1], [PHONE_1], ID [THAI_ID_1]). [NAME_1] again", "[NAME_2]เขียน", "call [PHONE_1]."} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in %q", want, out)
+		}
+	}
+	if got := s.Redact("Reply to John Doe"); got != "Reply to [NAME_1]" {
+		t.Fatalf("placeholder not stable across calls: %q", got)
+	}
+
+	if got := s.Restore("Dear [NAME_1], score 15/20 [UNKNOWN_9]"); got != "Dear John Doe, score 15/20 [UNKNOWN_9]" {
+		t.Fatalf("unexpected restore: %q", got)
+	}
+
+	rep := s.Report()
+	if rep.Total != 8 || rep.Counts[KindName] != 4 || rep.Counts[KindPhone] != 2 || rep.Restored != 1 {
+		t.Fatalf("unexpected report: %+v", rep)
+	}
+	for _, f := range rep.Findings {
+		if strings.Contains(f.Placeholder, "@") {
+			t.Fatalf("report leaks values: %+v", f)
+		}
+	}
+}
+
+func TestSession_InvalidThaiIDNotRedacted(t *testing.T) {
+	s := New(ThaiID()).NewSession()
+	if got := s.Redact("order 1103702345678"); got != "order 1103702345678" {
+		t.Fatalf("number failing the checksum was redacted: %q", got)
+	}
+}
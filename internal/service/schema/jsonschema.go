@@ -0,0 +1,195 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// node is one JSON Schema object, limited to what a Field can express.
+type node struct {
+	Type        any         `json:"type,omitempty"` // a type name or a list with "null"
+	Description string      `json:"description,omitempty"`
+	Enum        []any       `json:"enum,omitempty"`
+	Default     any         `json:"default,omitempty"`
+	Properties  *properties `json:"properties,omitempty"`
+	Required    []string    `json:"required,omitempty"`
+	Items       *node       `json:"items,omitempty"`
+	// Nullable is the OpenAPI spelling of a null type; it is read, never written.
+	Nullable bool `json:"nullable,omitempty"`
+}
+
+// properties keeps the order of an object's properties through JSON.
+type properties struct {
+	names []string
+	nodes map[string]*node
+}
+
+func (p *properties) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, name := range p.names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(name)
+		v, err := json.Marshal(p.nodes[name])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+func (p *properties) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return errors.New("properties must be an object")
+	}
+	p.nodes = map[string]*node{}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name := t.(string)
+		var n node
+		if err := dec.Decode(&n); err != nil {
+			return fmt.Errorf("property %s: %w", name, err)
+		}
+		if _, dup := p.nodes[name]; !dup {
+			p.names = append(p.names, name)
+		}
+		p.nodes[name] = &n
+	}
+	return nil
+}
+
+// JSONSchema converts fields to a JSON Schema object, keeping the order of
+// the fields.
+func JSONSchema(fields []Field) (string, error) {
+	b, err := json.Marshal(toNode(Field{Type: Object, Fields: fields}))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func toNode(f Field) *node {
+	n := &node{Description: f.Description, Enum: f.Enum, Default: f.Default}
+	if f.Type != "" {
+		n.Type = f.Type
+		if f.Nullable {
+			n.Type = []string{f.Type, "null"}
+			if len(n.Enum) > 0 {
+				n.Enum = append(slices.Clone(n.Enum), nil)
+			}
+		}
+	}
+	objectFields := f.Fields
+	if f.Type == Array {
+		switch {
+		case f.Items != nil:
+			n.Items = toNode(*f.Items)
+		case len(f.Fields) > 0:
+			n.Items = toNode(Field{Type: Object, Fields: f.Fields})
+		}
+		objectFields = nil
+	}
+	if f.Type == Object || len(objectFields) > 0 {
+		n.Properties = &properties{nodes: map[string]*node{}}
+		for _, c := range objectFields {
+			n.Properties.names = append(n.Properties.names, c.Name)
+			n.Properties.nodes[c.Name] = toNode(c)
+			if !c.Optional {
+				n.Required = append(n.Required, c.Name)
+			}
+		}
+	}
+	return n
+}
+
+// FromJSONSchema converts a JSON Schema object to fields. It understands
+// type (a name or a list including "null"), nullable, properties, required,
+// items, enum, description and default; other keywords are ignored.
+func FromJSONSchema(s string) ([]Field, error) {
+	var n node
+	if err := json.Unmarshal([]byte(s), &n); err != nil {
+		return nil, fmt.Errorf("parse JSON Schema: %w", err)
+	}
+	f, err := fromNode("", &n)
+	if err != nil {
+		return nil, err
+	}
+	if f.Type != Object {
+		return nil, fmt.Errorf("top-level schema must be an object, got %q", f.Type)
+	}
+	return f.Fields, nil
+}
+
+func fromNode(name string, n *node) (Field, error) {
+	f := Field{Name: name, Description: n.Description, Default: n.Default, Nullable: n.Nullable}
+	switch t := n.Type.(type) {
+	case nil:
+	case string:
+		f.Type = t
+	case []any:
+		for _, v := range t {
+			s, _ := v.(string)
+			switch {
+			case s == "null":
+				f.Nullable = true
+			case f.Type == "":
+				f.Type = s
+			default:
+				return f, fmt.Errorf("%s: union type %v is not supported", name, t)
+			}
+		}
+	default:
+		return f, fmt.Errorf("%s: invalid type %v", name, t)
+	}
+	if f.Type == "" {
+		switch {
+		case n.Properties != nil:
+			f.Type = Object
+		case n.Items != nil:
+			f.Type = Array
+		}
+	}
+	for _, e := range n.Enum {
+		if e == nil {
+			f.Nullable = true
+			continue
+		}
+		f.Enum = append(f.Enum, e)
+	}
+	if n.Properties != nil {
+		for _, pname := range n.Properties.names {
+			c, err := fromNode(pname, n.Properties.nodes[pname])
+			if err != nil {
+				return f, err
+			}
+			c.Optional = !slices.Contains(n.Required, pname)
+			f.Fields = append(f.Fields, c)
+		}
+	}
+	if n.Items != nil {
+		item, err := fromNode("", n.Items)
+		if err != nil {
+			return f, err
+		}
+		if item.Type == Object && len(item.Fields) > 0 && !item.Nullable && item.Description == "" && len(item.Enum) == 0 {
+			// arrays of objects use Fields, like hand-written trees
+			f.Fields = item.Fields
+		} else {
+			f.Items = &item
+		}
+	}
+	return f, nil
+}
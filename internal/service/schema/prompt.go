@@ -0,0 +1,114 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Structure renders fields as an annotated JSON outline for a prompt:
+//
+//	{
+//	  "club": "club name (string)",
+//	  "founded": "(integer, optional)",
+//	  "players": [
+//	    {
+//	      "name": "player name (string)"
+//	    }
+//	  ]
+//	}
+func Structure(fields []Field) string {
+	var b strings.Builder
+	b.WriteString("{\n")
+	writeFields(&b, fields, "  ")
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func writeFields(b *strings.Builder, fields []Field, indent string) {
+	for i, f := range fields {
+		comma := ","
+		if i == len(fields)-1 {
+			comma = ""
+		}
+		fmt.Fprintf(b, "%s%q: ", indent, f.Name)
+		writeValue(b, f, indent)
+		b.WriteString(comma + "\n")
+	}
+}
+
+func writeValue(b *strings.Builder, f Field, indent string) {
+	switch {
+	case f.Type == Object:
+		b.WriteString("{\n")
+		writeFields(b, f.Fields, indent+"  ")
+		b.WriteString(indent + "}")
+	case f.Type == Array && f.Items == nil && len(f.Fields) > 0:
+		fmt.Fprintf(b, "[\n%s  {\n", indent)
+		writeFields(b, f.Fields, indent+"    ")
+		fmt.Fprintf(b, "%s  }\n%s]", indent, indent)
+	case f.Type == Array:
+		// an array without Items or Fields shows its description, like ["desc"]
+		var item Field
+		if f.Items != nil {
+			item = *f.Items
+		}
+		if item.Description == "" {
+			item.Description = f.Description
+		}
+		b.WriteString("[")
+		writeValue(b, item, indent)
+		b.WriteString("]")
+	default:
+		fmt.Fprintf(b, "%q", describe(f))
+	}
+	if f.Type == Object || f.Type == Array {
+		if notes := notes(f, false); notes != "" {
+			fmt.Fprintf(b, " /* %s */", notes)
+		}
+	}
+}
+
+// describe is the placeholder text of a leaf value, e.g. "age (integer, optional)".
+func describe(f Field) string {
+	n := notes(f, true)
+	switch {
+	case n == "":
+		return f.Description
+	case f.Description == "":
+		return "(" + n + ")"
+	}
+	return f.Description + " (" + n + ")"
+}
+
+func notes(f Field, withType bool) string {
+	var parts []string
+	if withType && f.Type != "" {
+		parts = append(parts, f.Type)
+	}
+	if len(f.Enum) > 0 {
+		parts = append(parts, "one of "+enumList(f.Enum))
+	}
+	if f.Optional {
+		parts = append(parts, "optional")
+	}
+	if f.Nullable {
+		parts = append(parts, "may be null")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// BuildPrompt asks for topic in the JSON structure of fields, followed by
+// extra instructions.
+func BuildPrompt(topic string, fields []Field, instructions []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Please explain %q in JSON format with the following structure:\n", topic)
+	b.WriteString(Structure(fields))
+	if len(instructions) > 0 {
+		b.WriteString("Additional instructions:\n")
+		for _, ins := range instructions {
+			b.WriteString("- " + ins + "\n")
+		}
+	}
+	b.WriteString("Ensure valid JSON only, no extra text.")
+	return b.String()
+}
@@ -0,0 +1,184 @@
+// Package schema describes the JSON a model should answer with. One Field
+// tree drives the prompt that shows the model the expected structure, the
+// validator that checks the answer, JSON Schema conversion in both
+// directions and example output.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSON types of a Field.
+const (
+	String  = "string"
+	Number  = "number"
+	Integer = "integer"
+	Boolean = "boolean"
+	Object  = "object"
+	Array   = "array"
+)
+
+// Field describes one JSON value, usually a property of an object.
+type Field struct {
+	Name        string
+	Type        string // one of the type constants; empty accepts any value
+	Description string
+	// Fields are the properties of an object, or of the objects in an array
+	// when Items is nil.
+	Fields []Field
+	// Items describes the elements of an array, e.g. &Field{Type: String}.
+	Items    *Field
+	Enum     []any // allowed values
+	Optional bool  // the property may be missing
+	Nullable bool  // the value may be null
+	Default  any
+}
+
+// NormalizeType maps shorthand type names (text, int, float, bool) to the
+// type constants; unknown names are returned unchanged.
+func NormalizeType(t string) string {
+	switch strings.ToLower(strings.TrimSpace(t)) {
+	case "text", "string":
+		return String
+	case "int", "integer":
+		return Integer
+	case "float", "number":
+		return Number
+	case "bool", "boolean":
+		return Boolean
+	case "object":
+		return Object
+	case "array":
+		return Array
+	}
+	return t
+}
+
+// FromShorthand builds fields from property specs keyed by dot-separated
+// paths, e.g. {"name": "string", "address.city": "text=Bangkok", "age":
+// "int"}. A spec is a type, optionally followed by =default. Properties
+// not listed in required (by full path) are optional. Properties are sorted
+// by name.
+func FromShorthand(props map[string]string, required []string) ([]Field, error) {
+	req := map[string]bool{}
+	for _, r := range required {
+		req[r] = true
+	}
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var root []Field
+	for _, key := range keys {
+		parts := strings.Split(key, ".")
+		fields := &root
+		for i, part := range parts {
+			path := strings.Join(parts[:i+1], ".")
+			idx := -1
+			for j := range *fields {
+				if (*fields)[j].Name == part {
+					idx = j
+				}
+			}
+			if i == len(parts)-1 {
+				typ, def, err := parseSpec(props[key])
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", key, err)
+				}
+				f := Field{Name: part, Type: typ, Default: def, Optional: !req[path]}
+				if idx >= 0 {
+					// an object already created for a deeper path keeps its fields
+					f.Fields = (*fields)[idx].Fields
+					(*fields)[idx] = f
+				} else {
+					*fields = append(*fields, f)
+				}
+				break
+			}
+			if idx < 0 {
+				*fields = append(*fields, Field{Name: part, Type: Object, Optional: !req[path]})
+				idx = len(*fields) - 1
+			}
+			(*fields)[idx].Type = Object
+			fields = &(*fields)[idx].Fields
+		}
+	}
+	return root, nil
+}
+
+// parseSpec reads "type" or "type=default".
+func parseSpec(spec string) (string, any, error) {
+	typ, def, hasDef := strings.Cut(spec, "=")
+	typ = NormalizeType(typ)
+	if typ == "" {
+		typ = String
+	}
+	if !hasDef {
+		return typ, nil, nil
+	}
+	def = strings.TrimSpace(def)
+	switch typ {
+	case Integer:
+		v, err := strconv.Atoi(def)
+		return typ, v, err
+	case Number:
+		v, err := strconv.ParseFloat(def, 64)
+		return typ, v, err
+	case Boolean:
+		v, err := strconv.ParseBool(def)
+		return typ, v, err
+	}
+	return typ, def, nil
+}
+
+// Example returns a sample value for fields, e.g. to show the model.
+func Example(fields []Field) map[string]any {
+	out := make(map[string]any, len(fields))
+	for _, f := range fields {
+		out[f.Name] = exampleValue(f)
+	}
+	return out
+}
+
+func exampleValue(f Field) any {
+	switch {
+	case f.Default != nil:
+		return f.Default
+	case len(f.Enum) > 0:
+		return f.Enum[0]
+	}
+	switch f.Type {
+	case String:
+		return "example"
+	case Number, Integer:
+		return 0
+	case Boolean:
+		return true
+	case Object:
+		return Example(f.Fields)
+	case Array:
+		if f.Items != nil {
+			return []any{exampleValue(*f.Items)}
+		}
+		if len(f.Fields) > 0 {
+			return []any{Example(f.Fields)}
+		}
+		return []any{}
+	}
+	return nil
+}
+
+// ExampleJSON is Example encoded as JSON.
+func ExampleJSON(fields []Field) string {
+	b, err := json.Marshal(Example(fields))
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
@@ -0,0 +1,140 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var clubSchema = []Field{
+	{Name: "club", Type: String, Description: "club name"},
+	{Name: "founded", Type: Integer, Optional: true},
+	{Name: "promoted", Type: Boolean},
+	{Name: "league", Type: String, Enum: []any{"premier", "championship"}},
+	{Name: "manager", Type: String, Nullable: true},
+	{Name: "players", Type: Array, Fields: []Field{
+		{Name: "name", Type: String, Description: "player name"},
+		{Name: "number", Type: Integer},
+	}},
+	{Name: "colours", Type: Array, Items: &Field{Type: String}, Description: "kit colour"},
+	{Name: "honours", Type: Array, Description: "trophy won", Optional: true},
+	{Name: "stadium", Type: Object, Fields: []Field{{Name: "capacity", Type: Number}}},
+}
+
+func decode(t *testing.T, s string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestValidate(t *testing.T) {
+	valid := `{"club": "Arsenal", "promoted": false, "league": "premier", "manager": null,
+		"players": [{"name": "Saka", "number": 7}], "colours": ["red", "white"], "stadium": {"capacity": 60704}}`
+	if err := Validate(decode(t, valid), clubSchema); err != nil {
+		t.Fatalf("expected valid document, got %v", err)
+	}
+	cases := map[string]string{
+		`{"promoted": false}`: "club: missing field",
+		strings.Replace(valid, `"league": "premier"`, `"league": "serie a"`, 1): `league: must be one of "premier", "championship"`,
+		strings.Replace(valid, `"number": 7`, `"number": 7.5`, 1):               `players[0].number: should be integer`,
+		strings.Replace(valid, `"promoted": false`, `"promoted": "no"`, 1):      "promoted: should be boolean",
+		strings.Replace(valid, `"club": "Arsenal"`, `"club": null`, 1):          "club: must not be null",
+		strings.Replace(valid, `["red", "white"]`, `["red", 3]`, 1):             "colours[1]: should be string",
+		strings.Replace(valid, `{"capacity": 60704}`, `{"capacity": "big"}`, 1): "stadium.capacity: should be number",
+		`[]`: "top-level JSON is not an object",
+	}
+	for doc, want := range cases {
+		err := Validate(decode(t, doc), clubSchema)
+		var verr *ValidationError
+		if !errors.As(err, &verr) || err.Error() != want {
+			t.Fatalf("%s: expected %q, got %v", doc, want, err)
+		}
+	}
+}
+
+func TestJSONSchemaRoundTrip(t *testing.T) {
+	s, err := JSONSchema(clubSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(s, `{"type":"object","properties":{"club":{"type":"string","description":"club name"},"founded":`) {
+		t.Fatalf("expected ordered properties, got %s", s)
+	}
+	if !strings.Contains(s, `"manager":{"type":["string","null"]}`) || !strings.Contains(s, `"required":["club","promoted","league","manager","players","colours","stadium"]`) {
+		t.Fatalf("unexpected schema %s", s)
+	}
+	back, err := FromJSONSchema(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, clubSchema) {
+		t.Fatalf("round trip changed the fields:\n%+v\n%+v", back, clubSchema)
+	}
+
+	// OpenAPI nullable, enum with null and properties without a type
+	fields, err := FromJSONSchema(`{"properties": {"grade": {"type": "string", "enum": ["A", "B", null]}, "note": {"type": "string", "nullable": true}}, "required": ["grade"]}`)
+	if err != nil || len(fields) != 2 || !fields[0].Nullable || len(fields[0].Enum) != 2 || fields[0].Optional || !fields[1].Nullable || !fields[1].Optional {
+		t.Fatalf("unexpected fields %+v %v", fields, err)
+	}
+	for _, bad := range []string{`{"type": "array"}`, `{"properties": {"x": {"type": ["string", "number"]}}}`, `not json`} {
+		if _, err := FromJSONSchema(bad); err == nil {
+			t.Fatalf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestFromShorthand(t *testing.T) {
+	fields, err := FromShorthand(map[string]string{
+		"name":         "text",
+		"age":          "int=18",
+		"address.city": "string=Bangkok",
+		"address.zip":  "string",
+		"active":       "bool=true",
+	}, []string{"name", "address.city"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Field{
+		{Name: "active", Type: Boolean, Default: true, Optional: true},
+		{Name: "address", Type: Object, Optional: true, Fields: []Field{
+			{Name: "city", Type: String, Default: "Bangkok"},
+			{Name: "zip", Type: String, Optional: true},
+		}},
+		{Name: "age", Type: Integer, Default: 18, Optional: true},
+		{Name: "name", Type: String},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Fatalf("unexpected fields\n%+v\n%+v", fields, want)
+	}
+	if _, err := FromShorthand(map[string]string{"age": "int=old"}, nil); err == nil {
+		t.Fatalf("expected bad default error")
+	}
+}
+
+func TestPromptAndExample(t *testing.T) {
+	p := BuildPrompt("premier league", clubSchema, []string{"Use concise language"})
+	for _, want := range []string{
+		"Please explain \"premier league\" in JSON format with the following structure:\n{\n",
+		`"club": "club name (string)",`,
+		`"founded": "(integer, optional)",`,
+		`"league": "(string, one of \"premier\", \"championship\")",`,
+		`"manager": "(string, may be null)",`,
+		"\"players\": [\n    {\n      \"name\": \"player name (string)\",\n",
+		`"colours": ["kit colour (string)"],`,
+		`"honours": ["trophy won"] /* optional */,`,
+		"- Use concise language\nEnsure valid JSON only, no extra text.",
+	} {
+		if !strings.Contains(p, want) {
+			t.Fatalf("prompt lacks %q:\n%s", want, p)
+		}
+	}
+	ex := decode(t, ExampleJSON(clubSchema))
+	if err := Validate(ex, clubSchema); err != nil {
+		t.Fatalf("example must validate, got %v: %s", err, ExampleJSON(clubSchema))
+	}
+}
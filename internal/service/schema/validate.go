@@ -0,0 +1,157 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ValidationError reports the first value that does not match the fields.
+type ValidationError struct {
+	Path    string // e.g. players[2].name; empty for the whole document
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Validate checks a decoded JSON document (as from json.Unmarshal into an
+// any) against fields. It returns a *ValidationError for the first problem.
+func Validate(data any, fields []Field) error {
+	m, ok := data.(map[string]any)
+	if !ok {
+		return &ValidationError{Message: "top-level JSON is not an object"}
+	}
+	return validateObject(m, fields, "")
+}
+
+func validateObject(m map[string]any, fields []Field, path string) error {
+	for _, f := range fields {
+		p := f.Name
+		if path != "" {
+			p = path + "." + f.Name
+		}
+		val, exists := m[f.Name]
+		if !exists {
+			if f.Optional {
+				continue
+			}
+			return &ValidationError{Path: p, Message: "missing field"}
+		}
+		if err := validateValue(val, f, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateValue(val any, f Field, path string) error {
+	if val == nil {
+		if f.Nullable || f.Type == "" {
+			return nil
+		}
+		return &ValidationError{Path: path, Message: "must not be null"}
+	}
+	fail := func(format string, args ...any) error {
+		return &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)}
+	}
+	switch f.Type {
+	case String:
+		if _, ok := val.(string); !ok {
+			return fail("should be string")
+		}
+	case Number:
+		if _, ok := number(val); !ok {
+			return fail("should be number")
+		}
+	case Integer:
+		if n, ok := number(val); !ok || n != math.Trunc(n) {
+			return fail("should be integer")
+		}
+	case Boolean:
+		if _, ok := val.(bool); !ok {
+			return fail("should be boolean")
+		}
+	case Object:
+		m, ok := val.(map[string]any)
+		if !ok {
+			return fail("should be object")
+		}
+		if err := validateObject(m, f.Fields, path); err != nil {
+			return err
+		}
+	case Array:
+		arr, ok := val.([]any)
+		if !ok {
+			return fail("should be array")
+		}
+		for i, item := range arr {
+			ip := path + "[" + strconv.Itoa(i) + "]"
+			switch {
+			case f.Items != nil:
+				if err := validateValue(item, *f.Items, ip); err != nil {
+					return err
+				}
+			case len(f.Fields) > 0:
+				if err := validateValue(item, Field{Type: Object, Fields: f.Fields}, ip); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if len(f.Enum) > 0 && !inEnum(val, f.Enum) {
+		return fail("must be one of %s", enumList(f.Enum))
+	}
+	return nil
+}
+
+// number accepts the float64 of json.Unmarshal as well as json.Number and Go
+// numbers from hand-built documents.
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// inEnum compares values by their JSON encoding, so 1 matches 1.0.
+func inEnum(v any, enum []any) bool {
+	if n, ok := number(v); ok {
+		v = n
+	}
+	want, _ := json.Marshal(v)
+	for _, e := range enum {
+		if n, ok := number(e); ok {
+			e = n
+		}
+		if got, _ := json.Marshal(e); string(got) == string(want) {
+			return true
+		}
+	}
+	return false
+}
+
+func enumList(enum []any) string {
+	parts := make([]string, len(enum))
+	for i, e := range enum {
+		b, _ := json.Marshal(e)
+		parts[i] = string(b)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -1 +1,59 @@
 package speaking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go-azure-openai/internal/service/agent"
+)
+
+// AnswerFromRecording transcribes a student's speaking recording into answer text,
+// so it can be placed in the ===ANSWER=== section of an evaluation template and
+// graded the same way as a written answer. Language is an ISO-639-1 hint and may be empty.
+func AnswerFromRecording(ctx context.Context, a *agent.Agent, path, language string) (string, error) {
+	opts := []agent.AudioOption{agent.WithAudioFormat(agent.AudioFormatText)}
+	if language != "" {
+		opts = append(opts, agent.WithLanguage(language))
+	}
+	t, err := a.Transcribe(ctx, path, opts...)
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSpace(t.Text)
+	if text == "" {
+		return "", errors.New("recording produced an empty transcript")
+	}
+	return text, nil
+}
+
+// PrerenderQuestions renders each examiner question to an audio file in dir
+// (q01.mp3, q02.mp3, ...) and returns the paths in question order. Set
+// Config.SpeechCacheDir on the agent to avoid re-rendering unchanged questions.
+func PrerenderQuestions(ctx context.Context, a *agent.Agent, questions []string, dir string, format agent.SpeechFormat, opts ...agent.SpeechOption) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	opts = append(opts, agent.WithSpeechFormat(format))
+	paths := make([]string, 0, len(questions))
+	for i, q := range questions {
+		path := filepath.Join(dir, fmt.Sprintf("q%02d.%s", i+1, format))
+		f, err := os.Create(path)
+		if err != nil {
+			return paths, err
+		}
+		_, err = a.Speak(ctx, q, f, opts...)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+			return paths, fmt.Errorf("question %d: %w", i+1, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
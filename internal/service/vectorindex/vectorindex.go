@@ -0,0 +1,237 @@
+// Package vectorindex is a small in-process vector index with cosine similarity,
+// metadata filters and JSON persistence. It is meant for a few thousand records
+// (past answers, exemplars), where a brute-force scan is fast enough and no
+// separate vector database is needed.
+package vectorindex
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Record is a single stored vector with optional text and metadata.
+type Record struct {
+	ID       string            `json:"id"`
+	Vector   []float32         `json:"vector"`
+	Text     string            `json:"text,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Match is a search hit with its cosine similarity to the query (-1..1).
+type Match struct {
+	Record
+	Score float64 `json:"score"`
+}
+
+// Filter restricts a search to records whose metadata contains every key/value pair.
+// A nil or empty Filter matches all records.
+type Filter map[string]string
+
+func (f Filter) match(meta map[string]string) bool {
+	for k, v := range f {
+		if meta[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Index stores records in memory. It is safe for concurrent use.
+type Index struct {
+	mu      sync.RWMutex
+	dim     int
+	records []Record
+	norms   []float64
+	pos     map[string]int
+}
+
+// New returns an empty index. The dimension is fixed by the first record added.
+func New() *Index {
+	return &Index{pos: map[string]int{}}
+}
+
+// Len returns the number of records.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.records)
+}
+
+// Dim returns the vector dimension, or 0 if the index is empty.
+func (ix *Index) Dim() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.dim
+}
+
+// Add inserts a record, replacing any existing record with the same ID.
+func (ix *Index) Add(r Record) error {
+	if r.ID == "" {
+		return errors.New("record id is required")
+	}
+	if len(r.Vector) == 0 {
+		return fmt.Errorf("record %q has an empty vector", r.ID)
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.dim == 0 || len(ix.records) == 0 {
+		ix.dim = len(r.Vector)
+	}
+	if len(r.Vector) != ix.dim {
+		return fmt.Errorf("record %q has dimension %d, index has %d", r.ID, len(r.Vector), ix.dim)
+	}
+	n := norm(r.Vector)
+	if i, ok := ix.pos[r.ID]; ok {
+		ix.records[i] = r
+		ix.norms[i] = n
+		return nil
+	}
+	ix.pos[r.ID] = len(ix.records)
+	ix.records = append(ix.records, r)
+	ix.norms = append(ix.norms, n)
+	return nil
+}
+
+// Get returns the record with the given ID.
+func (ix *Index) Get(id string) (Record, bool) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	i, ok := ix.pos[id]
+	if !ok {
+		return Record{}, false
+	}
+	return ix.records[i], true
+}
+
+// Delete removes the record with the given ID and reports whether it existed.
+func (ix *Index) Delete(id string) bool {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	i, ok := ix.pos[id]
+	if !ok {
+		return false
+	}
+	last := len(ix.records) - 1
+	if i != last {
+		ix.records[i] = ix.records[last]
+		ix.norms[i] = ix.norms[last]
+		ix.pos[ix.records[i].ID] = i
+	}
+	ix.records = ix.records[:last]
+	ix.norms = ix.norms[:last]
+	delete(ix.pos, id)
+	return true
+}
+
+// Search returns up to k records most similar to query, best first.
+// Records are scanned exhaustively; filter narrows the candidates by metadata.
+func (ix *Index) Search(query []float32, k int, filter Filter) ([]Match, error) {
+	if k <= 0 {
+		return nil, nil
+	}
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	if len(ix.records) == 0 {
+		return nil, nil
+	}
+	if len(query) != ix.dim {
+		return nil, fmt.Errorf("query has dimension %d, index has %d", len(query), ix.dim)
+	}
+	qn := norm(query)
+	matches := make([]Match, 0, k)
+	for i, r := range ix.records {
+		if !filter.match(r.Metadata) {
+			continue
+		}
+		matches = append(matches, Match{Record: r, Score: cosine(query, r.Vector, qn, ix.norms[i])})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches, nil
+}
+
+// Cosine returns the cosine similarity of a and b, or 0 if either is a zero
+// vector or the lengths differ.
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	return cosine(a, b, norm(a), norm(b))
+}
+
+func cosine(a, b []float32, na, nb float64) float64 {
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot / (na * nb)
+}
+
+func norm(v []float32) float64 {
+	var s float64
+	for _, x := range v {
+		s += float64(x) * float64(x)
+	}
+	return math.Sqrt(s)
+}
+
+// fileFormat is the on-disk JSON layout.
+type fileFormat struct {
+	Dim     int      `json:"dim"`
+	Records []Record `json:"records"`
+}
+
+// Save writes the index to path as JSON. The file is written to a temporary
+// file first and renamed, so a crash never leaves a truncated index behind.
+func (ix *Index) Save(path string) error {
+	ix.mu.RLock()
+	b, err := json.Marshal(fileFormat{Dim: ix.dim, Records: ix.records})
+	ix.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load reads an index previously written by Save.
+func Load(path string) (*Index, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f fileFormat
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("decode index %s: %w", path, err)
+	}
+	ix := New()
+	for _, r := range f.Records {
+		if err := ix.Add(r); err != nil {
+			return nil, fmt.Errorf("load index %s: %w", path, err)
+		}
+	}
+	return ix, nil
+}
@@ -0,0 +1,67 @@
+package vectorindex
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSearchOrderAndFilter(t *testing.T) {
+	ix := New()
+	recs := []Record{
+		{ID: "a", Vector: []float32{1, 0}, Metadata: map[string]string{"task": "email"}},
+		{ID: "b", Vector: []float32{0.9, 0.1}, Metadata: map[string]string{"task": "story"}},
+		{ID: "c", Vector: []float32{0, 1}, Metadata: map[string]string{"task": "email"}},
+	}
+	for _, r := range recs {
+		if err := ix.Add(r); err != nil {
+			t.Fatalf("add %s: %v", r.ID, err)
+		}
+	}
+	got, err := ix.Search([]float32{1, 0}, 2, nil)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(got) != 2 || got[0].ID != "a" || got[1].ID != "b" {
+		t.Fatalf("unexpected order: %+v", got)
+	}
+	got, _ = ix.Search([]float32{1, 0}, 5, Filter{"task": "email"})
+	if len(got) != 2 || got[0].ID != "a" || got[1].ID != "c" {
+		t.Fatalf("filter not applied: %+v", got)
+	}
+	if err := ix.Add(Record{ID: "d", Vector: []float32{1, 2, 3}}); err == nil {
+		t.Fatalf("expected dimension mismatch error")
+	}
+}
+
+func TestDeleteAndPersist(t *testing.T) {
+	ix := New()
+	ix.Add(Record{ID: "a", Vector: []float32{1, 0}, Text: "first"})
+	ix.Add(Record{ID: "b", Vector: []float32{0, 1}, Text: "second"})
+	if !ix.Delete("a") || ix.Delete("a") {
+		t.Fatalf("unexpected delete result")
+	}
+	path := filepath.Join(t.TempDir(), "index.json")
+	if err := ix.Save(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if loaded.Len() != 1 {
+		t.Fatalf("expected 1 record, got %d", loaded.Len())
+	}
+	r, ok := loaded.Get("b")
+	if !ok || r.Text != "second" {
+		t.Fatalf("record not restored: %+v", r)
+	}
+}
+
+func TestCosine(t *testing.T) {
+	if s := Cosine([]float32{1, 1}, []float32{2, 2}); s < 0.999 {
+		t.Fatalf("expected ~1, got %v", s)
+	}
+	if s := Cosine([]float32{0, 0}, []float32{1, 0}); s != 0 {
+		t.Fatalf("expected 0 for zero vector, got %v", s)
+	}
+}
@@ -0,0 +1,98 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FileCheckpoint stores each run's step outputs as JSON in dir/<runID>.json.
+type FileCheckpoint struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileCheckpoint returns a checkpointer writing to dir, creating it if needed.
+func NewFileCheckpoint(dir string) (*FileCheckpoint, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileCheckpoint{dir: dir}, nil
+}
+
+func (c *FileCheckpoint) path(runID string) (string, error) {
+	if runID == "" || strings.ContainsAny(runID, `/\`) || runID == "." || runID == ".." {
+		return "", fmt.Errorf("invalid run id %q", runID)
+	}
+	return filepath.Join(c.dir, runID+".json"), nil
+}
+
+// Load returns the outputs saved for runID; an unknown run has none.
+func (c *FileCheckpoint) Load(runID string) (map[string]Saved, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.load(runID)
+}
+
+func (c *FileCheckpoint) load(runID string) (map[string]Saved, error) {
+	p, err := c.path(runID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]Saved{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	outs := map[string]Saved{}
+	return outs, json.Unmarshal(data, &outs)
+}
+
+// Save records the output of stepID, replacing the file atomically.
+func (c *FileCheckpoint) Save(runID, stepID string, s Saved) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	outs, err := c.load(runID)
+	if err != nil {
+		return err
+	}
+	outs[stepID] = s
+	data, err := json.MarshalIndent(outs, "", "  ")
+	if err != nil {
+		return err
+	}
+	p, _ := c.path(runID)
+	tmp, err := os.CreateTemp(c.dir, runID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// Clear removes the checkpoint of a finished run.
+func (c *FileCheckpoint) Clear(runID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, err := c.path(runID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,444 @@
+// Package workflow runs pipelines of LLM calls and Go functions declared as a
+// DAG, in Go or YAML: transcribe, clean up, grade each criterion, aggregate,
+// write feedback. Independent steps run in parallel, steps can fan out over a
+// list, failed attempts are retried with a per-attempt timeout, and each
+// finished step is checkpointed so a failed run resumes where it stopped.
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"go-azure-openai/internal/service/agent"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Built-in step kinds for Step.Uses.
+const (
+	UsesChat       = "chat"       // send Prompt (and System) to the agent
+	UsesTranscribe = "transcribe" // transcribe the audio file at path Prompt
+)
+
+// Step is one node of a workflow.
+//
+// Prompt and ForEach are text/template strings evaluated against Input, e.g.
+// "Grade {{.Item}} for: {{.Steps.clean}}" or "{{.Inputs.audio}}".
+type Step struct {
+	ID    string   `yaml:"id"`
+	Needs []string `yaml:"needs,omitempty"`
+	// Uses is UsesChat, UsesTranscribe or the name of a function registered in
+	// Runner.Funcs. Empty means Run if set, otherwise chat.
+	Uses        string   `yaml:"uses,omitempty"`
+	Prompt      string   `yaml:"prompt,omitempty"`
+	System      string   `yaml:"system,omitempty"`
+	Temperature *float32 `yaml:"temperature,omitempty"` // nil keeps the agent's default
+	// ForEach fans the step out over a list: the template must render a JSON
+	// array of strings or one item per line. The step output is then a JSON
+	// array with one result per item, in order.
+	ForEach string        `yaml:"for_each,omitempty"`
+	Retries int           `yaml:"retries,omitempty"` // extra attempts after a failure
+	Timeout time.Duration `yaml:"timeout,omitempty"` // per attempt; 0 means none
+	// Run implements the step in Go; it is used when Uses is empty.
+	Run StepFunc `yaml:"-"`
+}
+
+// Input is what a step's templates and function see.
+type Input struct {
+	Inputs map[string]string // run inputs
+	Steps  map[string]string // outputs of the steps in Needs, by ID
+	Item   string            // current item when fanning out
+	Index  int               // index of Item
+	Prompt string            // the rendered Prompt
+}
+
+// StepFunc runs a step implemented in Go and returns its output.
+type StepFunc func(ctx context.Context, in Input) (string, error)
+
+// Workflow is a named set of steps.
+type Workflow struct {
+	Name  string `yaml:"name"`
+	Steps []Step `yaml:"steps"`
+}
+
+// ParseYAML reads a workflow definition and validates it.
+func ParseYAML(data []byte) (*Workflow, error) {
+	var w Workflow
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&w); err != nil {
+		return nil, fmt.Errorf("parse workflow: %w", err)
+	}
+	return &w, w.Validate()
+}
+
+// LoadFile reads a YAML workflow from path.
+func LoadFile(path string) (*Workflow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseYAML(data)
+}
+
+// Validate checks that step IDs are unique, dependencies exist and the graph
+// has no cycles.
+func (w *Workflow) Validate() error {
+	if len(w.Steps) == 0 {
+		return errors.New("workflow has no steps")
+	}
+	byID := make(map[string]*Step, len(w.Steps))
+	for i := range w.Steps {
+		s := &w.Steps[i]
+		if s.ID == "" {
+			return fmt.Errorf("step %d has no id", i+1)
+		}
+		if _, dup := byID[s.ID]; dup {
+			return fmt.Errorf("duplicate step id %q", s.ID)
+		}
+		byID[s.ID] = s
+	}
+	for _, s := range w.Steps {
+		for _, d := range s.Needs {
+			if _, ok := byID[d]; !ok {
+				return fmt.Errorf("step %q needs unknown step %q", s.ID, d)
+			}
+		}
+	}
+	// Depth-first search for cycles.
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	var visit func(id string, path []string) error
+	visit = func(id string, path []string) error {
+		switch state[id] {
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, id), " -> "))
+		case done:
+			return nil
+		}
+		state[id] = visiting
+		for _, d := range byID[id].Needs {
+			if err := visit(d, append(path, id)); err != nil {
+				return err
+			}
+		}
+		state[id] = done
+		return nil
+	}
+	for _, s := range w.Steps {
+		if err := visit(s.ID, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Saved is a checkpointed step output with the hash of the step definition
+// that produced it.
+type Saved struct {
+	Output string `json:"output"`
+	Hash   string `json:"hash"`
+}
+
+// Checkpointer stores finished step outputs per run.
+type Checkpointer interface {
+	Load(runID string) (map[string]Saved, error)
+	Save(runID, stepID string, s Saved) error
+}
+
+// Runner executes workflows.
+type Runner struct {
+	Agent       *agent.Agent        // for chat and transcribe steps
+	Funcs       map[string]StepFunc // steps referenced by name from YAML
+	Checkpoint  Checkpointer        // optional; enables resume
+	Concurrency int                 // max calls in flight, including fan-out items (default 4)
+}
+
+// Result is the outcome of a run.
+type Result struct {
+	Outputs map[string]string `json:"outputs"`
+	Resumed []string          `json:"resumed,omitempty"` // steps restored from the checkpoint
+}
+
+// StepError reports which step failed.
+type StepError struct {
+	Step     string
+	Attempts int
+	Err      error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("step %q failed after %d attempt(s): %v", e.Step, e.Attempts, e.Err)
+}
+
+func (e *StepError) Unwrap() error { return e.Err }
+
+// Run executes w with inputs. With a Checkpointer, steps already finished
+// under runID are not run again, unless the step or one it needs has changed
+// since. The first failing step cancels the others and its error is returned
+// as a *StepError.
+func (r *Runner) Run(ctx context.Context, w *Workflow, runID string, inputs map[string]string) (Result, error) {
+	res := Result{Outputs: map[string]string{}}
+	if err := w.Validate(); err != nil {
+		return res, err
+	}
+	hashes := w.hashes()
+	if r.Checkpoint != nil {
+		saved, err := r.Checkpoint.Load(runID)
+		if err != nil {
+			return res, fmt.Errorf("load checkpoint: %w", err)
+		}
+		for _, s := range w.Steps {
+			if sv, ok := saved[s.ID]; ok && sv.Hash == hashes[s.ID] {
+				res.Outputs[s.ID] = sv.Output
+				res.Resumed = append(res.Resumed, s.ID)
+			}
+		}
+	}
+	limit := r.Concurrency
+	if limit <= 0 {
+		limit = 4
+	}
+	sem := make(chan struct{}, limit)
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	var mu sync.Mutex // guards res.Outputs
+	done := make(map[string]chan struct{}, len(w.Steps))
+	for _, s := range w.Steps {
+		done[s.ID] = make(chan struct{})
+	}
+	var wg sync.WaitGroup
+	for i := range w.Steps {
+		s := &w.Steps[i]
+		mu.Lock()
+		_, finished := res.Outputs[s.ID]
+		mu.Unlock()
+		if finished {
+			close(done[s.ID])
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, d := range s.Needs {
+				select {
+				case <-done[d]:
+				case <-ctx.Done():
+					return
+				}
+			}
+			mu.Lock()
+			in := Input{Inputs: inputs, Steps: make(map[string]string, len(s.Needs))}
+			for _, d := range s.Needs {
+				in.Steps[d] = res.Outputs[d]
+			}
+			mu.Unlock()
+
+			out, err := r.runStep(ctx, s, in, sem)
+			if err == nil && r.Checkpoint != nil {
+				if cerr := r.Checkpoint.Save(runID, s.ID, Saved{Output: out, Hash: hashes[s.ID]}); cerr != nil {
+					err = &StepError{Step: s.ID, Attempts: 1, Err: fmt.Errorf("save checkpoint: %w", cerr)}
+				}
+			}
+			if err != nil {
+				cancel(err)
+				return
+			}
+			mu.Lock()
+			res.Outputs[s.ID] = out
+			mu.Unlock()
+			close(done[s.ID])
+		}()
+	}
+	wg.Wait()
+	if err := context.Cause(ctx); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// hashes returns a hash of each step's definition, including the hashes of
+// the steps it needs, so a change invalidates everything downstream of it.
+// A Go Run func cannot be hashed; change the step ID when its code changes.
+// w must be valid.
+func (w *Workflow) hashes() map[string]string {
+	byID := make(map[string]*Step, len(w.Steps))
+	for i := range w.Steps {
+		byID[w.Steps[i].ID] = &w.Steps[i]
+	}
+	hashes := make(map[string]string, len(w.Steps))
+	var hash func(id string) string
+	hash = func(id string) string {
+		if h, ok := hashes[id]; ok {
+			return h
+		}
+		s := byID[id]
+		needs := make([]string, len(s.Needs))
+		for i, d := range s.Needs {
+			needs[i] = d + "=" + hash(d)
+		}
+		def, _ := json.Marshal(struct {
+			ID, Uses, Prompt, System, ForEach string
+			Temperature                       *float32
+			Needs                             []string
+		}{s.ID, s.Uses, s.Prompt, s.System, s.ForEach, s.Temperature, needs})
+		sum := sha256.Sum256(def)
+		hashes[id] = hex.EncodeToString(sum[:])
+		return hashes[id]
+	}
+	for _, s := range w.Steps {
+		hash(s.ID)
+	}
+	return hashes
+}
+
+// runStep runs s once, or once per item when it fans out.
+func (r *Runner) runStep(ctx context.Context, s *Step, in Input, sem chan struct{}) (string, error) {
+	if s.ForEach == "" {
+		return r.attempt(ctx, s, in, sem)
+	}
+	list, err := render(s.ID+".for_each", s.ForEach, in)
+	if err != nil {
+		return "", &StepError{Step: s.ID, Err: err}
+	}
+	items := splitItems(list)
+	outs := make([]string, len(items))
+	errs := make([]error, len(items))
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			itemIn := in
+			itemIn.Item, itemIn.Index = item, i
+			outs[i], errs[i] = r.attempt(ctx, s, itemIn, sem)
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(outs)
+	return string(b), err
+}
+
+// attempt runs s with retries, each attempt holding a concurrency slot and
+// bounded by s.Timeout.
+func (r *Runner) attempt(ctx context.Context, s *Step, in Input, sem chan struct{}) (string, error) {
+	var err error
+	attempts := 0
+	for attempts <= s.Retries {
+		if attempts > 0 {
+			backoff := time.Duration(100<<min(attempts-1, 6)) * time.Millisecond
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return "", &StepError{Step: s.ID, Attempts: attempts, Err: err}
+			}
+		}
+		attempts++
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return "", &StepError{Step: s.ID, Attempts: attempts, Err: context.Cause(ctx)}
+		}
+		var out string
+		out, err = r.call(ctx, s, in)
+		<-sem
+		if err == nil {
+			return out, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return "", &StepError{Step: s.ID, Attempts: attempts, Err: err}
+}
+
+// call performs one attempt of s.
+func (r *Runner) call(ctx context.Context, s *Step, in Input) (string, error) {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	var err error
+	if in.Prompt, err = render(s.ID+".prompt", s.Prompt, in); err != nil {
+		return "", err
+	}
+	uses := s.Uses
+	if uses == "" && s.Run != nil {
+		return s.Run(ctx, in)
+	}
+	switch uses {
+	case "", UsesChat:
+		if r.Agent == nil {
+			return "", errors.New("chat step needs Runner.Agent")
+		}
+		var opts []agent.ChatOption
+		if s.System != "" {
+			opts = append(opts, agent.WithSystem(s.System))
+		}
+		if s.Temperature != nil {
+			opts = append(opts, agent.WithTemperature(*s.Temperature))
+		}
+		res, err := r.Agent.ChatStructured(ctx, in.Prompt, opts...)
+		return res.Text, err
+	case UsesTranscribe:
+		if r.Agent == nil {
+			return "", errors.New("transcribe step needs Runner.Agent")
+		}
+		t, err := r.Agent.Transcribe(ctx, strings.TrimSpace(in.Prompt))
+		return t.Text, err
+	default:
+		fn, ok := r.Funcs[uses]
+		if !ok {
+			return "", fmt.Errorf("unknown step function %q", uses)
+		}
+		return fn(ctx, in)
+	}
+}
+
+func render(name, text string, in Input) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, in); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// splitItems reads a JSON array of strings, or one item per non-empty line.
+func splitItems(s string) []string {
+	var items []string
+	if err := json.Unmarshal([]byte(strings.TrimSpace(s)), &items); err == nil {
+		return items
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			items = append(items, line)
+		}
+	}
+	return items
+}
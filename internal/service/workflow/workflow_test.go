@@ -0,0 +1,213 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-azure-openai/internal/service/agent"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const gradingYAML = `
+name: grade
+steps:
+  - id: clean
+    uses: trim
+    prompt: "{{.Inputs.answer}}"
+  - id: criteria
+    uses: echo
+    prompt: '["content","language"]'
+  - id: grade
+    needs: [clean, criteria]
+    uses: score
+    for_each: "{{.Steps.criteria}}"
+    prompt: "{{.Item}}: {{.Steps.clean}}"
+    retries: 1
+    timeout: 1s
+  - id: feedback
+    needs: [grade]
+    uses: echo
+    prompt: "scores={{.Steps.grade}}"
+`
+
+func TestParseYAML_Validate(t *testing.T) {
+	w, err := ParseYAML([]byte(gradingYAML))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(w.Steps) != 4 || w.Steps[2].Timeout != time.Second || w.Steps[2].Retries != 1 {
+		t.Fatalf("unexpected workflow: %+v", w)
+	}
+	for _, bad := range []string{
+		"steps: [{id: a, needs: [b]}]",
+		"steps: [{id: a, needs: [b]}, {id: b, needs: [a]}]",
+		"steps: [{id: a}, {id: a}]",
+		"steps: [{id: a, unknown: 1}]",
+	} {
+		if _, err := ParseYAML([]byte(bad)); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func testFuncs(scoreCalls *atomic.Int32, failOnce *atomic.Bool) map[string]StepFunc {
+	return map[string]StepFunc{
+		"trim": func(_ context.Context, in Input) (string, error) { return strings.TrimSpace(in.Prompt), nil },
+		"echo": func(_ context.Context, in Input) (string, error) { return in.Prompt, nil },
+		"score": func(_ context.Context, in Input) (string, error) {
+			scoreCalls.Add(1)
+			if in.Item == "language" && failOnce.CompareAndSwap(true, false) {
+				return "", errors.New("transient")
+			}
+			return strings.ToUpper(in.Prompt), nil
+		},
+	}
+}
+
+func TestRunner_FanOutRetryAndFanIn(t *testing.T) {
+	w, _ := ParseYAML([]byte(gradingYAML))
+	var calls atomic.Int32
+	var failOnce atomic.Bool
+	failOnce.Store(true)
+	r := Runner{Funcs: testFuncs(&calls, &failOnce)}
+
+	res, err := r.Run(context.Background(), w, "run1", map[string]string{"answer": "  hello  "})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := res.Outputs["grade"]; got != `["CONTENT: HELLO","LANGUAGE: HELLO"]` {
+		t.Fatalf("unexpected fan-out output: %s", got)
+	}
+	if got := res.Outputs["feedback"]; !strings.HasPrefix(got, "scores=[") {
+		t.Fatalf("fan-in not passed downstream: %s", got)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("expected one retry, got %d score calls", calls.Load())
+	}
+}
+
+func TestRunner_ParallelSteps(t *testing.T) {
+	var running, peak atomic.Int32
+	slow := func(ctx context.Context, in Input) (string, error) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		running.Add(-1)
+		return "ok", nil
+	}
+	w := &Workflow{Steps: []Step{{ID: "a", Run: slow}, {ID: "b", Run: slow}, {ID: "c", Run: slow, Needs: []string{"a", "b"}}}}
+	if _, err := (&Runner{}).Run(context.Background(), w, "p", nil); err != nil {
+		t.Fatal(err)
+	}
+	if peak.Load() != 2 {
+		t.Fatalf("expected independent steps to overlap, peak=%d", peak.Load())
+	}
+}
+
+func TestRunner_ResumeFromCheckpoint(t *testing.T) {
+	cp, err := NewFileCheckpoint(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	ran := map[string]int{}
+	step := func(id string, fail *bool) StepFunc {
+		return func(context.Context, Input) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			ran[id]++
+			if fail != nil && *fail {
+				return "", errors.New("backend down")
+			}
+			return id + "-out", nil
+		}
+	}
+	fail := true
+	w := &Workflow{Steps: []Step{
+		{ID: "transcribe", Run: step("transcribe", nil)},
+		{ID: "grade", Needs: []string{"transcribe"}, Run: step("grade", &fail)},
+	}}
+	r := Runner{Checkpoint: cp}
+	_, err = r.Run(context.Background(), w, "run-42", nil)
+	var se *StepError
+	if !errors.As(err, &se) || se.Step != "grade" {
+		t.Fatalf("expected StepError for grade, got %v", err)
+	}
+
+	fail = false
+	res, err := r.Run(context.Background(), w, "run-42", nil)
+	if err != nil {
+		t.Fatalf("expected resume to succeed, got %v", err)
+	}
+	if ran["transcribe"] != 1 || len(res.Resumed) != 1 || res.Outputs["grade"] != "grade-out" {
+		t.Fatalf("expected transcribe to be restored, ran=%v res=%+v", ran, res)
+	}
+
+	// a changed step is run again, and so is every step that needs it
+	w.Steps[0].Prompt = "{{.Inputs.audio}}"
+	res, err = r.Run(context.Background(), w, "run-42", map[string]string{"audio": "a.wav"})
+	if err != nil || ran["transcribe"] != 2 || ran["grade"] != 3 || len(res.Resumed) != 0 {
+		t.Fatalf("expected changed steps to rerun, ran=%v res=%+v err=%v", ran, res, err)
+	}
+}
+
+// chatClient answers every chat call with "ok" and keeps the requests.
+type chatClient struct {
+	mu   sync.Mutex
+	reqs []openai.ChatCompletionRequest
+}
+
+func (c *chatClient) CreateChatCompletion(_ context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reqs = append(c.reqs, req)
+	return openai.ChatCompletionResponse{Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Content: "ok"}}}}, nil
+}
+
+func (c *chatClient) CreateChatCompletionStream(context.Context, openai.ChatCompletionRequest) (*openai.ChatCompletionStream, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestRunner_ZeroTemperatureIsKept(t *testing.T) {
+	w, err := ParseYAML([]byte(`
+steps:
+  - id: strict
+    prompt: grade
+    temperature: 0
+  - id: default
+    prompt: grade
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &chatClient{}
+	a, err := agent.NewWithClient(agent.Config{Key: "k", Endpoint: "https://x.openai.azure.com", Model: "gpt-test"}, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := Runner{Agent: a, Concurrency: 1}
+	if _, err := r.Run(context.Background(), w, "run-1", nil); err != nil {
+		t.Fatal(err)
+	}
+	temps := map[float32]bool{}
+	for _, req := range c.reqs {
+		temps[req.Temperature] = true
+	}
+	if !temps[0.7] || temps[0] || len(temps) != 2 {
+		t.Fatalf("expected a deterministic and a default temperature, got %v", temps)
+	}
+	if h := w.hashes(); h["strict"] == (&Workflow{Steps: []Step{{ID: "strict", Prompt: "grade"}}}).hashes()["strict"] {
+		t.Fatalf("temperature not part of the step hash")
+	}
+}
@@ -0,0 +1,71 @@
+// Package telemetry configures OpenTelemetry tracing and metrics export for the service.
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// Options configures Setup. Leave the exporter fields nil to export over OTLP/HTTP,
+// which reads the standard OTEL_EXPORTER_OTLP_* environment variables.
+type Options struct {
+	ServiceName string
+
+	// SpanExporter overrides the OTLP trace exporter, e.g. with tracetest.NewInMemoryExporter().
+	SpanExporter sdktrace.SpanExporter
+	// MetricReader overrides the periodic OTLP metric reader, e.g. with sdkmetric.NewManualReader().
+	MetricReader sdkmetric.Reader
+	// MetricInterval is the OTLP export interval (default 30s).
+	MetricInterval time.Duration
+}
+
+// Setup installs global tracer and meter providers and the W3C trace-context
+// propagator. Call the returned function on shutdown to flush pending data.
+func Setup(ctx context.Context, opts Options) (shutdown func(context.Context) error, err error) {
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(opts.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	spanExp := opts.SpanExporter
+	if spanExp == nil {
+		spanExp, err = otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(spanExp), sdktrace.WithResource(res))
+
+	reader := opts.MetricReader
+	if reader == nil {
+		metricExp, err := otlpmetrichttp.New(ctx)
+		if err != nil {
+			tp.Shutdown(ctx)
+			return nil, err
+		}
+		interval := opts.MetricInterval
+		if interval <= 0 {
+			interval = 30 * time.Second
+		}
+		reader = sdkmetric.NewPeriodicReader(metricExp, sdkmetric.WithInterval(interval))
+	}
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithResource(res))
+
+	otel.SetTracerProvider(tp)
+	otel.SetMeterProvider(mp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return func(ctx context.Context) error {
+		return errors.Join(tp.Shutdown(ctx), mp.Shutdown(ctx))
+	}, nil
+}
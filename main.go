@@ -3,12 +3,13 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"os"
 	"time"
 
+	"go-azure-openai/internal/service/schema"
+
 	"github.com/joho/godotenv"
 	"github.com/sashabaranov/go-openai"
 )
@@ -22,13 +23,8 @@ type OpenAIConfig struct {
 	Timeout        time.Duration
 }
 
-// FieldSchema รองรับ nested JSON
-type FieldSchema struct {
-	Name        string
-	Type        string // string, number, array, object
-	Description string
-	Fields      []FieldSchema // สำหรับ type == "object" หรือ array of objects
-}
+// FieldSchema รองรับ nested JSON (ย้ายไปอยู่ที่ package schema แล้ว)
+type FieldSchema = schema.Field
 
 // NewAzureClient สร้าง OpenAI client สำหรับ Azure
 func NewAzureClient(cfg OpenAIConfig) *openai.Client {
@@ -41,89 +37,16 @@ func NewAzureClient(cfg OpenAIConfig) *openai.Client {
 
 // BuildSchemaPrompt สร้าง prompt dynamic สำหรับ JSON complex schema
 func BuildSchemaPrompt(topic string, fields []FieldSchema, instructions []string) string {
-	var buildFields func(fields []FieldSchema, indent string) string
-	buildFields = func(fields []FieldSchema, indent string) string {
-		prompt := ""
-		for _, f := range fields {
-			switch f.Type {
-			case "object":
-				prompt += fmt.Sprintf("%s\"%s\": {\n", indent, f.Name)
-				prompt += buildFields(f.Fields, indent+"  ")
-				prompt += fmt.Sprintf("%s},\n", indent)
-			case "array":
-				if len(f.Fields) > 0 && f.Fields[0].Type == "object" {
-					prompt += fmt.Sprintf("%s\"%s\": [\n%s  {\n", indent, f.Name, indent)
-					prompt += buildFields(f.Fields, indent+"    ")
-					prompt += fmt.Sprintf("%s  }\n%s],\n", indent, indent)
-				} else {
-					prompt += fmt.Sprintf("%s\"%s\": [\"%s\"],\n", indent, f.Name, f.Description)
-				}
-			default:
-				prompt += fmt.Sprintf("%s\"%s\": \"%s (%s)\",\n", indent, f.Name, f.Description, f.Type)
-			}
-		}
-		return prompt
-	}
-
-	prompt := fmt.Sprintf("Please explain \"%s\" in JSON format with the following structure:\n{\n", topic)
-	prompt += buildFields(fields, "  ")
-	prompt += "}\n"
-
-	if len(instructions) > 0 {
-		prompt += "Additional instructions:\n"
-		for _, ins := range instructions {
-			prompt += "- " + ins + "\n"
-		}
-	}
-
-	prompt += "Ensure valid JSON only, no extra text."
-	return prompt
+	return schema.BuildPrompt(topic, fields, instructions)
 }
 
-// ValidateJSON recursive validation
-func ValidateJSON(data interface{}, schema []FieldSchema) error {
-	m, ok := data.(map[string]interface{})
-	if !ok {
-		return errors.New("top-level JSON is not an object")
-	}
-
-	for _, f := range schema {
-		val, exists := m[f.Name]
-		if !exists {
-			return fmt.Errorf("missing field: %s", f.Name)
-		}
-		switch f.Type {
-		case "string":
-			if _, ok := val.(string); !ok {
-				return fmt.Errorf("field '%s' should be string", f.Name)
-			}
-		case "number":
-			if _, ok := val.(float64); !ok {
-				return fmt.Errorf("field '%s' should be number", f.Name)
-			}
-		case "array":
-			arr, ok := val.([]interface{})
-			if !ok {
-				return fmt.Errorf("field '%s' should be array", f.Name)
-			}
-			if len(f.Fields) > 0 && f.Fields[0].Type == "object" {
-				for _, item := range arr {
-					if err := ValidateJSON(item, f.Fields); err != nil {
-						return fmt.Errorf("array item in '%s' invalid: %v", f.Name, err)
-					}
-				}
-			}
-		case "object":
-			if err := ValidateJSON(val, f.Fields); err != nil {
-				return fmt.Errorf("object field '%s' invalid: %v", f.Name, err)
-			}
-		}
-	}
-	return nil
+// ValidateJSON ตรวจ JSON ตาม schema แบบ recursive
+func ValidateJSON(data interface{}, fields []FieldSchema) error {
+	return schema.Validate(data, fields)
 }
 
 // FetchJSONFromAI ส่ง prompt และ validate complex schema
-func FetchJSONFromAI(cfg OpenAIConfig, prompt string, schema []FieldSchema) (map[string]interface{}, error) {
+func FetchJSONFromAI(cfg OpenAIConfig, prompt string, fields []FieldSchema) (map[string]interface{}, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
 	defer cancel()
 
@@ -152,7 +75,7 @@ func FetchJSONFromAI(cfg OpenAIConfig, prompt string, schema []FieldSchema) (map
 		return nil, fmt.Errorf("json unmarshal error: %w", err)
 	}
 
-	if err := ValidateJSON(data, schema); err != nil {
+	if err := ValidateJSON(data, fields); err != nil {
 		return nil, fmt.Errorf("JSON validation failed: %w", err)
 	}
 
@@ -170,7 +93,7 @@ func main() {
 	}
 
 	// Nested/complex schema example
-	schema := []FieldSchema{
+	fields := []FieldSchema{
 		{
 			Name:        "clubs",
 			Type:        "string",
@@ -200,8 +123,8 @@ func main() {
 		"Avoid extra explanation outside JSON",
 	}
 
-	prompt := BuildSchemaPrompt("premier league", schema, instructions)
-	jsonData, err := FetchJSONFromAI(cfg, prompt, schema)
+	prompt := BuildSchemaPrompt("premier league", fields, instructions)
+	jsonData, err := FetchJSONFromAI(cfg, prompt, fields)
 	if err != nil {
 		log.Fatalf("Error fetching JSON from AI: %v", err)
 	}